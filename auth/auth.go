@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Principal is the identity on whose behalf a request is made
+type Principal struct {
+	Name  string
+	Admin bool
+}
+
+// Authenticator identifies the principal that made a request.
+// It returns false if the request doesn't carry valid credentials.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, bool)
+}
+
+// BearerTokens is an Authenticator that maps the bearer token in the Authorization header of a request to a principal
+type BearerTokens map[string]Principal
+
+// Authenticate implements Authenticator.
+func (b BearerTokens) Authenticate(r *http.Request) (Principal, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return Principal{}, false
+	}
+
+	// Compare every token in constant time, so the response time doesn't give away how close a guess was
+	var result Principal
+	var found bool
+	for known, principal := range b {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			result, found = principal, true
+		}
+	}
+
+	return result, found
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries the given principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal carried by ctx, and false if the request wasn't authenticated
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBearerTokens_Authenticate(t *testing.T) {
+	admin := Principal{Name: "admin", Admin: true}
+	tokens := BearerTokens{"secret-token": admin}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantPrincipal Principal
+		wantOK        bool
+	}{
+		{
+			name:          "Valid Token",
+			authorization: "Bearer secret-token",
+			wantPrincipal: admin,
+			wantOK:        true,
+		},
+		{
+			name:          "Case Insensitive Scheme",
+			authorization: "bearer secret-token",
+			wantPrincipal: admin,
+			wantOK:        true,
+		},
+		{
+			name:          "Unknown Token",
+			authorization: "Bearer not-the-token",
+		},
+		{
+			name:          "Other Scheme",
+			authorization: "Basic secret-token",
+		},
+		{
+			name: "No Header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			principal, ok := tokens.Authenticate(r)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantPrincipal, principal)
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	want := Principal{Name: "someone"}
+	got, ok := FromContext(WithPrincipal(context.Background(), want))
+	assert.True(t, ok)
+	assert.Equal(t, want, got)
+}
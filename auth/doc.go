@@ -0,0 +1,2 @@
+// auth identifies the principal on whose behalf a request is made, and carries it through the request context
+package auth
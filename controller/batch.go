@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// maxBatchSize is the largest number of operations a single batch request may hold
+const maxBatchSize = 10000
+
+// The operations that can be part of a batch
+const (
+	batchInsert = "insert"
+	batchUpdate = "update"
+	batchRemove = "remove"
+)
+
+var (
+	// errBatchFailed is used to roll back an all-or-nothing batch in which an operation failed
+	errBatchFailed = errors.New("an operation in the batch failed")
+	// errInvalidBatchRequest indicates that the mode or the number of operations of a batch request is invalid
+	errInvalidBatchRequest = errors.New("invalid batch request")
+)
+
+// batchOperations are the operations of a batch request. In XML they are the operation elements of a batch element,
+// since XML documents have a single root element.
+type batchOperations[T any] []batchOperation[T]
+
+// UnmarshalXML implements xml.Unmarshaler
+func (o *batchOperations[T]) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var batch struct {
+		Operations []batchOperation[T] `xml:"operation"`
+	}
+	if err := d.DecodeElement(&batch, &start); err != nil {
+		return err
+	}
+
+	*o = batch.Operations
+	return nil
+}
+
+// batchOperation is a single operation in a batch request. Inserts only need data, removes only need the ID
+// of the item, and updates need both.
+type batchOperation[T any] struct {
+	Op   string `json:"op" xml:"op"`
+	ID   string `json:"id,omitempty" xml:"id,omitempty"`
+	Data *T     `json:"data,omitempty" xml:"data,omitempty"`
+}
+
+// batchRequest is a parsed batch request
+type batchRequest[T any] struct {
+	// atomic is true if either every operation must succeed or none may be applied,
+	// and false if the operations that succeed are applied even if others fail.
+	atomic     bool
+	operations batchOperations[T]
+}
+
+// parseBatchRequest reads a batch request, which is an array of operations in the body of the request.
+// The mode query parameter is either "atomic", which is the default, or "best-effort".
+func parseBatchRequest[T any](r *http.Request) (batchRequest[T], error) {
+	result := batchRequest[T]{atomic: true}
+
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "atomic":
+	case "best-effort":
+		result.atomic = false
+	default:
+		return result, fmt.Errorf("%w: unknown batch mode %q", errInvalidBatchRequest, url.QueryEscape(mode))
+	}
+
+	if err := decodeRequest(r, &result.operations); err != nil {
+		return result, fmt.Errorf("failed to parse batch: %w", err)
+	}
+	if len(result.operations) == 0 || len(result.operations) > maxBatchSize {
+		return result, fmt.Errorf("%w: a batch must hold between 1 and %d operations, got %d", errInvalidBatchRequest, maxBatchSize, len(result.operations))
+	}
+
+	return result, nil
+}
+
+// batchErrors splits the error returned by a batch operation of a db.Datastore, on a batch of n items,
+// into the error of each item. An error that isn't a db.BatchError applies to the batch as a whole, and is returned as is.
+func batchErrors(err error, n int) ([]error, error) {
+	if err == nil {
+		return make([]error, n), nil
+	}
+
+	var batchErr db.BatchError
+	if !errors.As(err, &batchErr) {
+		return nil, err
+	}
+	return batchErr, nil
+}
+
+// failBatchResult turns a result into the failure with the given status code
+func failBatchResult[T any](result *batchResult[T], statusCode int) {
+	result.Status = statusCode
+	result.ID = ""
+	result.Data = nil
+	result.Error = errorMessage(statusCode)
+	result.Errors = nil
+}
+
+// sendBatchResponse sends the results of a batch back to the client. The status code of the response is:
+//   - 200 if every operation succeeded
+//   - 207 if the batch was best-effort and some operations failed
+//   - the status code of the first failed operation if the batch was atomic
+func sendBatchResponse[T any](w http.ResponseWriter, atomic bool, results []batchResult[T], encoder responseEncoder) error {
+	statusCode := http.StatusOK
+	for _, result := range results {
+		if result.Status < 400 || result.Status == http.StatusFailedDependency {
+			continue
+		}
+
+		statusCode = http.StatusMultiStatus
+		if atomic {
+			statusCode = result.Status
+		}
+		break
+	}
+
+	w.WriteHeader(statusCode)
+	return encoder.Encode(dataResponse[[]batchResult[T]]{
+		baseResponse: baseResponse{Success: statusCode == http.StatusOK},
+		Data:         results,
+	})
+}
@@ -0,0 +1,446 @@
+package controller
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// The media types that requests and responses can be sent in
+const (
+	jsonType    = "application/json"
+	xmlType     = "application/xml"
+	csvType     = "text/csv"
+	msgpackType = "application/vnd.msgpack"
+)
+
+const (
+	// problemJSONType is the media type of problem details (RFC 9457) in JSON
+	problemJSONType = "application/problem+json"
+	// problemXMLType is the media type of problem details (RFC 9457) in XML
+	problemXMLType = "application/problem+xml"
+	// problemXMLNamespace is the namespace of problem details in XML
+	problemXMLNamespace = "urn:ietf:rfc:7807"
+)
+
+var (
+	// errUnsupportedMediaType indicates that the body of a request is in a media type that can't be decoded
+	errUnsupportedMediaType = errors.New("unsupported media type")
+	// errMalformedBody indicates that the body of a request couldn't be decoded
+	errMalformedBody = errors.New("malformed request body")
+	// errNotTabular indicates that a response can't be sent as CSV, because it doesn't hold a list of items
+	errNotTabular = errors.New("only lists of items can be encoded as CSV")
+)
+
+// codec encodes response bodies, and decodes request bodies, in a single media type
+type codec struct {
+	mediaType string
+	// aliases are other names clients use for the media type
+	aliases []string
+	// problemType is the media type of problem details in this format, or empty if the format can't express them,
+	// in which case errors are sent as JSON
+	problemType string
+	encode      func(w io.Writer, v any) error
+	// decode is nil if request bodies can't be sent in this format
+	decode func(r io.Reader, v any) error
+}
+
+var (
+	jsonCodec = codec{
+		mediaType:   jsonType,
+		problemType: problemJSONType,
+		encode: func(w io.Writer, v any) error {
+			return json.NewEncoder(w).Encode(v)
+		},
+		decode: func(r io.Reader, v any) error {
+			return json.NewDecoder(r).Decode(v)
+		},
+	}
+	xmlCodec = codec{
+		mediaType:   xmlType,
+		aliases:     []string{"text/xml"},
+		problemType: problemXMLType,
+		encode:      encodeXML,
+		decode: func(r io.Reader, v any) error {
+			return xml.NewDecoder(r).Decode(v)
+		},
+	}
+	msgpackCodec = codec{
+		mediaType:   msgpackType,
+		aliases:     []string{"application/x-msgpack", "application/msgpack"},
+		problemType: msgpackType,
+		encode: func(w io.Writer, v any) error {
+			encoder := msgpack.NewEncoder(w)
+			encoder.SetCustomStructTag("json")
+			return encoder.Encode(v)
+		},
+		decode: func(r io.Reader, v any) error {
+			decoder := msgpack.NewDecoder(r)
+			decoder.SetCustomStructTag("json")
+			return decoder.Decode(v)
+		},
+	}
+	csvCodec = codec{
+		mediaType: csvType,
+		encode:    encodeCSV,
+	}
+)
+
+var (
+	// itemCodecs are the codecs a response that holds a single item can be sent in, the first being the default
+	itemCodecs = []codec{jsonCodec, xmlCodec, msgpackCodec}
+	// listCodecs are the codecs a response that holds a list of items can be sent in, the first being the default
+	listCodecs = []codec{jsonCodec, xmlCodec, msgpackCodec, csvCodec}
+)
+
+// names returns the media type of the codec along with its aliases. The media type of its problem details counts
+// as one of them, so that clients that only list application/problem+json, to get errors as problem details,
+// are still sent JSON when there is no error.
+func (c codec) names() []string {
+	names := append([]string{c.mediaType}, c.aliases...)
+	if c.problemType != "" && c.problemType != c.mediaType {
+		names = append(names, c.problemType)
+	}
+	return names
+}
+
+// responseEncoder writes values to the body of a response, in the media type that was negotiated with the client
+type responseEncoder struct {
+	w     http.ResponseWriter
+	codec codec
+}
+
+// Encode writes v to the body of the response
+func (e responseEncoder) Encode(v any) error {
+	return e.codec.encode(e.w, v)
+}
+
+// jsonResponse returns an encoder that writes JSON, for responses that aren't negotiated, such as event streams
+func jsonResponse(w http.ResponseWriter) responseEncoder {
+	return responseEncoder{w: w, codec: jsonCodec}
+}
+
+// negotiate picks the codec of the response to r from codecs, based on the Accept header of r, and returns an encoder
+// that writes in it. The Content-Type of the response is set accordingly. If the client accepts none of the codecs,
+// a 406 response is sent and false is returned.
+func negotiate(w http.ResponseWriter, r *http.Request, format ErrorFormat, codecs []codec) (responseEncoder, bool) {
+	accept := parseAccept(r)
+	if len(accept) == 0 {
+		w.Header().Set("Content-Type", codecs[0].mediaType)
+		return responseEncoder{w: w, codec: codecs[0]}, true
+	}
+
+	best, bestQuality := -1, 0.0
+	for i, c := range codecs {
+		if quality := accept.quality(c.names()...); quality > bestQuality {
+			best, bestQuality = i, quality
+		}
+	}
+	if best < 0 {
+		encoder := jsonResponse(w)
+		sendErrorResponse(w, r, format, http.StatusNotAcceptable, encoder)
+		return encoder, false
+	}
+
+	w.Header().Set("Content-Type", codecs[best].mediaType)
+	return responseEncoder{w: w, codec: codecs[best]}, true
+}
+
+// decodeRequest decodes the body of r into v, in the format given by its Content-Type, which is JSON if it has none.
+// It returns errUnsupportedMediaType if the format can't be decoded, and errMalformedBody if the body isn't valid.
+func decodeRequest(r *http.Request, v any) error {
+	c := jsonCodec
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errUnsupportedMediaType, err)
+		}
+
+		found := false
+		for _, candidate := range itemCodecs {
+			for _, name := range candidate.names() {
+				if name == mediaType && candidate.decode != nil {
+					c, found = candidate, true
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", errUnsupportedMediaType, mediaType)
+		}
+	}
+
+	if err := c.decode(r.Body, v); err != nil {
+		return fmt.Errorf("%w: %w", errMalformedBody, err)
+	}
+	return nil
+}
+
+// mediaRange is a single entry of an Accept header
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// acceptHeader is the parsed Accept header of a request
+type acceptHeader []mediaRange
+
+// parseAccept parses the Accept header of r. Entries that can't be parsed are left out.
+func parseAccept(r *http.Request) acceptHeader {
+	var result acceptHeader
+	for _, value := range r.Header.Values("Accept") {
+		for _, entry := range strings.Split(value, ",") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			mediaType, params, err := mime.ParseMediaType(entry)
+			if err != nil {
+				continue
+			}
+
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					continue
+				}
+			}
+			result = append(result, mediaRange{mediaType: mediaType, quality: quality})
+		}
+	}
+
+	return result
+}
+
+// quality returns how much the client prefers the media type known by the given names, between 0 for not at all and 1.
+// The most specific entry that matches the media type decides, so that "application/json;q=0" excludes JSON
+// even if "*/*" is listed as well.
+func (a acceptHeader) quality(names ...string) float64 {
+	quality, specificity := 0.0, 0
+	for _, entry := range a {
+		for _, name := range names {
+			mainType, _, _ := strings.Cut(name, "/")
+			var matched int
+			switch entry.mediaType {
+			case name:
+				matched = 3
+			case mainType + "/*":
+				matched = 2
+			case "*/*":
+				matched = 1
+			}
+			if matched > specificity || (matched == specificity && matched > 0 && entry.quality > quality) {
+				quality, specificity = entry.quality, matched
+			}
+		}
+	}
+
+	return quality
+}
+
+// refuses reports whether the client explicitly refuses the given media type, by listing it with a quality of zero
+func (a acceptHeader) refuses(mediaType string) bool {
+	for _, entry := range a {
+		if entry.mediaType == mediaType && entry.quality == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlNamer is implemented by responses whose root element in XML isn't called "response"
+type xmlNamer interface {
+	xmlName() xml.Name
+}
+
+// encodeXML writes v as an XML document, whose root element is called "response" unless v is an xmlNamer
+func encodeXML(w io.Writer, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: "response"}}
+	if namer, ok := v.(xmlNamer); ok {
+		start.Name = namer.xmlName()
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if err := encoder.EncodeElement(v, start); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// lister is implemented by responses that hold a list of items, which are the only responses that can be sent as CSV
+type lister interface {
+	listItems() any
+}
+
+// encodeCSV writes the items of v, which has to be a lister, as CSV. The first row names the columns, which are the
+// fields of the items as they are named in JSON. The fields of nested objects are flattened into columns such as
+// "data.firstName", while lists are written as JSON.
+func encodeCSV(w io.Writer, v any) error {
+	list, ok := v.(lister)
+	if !ok {
+		return errNotTabular
+	}
+	items := reflect.ValueOf(list.listItems())
+	if items.Kind() != reflect.Slice {
+		return errNotTabular
+	}
+
+	itemType := items.Type().Elem()
+	for itemType.Kind() == reflect.Pointer {
+		itemType = itemType.Elem()
+	}
+	if itemType.Kind() != reflect.Struct {
+		return errNotTabular
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader(itemType, "")); err != nil {
+		return err
+	}
+	for i := 0; i < items.Len(); i++ {
+		row, err := csvRow(items.Index(i), itemType)
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvFields returns the fields of a struct type that are encoded, along with their names in JSON
+func csvFields(t reflect.Type) ([]reflect.StructField, []string) {
+	var fields []reflect.StructField
+	var names []string
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, field)
+		names = append(names, name)
+	}
+
+	return fields, names
+}
+
+// csvColumn reports whether a field is written as a single column, rather than being flattened into a column
+// for each of its own fields
+func csvColumn(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() != reflect.Struct
+}
+
+// csvHeader returns the names of the columns of a struct type, each prefixed by prefix
+func csvHeader(t reflect.Type, prefix string) []string {
+	var header []string
+	fields, names := csvFields(t)
+	for i, field := range fields {
+		if csvColumn(field.Type) {
+			header = append(header, prefix+names[i])
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		header = append(header, csvHeader(fieldType, prefix+names[i]+".")...)
+	}
+
+	return header
+}
+
+// csvRow returns the cells of a struct value of type t, in the order of csvHeader. The cells of a nil pointer are empty.
+func csvRow(v reflect.Value, t reflect.Type) ([]string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return make([]string, len(csvHeader(t, ""))), nil
+		}
+		v = v.Elem()
+	}
+
+	var row []string
+	fields, _ := csvFields(t)
+	for _, field := range fields {
+		value := v.FieldByIndex(field.Index)
+		if !csvColumn(field.Type) {
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			cells, err := csvRow(value, fieldType)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, cells...)
+			continue
+		}
+
+		cell, err := csvCell(value)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, cell)
+	}
+
+	return row, nil
+}
+
+// csvCell formats a single value as a cell. Lists, maps and values that aren't known are written as JSON.
+func csvCell(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return "", nil
+		}
+		data, err := json.Marshal(v.Interface())
+		return string(data), err
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	default:
+		data, err := json.Marshal(v.Interface())
+		return string(data), err
+	}
+}
@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/memdb"
+)
+
+func Test_negotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept []string
+		codecs []codec
+		want   string // The Content-Type of the response, which is empty if it isn't acceptable
+	}{
+		{name: "No Accept", codecs: itemCodecs, want: jsonType},
+		{name: "Any", accept: []string{"*/*"}, codecs: itemCodecs, want: jsonType},
+		{name: "JSON", accept: []string{"application/json"}, codecs: itemCodecs, want: jsonType},
+		{name: "XML", accept: []string{"application/xml"}, codecs: itemCodecs, want: xmlType},
+		{name: "XML Alias", accept: []string{"text/xml"}, codecs: itemCodecs, want: xmlType},
+		{name: "MessagePack", accept: []string{"application/x-msgpack"}, codecs: itemCodecs, want: msgpackType},
+		{name: "CSV List", accept: []string{"text/csv"}, codecs: listCodecs, want: csvType},
+		{name: "CSV Item", accept: []string{"text/csv"}, codecs: itemCodecs},
+		{name: "Quality", accept: []string{"application/json;q=0.5, application/xml;q=0.8"}, codecs: itemCodecs, want: xmlType},
+		{name: "Multiple Headers", accept: []string{"text/html", "application/xml"}, codecs: itemCodecs, want: xmlType},
+		{name: "Specific Beats Range", accept: []string{"application/json;q=0, application/*"}, codecs: itemCodecs, want: xmlType},
+		{name: "Range", accept: []string{"text/csv;q=0.5, application/*"}, codecs: listCodecs, want: jsonType},
+		{name: "Problem Details", accept: []string{"application/problem+json"}, codecs: itemCodecs, want: jsonType},
+		{name: "XML Problem Details", accept: []string{"application/problem+xml"}, codecs: listCodecs, want: xmlType},
+		{name: "Nothing Acceptable", accept: []string{"text/html"}, codecs: itemCodecs},
+		{name: "Refused", accept: []string{"*/*;q=0"}, codecs: itemCodecs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/person", nil)
+			for _, value := range tt.accept {
+				r.Header.Add("Accept", value)
+			}
+
+			encoder, ok := negotiate(w, r, ErrorFormatProblem, tt.codecs)
+			if tt.want == "" {
+				assert.False(t, ok)
+				assert.Equal(t, http.StatusNotAcceptable, w.Code)
+				return
+			}
+			if assert.True(t, ok) {
+				assert.Equal(t, tt.want, encoder.codec.mediaType)
+				assert.Equal(t, tt.want, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func Test_decodeRequest(t *testing.T) {
+	want := person{FirstName: "Testy", LastName: "McTesterson", DateOfBirth: "1970-01-01"}
+	packed, err := msgpack.Marshal(map[string]any{"firstName": "Testy", "lastName": "McTesterson", "dob": "1970-01-01"})
+	if err != nil {
+		t.Fatalf("failed to encode MessagePack: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantErr     error
+	}{
+		{name: "No Content-Type", body: []byte(`{"firstName": "Testy", "lastName": "McTesterson", "dob": "1970-01-01"}`)},
+		{name: "JSON", contentType: "application/json; charset=utf-8", body: []byte(`{"firstName": "Testy", "lastName": "McTesterson", "dob": "1970-01-01"}`)},
+		{name: "XML", contentType: "application/xml", body: []byte(`<person><firstName>Testy</firstName><lastName>McTesterson</lastName><dob>1970-01-01</dob></person>`)},
+		{name: "MessagePack", contentType: msgpackType, body: packed},
+		{name: "CSV", contentType: csvType, body: []byte("firstName,lastName\nTesty,McTesterson\n"), wantErr: errUnsupportedMediaType},
+		{name: "Unknown", contentType: "text/plain", body: []byte("Testy McTesterson"), wantErr: errUnsupportedMediaType},
+		{name: "Malformed", contentType: "application/xml", body: []byte(`{"firstName": "Testy"}`), wantErr: errMalformedBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/person", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var got person
+			err := decodeRequest(r, &got)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func Test_encodeCSV(t *testing.T) {
+	t.Run("Page", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := encodeCSV(&buf, dataResponse[pageResponse[person]]{
+			Data: pageResponse[person]{
+				Items: []person{
+					{ID: "1", FirstName: "Testy", LastName: "McTesterson, Jr.", DateOfBirth: "1/1/1970"},
+					{ID: "2", FirstName: "Some", LastName: "Tester", Removed: true, RemovedAt: "2024-01-01T00:00:00Z"},
+				},
+				Total: 2,
+				Next:  "cursor",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "id,firstName,lastName,dob,removed,createdAt,updatedAt,removedAt\n"+
+			"1,Testy,\"McTesterson, Jr.\",1/1/1970,false,,,\n"+
+			"2,Some,Tester,,true,,,2024-01-01T00:00:00Z\n", buf.String())
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := encodeCSV(&buf, dataResponse[[]batchResult[person]]{
+			Data: []batchResult[person]{
+				{Index: 0, Status: http.StatusCreated, ID: "1", Data: &person{ID: "1", FirstName: "Testy", LastName: "McTesterson"}},
+				{Index: 1, Status: http.StatusUnprocessableEntity, Error: "malformed request data", Errors: []fieldError{{Field: "data.firstName", Code: codeRequired, Message: "is required"}}},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "index,status,id,data.id,data.firstName,data.lastName,data.dob,data.removed,data.createdAt,data.updatedAt,data.removedAt,error,errors\n"+
+			"0,201,1,1,Testy,McTesterson,,false,,,,,\n"+
+			"1,422,,,,,,,,,,malformed request data,\"[{\"\"field\"\":\"\"data.firstName\"\",\"\"code\"\":\"\"required\"\",\"\"message\"\":\"\"is required\"\"}]\"\n", buf.String())
+	})
+
+	t.Run("Single Item", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := encodeCSV(&buf, dataResponse[person]{Data: person{ID: "1"}})
+		assert.ErrorIs(t, err, errNotTabular)
+		assert.Empty(t, buf.String())
+	})
+}
+
+func Test_encodeXML(t *testing.T) {
+	t.Run("Response", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := encodeXML(&buf, dataResponse[person]{
+			baseResponse: baseResponse{Success: true},
+			Data:         person{ID: "1", FirstName: "Testy", LastName: "McTesterson", DateOfBirth: "1/1/1970"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, xml.Header+"<response><success>true</success><data><id>1</id><firstName>Testy</firstName>"+
+			"<lastName>McTesterson</lastName><dob>1/1/1970</dob><removed>false</removed></data></response>\n", buf.String())
+	})
+
+	t.Run("Problem", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := encodeXML(&buf, problem{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound, Detail: "not found"})
+		assert.NoError(t, err)
+		assert.Equal(t, xml.Header+`<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type><title>Not Found</title>`+
+			"<status>404</status><detail>not found</detail></problem>\n", buf.String())
+	})
+}
+
+func Test_personDataHandler_ContentNegotiation(t *testing.T) {
+	database := memdb.NewSession()
+	pdh := personDataHandler{
+		database:        database,
+		personDatastore: database.Person(),
+		logger:          slog.Default(),
+	}
+
+	// Add a person in XML, and get it back in MessagePack
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/person", strings.NewReader(
+		`<person><firstName>Testy</firstName><lastName>McTesterson</lastName><dob>1970-01-01</dob></person>`,
+	))
+	r.Header.Set("Content-Type", "application/xml")
+	r.Header.Set("Accept", msgpackType)
+	pdh.Add(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, msgpackType, w.Header().Get("Content-Type"))
+	var added dataResponse[person]
+	decoder := msgpack.NewDecoder(w.Body)
+	decoder.SetCustomStructTag("json")
+	if err := decoder.Decode(&added); err != nil {
+		t.Fatalf("failed to decode MessagePack response: %v", err)
+	}
+	assert.True(t, added.Success)
+	assert.Equal(t, "McTesterson", added.Data.LastName)
+	assert.Equal(t, "1/1/1970", added.Data.DateOfBirth)
+
+	t.Run("List as CSV", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := withHeader(httptest.NewRequest(http.MethodGet, "/person", nil), "Accept", "text/csv")
+		pdh.GetAll(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, csvType, w.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.Equal(t, "id,firstName,lastName,dob,removed,createdAt,updatedAt,removedAt", lines[0])
+			assert.True(t, strings.HasPrefix(lines[1], added.Data.ID+",Testy,McTesterson,1/1/1970,false,"), lines[1])
+		}
+	})
+
+	t.Run("Item for Problem Details", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := withHeader(httptest.NewRequest(http.MethodGet, "/person/{id}", nil), "Accept", problemJSONType)
+		r = withURLParam(r, "id", added.Data.ID)
+		pdh.GetSpecific(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, jsonType, w.Header().Get("Content-Type"))
+	})
+
+	t.Run("Item as CSV", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := withHeader(httptest.NewRequest(http.MethodGet, "/person/{id}", nil), "Accept", "text/csv")
+		r = withURLParam(r, "id", added.Data.ID)
+		pdh.GetSpecific(w, r)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Equal(t, problemJSONType, w.Header().Get("Content-Type"))
+	})
+
+	t.Run("Unsupported Content-Type", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := withHeader(httptest.NewRequest(http.MethodPost, "/person", strings.NewReader("Testy McTesterson")), "Content-Type", "text/plain")
+		pdh.Add(w, r)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		page, err := database.Person().Query(r.Context(), db.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, page.Total)
+	})
+
+	t.Run("Error as XML", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := withHeader(httptest.NewRequest(http.MethodPost, "/person", strings.NewReader(`{"firstName": ""}`)), "Accept", "application/xml, application/problem+xml")
+		pdh.Add(w, r)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, problemXMLType, w.Header().Get("Content-Type"))
+		var got problem
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, http.StatusUnprocessableEntity, got.Status)
+		assert.Equal(t, validationError{
+			{Field: "firstName", Code: codeRequired, Message: "is required"},
+			{Field: "lastName", Code: codeRequired, Message: "is required"},
+		}, got.Errors)
+	})
+
+	t.Run("Legacy Error as XML", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := withHeader(httptest.NewRequest(http.MethodGet, "/person/{id}", nil), "Accept", "application/xml, application/problem+xml;q=0")
+		r = withURLParam(r, "id", "not-a-uuid")
+		pdh.GetSpecific(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, xmlType, w.Header().Get("Content-Type"))
+		var got baseResponse
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, baseResponse{Message: "not found"}, got)
+	})
+
+	t.Run("Batch in XML", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/person/batch", strings.NewReader(`<batch>
+			<operation><op>insert</op><data><firstName>Some</firstName><lastName>Tester</lastName></data></operation>
+			<operation><op>insert</op><data><firstName>Another</firstName><lastName>Tester</lastName></data></operation>
+		</batch>`))
+		r.Header.Set("Content-Type", "application/xml")
+		pdh.Batch(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var got dataResponse[[]batchResult[person]]
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		if assert.Len(t, got.Data, 2) {
+			assert.Equal(t, "Some", got.Data[0].Data.FirstName)
+			assert.Equal(t, "Another", got.Data[1].Data.FirstName)
+		}
+	})
+}
+
+// withURLParam sets a URL parameter of r, as the router would
+func withURLParam(r *http.Request, key, value string) *http.Request {
+	chiContext := chi.NewRouteContext()
+	chiContext.URLParams.Add(key, value)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiContext))
+}
@@ -1,8 +1,12 @@
 package controller
 
 import (
+	"context"
+	"crypto/rand"
 	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/williabk198/go-api-server-template/db"
 )
@@ -11,36 +15,165 @@ const (
 	requestDateFormat      string = "2006-01-02"
 	requestTimeFormat      string = "15:04Z07:00"
 	requestTimestampFormat string = requestDateFormat + "T" + requestTimeFormat
+	responseDateFormat     string = "1/2/2006"
+)
+
+// DefaultEventHeartbeat is how often an idle event stream sends a heartbeat, unless WithEventHeartbeat says otherwise
+const DefaultEventHeartbeat = 15 * time.Second
+
+// DefaultSocketBuffer is the number of messages held for a socket client, unless WithSocketBuffer says otherwise
+const DefaultSocketBuffer = 64
+
+// ErrorFormat is the format in which error responses are sent
+type ErrorFormat int
+
+const (
+	// ErrorFormatProblem sends errors as problem details (RFC 9457) with the application/problem+json media type.
+	// Clients whose Accept header refuses application/problem+json, by giving it a quality of zero, are sent
+	// errors in the ErrorFormatLegacy format instead.
+	ErrorFormatProblem ErrorFormat = iota
+	// ErrorFormatLegacy sends errors as {"success": false, "msg": "..."}, which is how they were sent before
+	// problem details were supported.
+	ErrorFormatLegacy
 )
 
 // DataHandler defines simple HTTP handlers that interact with database data.
 type DataHandler interface {
 	Add(w http.ResponseWriter, r *http.Request)
+	Batch(w http.ResponseWriter, r *http.Request)
+	Events(w http.ResponseWriter, r *http.Request)
+	Socket(w http.ResponseWriter, r *http.Request)
+	GetAll(w http.ResponseWriter, r *http.Request)
 	GetSpecific(w http.ResponseWriter, r *http.Request)
+	History(w http.ResponseWriter, r *http.Request)
+	Patch(w http.ResponseWriter, r *http.Request)
+	Purge(w http.ResponseWriter, r *http.Request)
 	Remove(w http.ResponseWriter, r *http.Request)
+	Restore(w http.ResponseWriter, r *http.Request)
+	Revert(w http.ResponseWriter, r *http.Request)
 	Update(w http.ResponseWriter, r *http.Request)
 }
 
 // Controller defines the different parts of the controller.
 type Controller interface {
 	Person() DataHandler
+	// Drain waits until the event streams and sockets are closed, which they are once the context passed to
+	// WithShutdown is done. It returns the error of ctx if it is done first.
+	Drain(ctx context.Context) error
+}
+
+// Option configures optional behavior of the controller
+type Option func(*controller)
+
+// WithCursorSecret sets the secret used to sign the cursors handed out for paging through lists.
+// If it isn't set a random secret is used, which means cursors stop working when the server restarts.
+func WithCursorSecret(secret []byte) Option {
+	return func(c *controller) {
+		c.cursorSecret = secret
+	}
+}
+
+// WithShutdown ends the event streams, which would otherwise last as long as their clients stay connected,
+// once ctx is done. This lets a graceful shutdown of the server finish.
+func WithShutdown(ctx context.Context) Option {
+	return func(c *controller) {
+		c.shutdown = ctx
+	}
+}
+
+// WithEventHeartbeat sets how often an event stream or socket sends a heartbeat while there are no events,
+// so that proxies don't close it for being idle. By default this is DefaultEventHeartbeat.
+// Socket clients that don't answer for two heartbeats are disconnected.
+func WithEventHeartbeat(interval time.Duration) Option {
+	return func(c *controller) {
+		c.heartbeat = interval
+	}
+}
+
+// WithSocketBuffer sets the number of messages that are held for a socket client while it is busy.
+// Clients that fall further behind are disconnected. By default this is DefaultSocketBuffer.
+func WithSocketBuffer(size int) Option {
+	return func(c *controller) {
+		c.socketBuffer = size
+	}
+}
+
+// WithErrorFormat sets the format in which error responses are sent. By default this is ErrorFormatProblem.
+func WithErrorFormat(format ErrorFormat) Option {
+	return func(c *controller) {
+		c.errorFormat = format
+	}
+}
+
+// WithClock sets the clock that decides which dates lie in the future, such as the dates of birth of people.
+// By default the system clock is used.
+func WithClock(clock db.Clock) Option {
+	return func(c *controller) {
+		c.clock = clock
+	}
 }
 
 type controller struct {
-	database db.Database
-	logger   *slog.Logger
+	database     db.Database
+	logger       *slog.Logger
+	cursorSecret []byte
+	shutdown     context.Context
+	heartbeat    time.Duration
+	socketBuffer int
+	streams      *sync.WaitGroup // The event streams and sockets that are open
+	errorFormat  ErrorFormat
+	clock        db.Clock
 }
 
 func (c controller) Person() DataHandler {
 	return personDataHandler{
+		database:        c.database,
 		personDatastore: c.database.Person(),
+		personHistory:   c.database.PersonHistory(),
+		personFeed:      c.database.PersonFeed(),
 		logger:          c.logger,
+		cursorSecret:    c.cursorSecret,
+		shutdown:        c.shutdown,
+		heartbeat:       c.heartbeat,
+		socketBuffer:    c.socketBuffer,
+		streams:         c.streams,
+		errorFormat:     c.errorFormat,
+		clock:           c.clock,
 	}
 }
 
-func NewController(logger *slog.Logger, database db.Database) Controller {
-	return controller{
-		database: database,
-		logger:   logger,
+func NewController(logger *slog.Logger, database db.Database, opts ...Option) Controller {
+	c := controller{
+		database:     database,
+		logger:       logger,
+		shutdown:     context.Background(),
+		heartbeat:    DefaultEventHeartbeat,
+		socketBuffer: DefaultSocketBuffer,
+		streams:      &sync.WaitGroup{},
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if len(c.cursorSecret) == 0 {
+		c.cursorSecret = make([]byte, 32)
+		rand.Read(c.cursorSecret)
+	}
+
+	return c
+}
+
+func (c controller) Drain(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		c.streams.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -9,8 +9,10 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/williabk198/go-api-server-template/auth"
 	"github.com/williabk198/go-api-server-template/db"
 )
 
@@ -23,6 +25,7 @@ type args struct {
 
 type wantResp[T any] struct {
 	statusCode int
+	headers    map[string]string
 	data       T
 }
 
@@ -36,6 +39,16 @@ func assertResponse[T any](t *testing.T, wantResp wantResp[T], gotRespWriter htt
 		return
 	}
 
+	// Ensure that the expected headers were set
+	for key, value := range wantResp.headers {
+		assert.Equal(t, value, gotResp.Header.Get(key), "header %q", key)
+	}
+
+	if gotResp.Header.Get("Content-Type") == problemJSONType {
+		assertProblem(t, wantResp, gotResp)
+		return
+	}
+
 	// Ensure that the returned response is in JSON format
 	var gotData T
 	if err := json.NewDecoder(gotResp.Body).Decode(&gotData); err != nil {
@@ -47,6 +60,37 @@ func assertResponse[T any](t *testing.T, wantResp wantResp[T], gotRespWriter htt
 	assert.Equal(t, wantResp.data, gotData)
 }
 
+// assertProblem checks an error response sent as problem details. The expected response is given in the legacy
+// format, whose message and validation errors the problem details have to carry.
+func assertProblem[T any](t *testing.T, wantResp wantResp[T], gotResp *http.Response) {
+	t.Helper()
+
+	var gotProblem problem
+	if err := json.NewDecoder(gotResp.Body).Decode(&gotProblem); err != nil {
+		t.Errorf("failed to decode problem details %v", err)
+		return
+	}
+
+	rawWant, err := json.Marshal(wantResp.data)
+	if err != nil {
+		t.Fatalf("failed to encode expected response: %v", err)
+	}
+	var want validationResponse
+	if err := json.Unmarshal(rawWant, &want); err != nil {
+		t.Fatalf("expected response is not an error response: %v", err)
+	}
+
+	assert.Equal(t, "about:blank", gotProblem.Type)
+	assert.Equal(t, http.StatusText(wantResp.statusCode), gotProblem.Title)
+	assert.Equal(t, wantResp.statusCode, gotProblem.Status)
+	assert.Equal(t, want.Message, gotProblem.Detail)
+	assert.NotEmpty(t, gotProblem.Instance)
+	// Only validationResponse can hold the validation errors, so the other kinds of responses don't check them
+	if want.Errors != nil {
+		assert.Equal(t, want.Errors, gotProblem.Errors)
+	}
+}
+
 func encodeJSONBody(t *testing.T, data any) io.Reader {
 	t.Helper()
 
@@ -58,8 +102,38 @@ func encodeJSONBody(t *testing.T, data any) io.Reader {
 	return bytes.NewBuffer(rawData)
 }
 
+func withHeader(r *http.Request, key, value string) *http.Request {
+	r.Header.Set(key, value)
+	return r
+}
+
+func withPrincipal(r *http.Request, principal auth.Principal) *http.Request {
+	return r.WithContext(auth.WithPrincipal(r.Context(), principal))
+}
+
 /* Mock Data Section */
 
+type mockDatabase struct {
+	person        *mockDatastore[db.Person, uuid.UUID]
+	personHistory *mockHistoryStore[db.Person, uuid.UUID]
+}
+
+func (md *mockDatabase) Person() db.Datastore[db.Person, uuid.UUID] {
+	return md.person
+}
+
+func (md *mockDatabase) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return md.personHistory
+}
+
+func (md *mockDatabase) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return nil
+}
+
+func (md *mockDatabase) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	return fn(md)
+}
+
 type mockDatastore[T db.Entity, U db.Identifier] struct {
 	mock.Mock
 }
@@ -74,12 +148,56 @@ func (md *mockDatastore[T, U]) Insert(ctx context.Context, data *T) error {
 	return args.Error(0)
 }
 
+func (md *mockDatastore[T, U]) InsertBatch(ctx context.Context, items []*T) error {
+	args := md.Called(ctx, items)
+	return args.Error(0)
+}
+
+func (md *mockDatastore[T, U]) Purge(ctx context.Context, id U) error {
+	args := md.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (md *mockDatastore[T, U]) Query(ctx context.Context, query db.Query) (*db.Page[T], error) {
+	args := md.Called(ctx, query)
+	return args.Get(0).(*db.Page[T]), args.Error(1)
+}
+
 func (md *mockDatastore[T, U]) Remove(ctx context.Context, id U) (*T, error) {
 	args := md.Called(ctx, id)
 	return args.Get(0).(*T), args.Error(1)
 }
 
+func (md *mockDatastore[T, U]) RemoveBatch(ctx context.Context, ids []U) ([]*T, error) {
+	args := md.Called(ctx, ids)
+	return args.Get(0).([]*T), args.Error(1)
+}
+
+func (md *mockDatastore[T, U]) Restore(ctx context.Context, id U) (*T, error) {
+	args := md.Called(ctx, id)
+	return args.Get(0).(*T), args.Error(1)
+}
+
 func (md *mockDatastore[T, U]) Update(ctx context.Context, filter *T) error {
 	args := md.Called(ctx, filter)
 	return args.Error(0)
 }
+
+func (md *mockDatastore[T, U]) UpdateBatch(ctx context.Context, items []*T) error {
+	args := md.Called(ctx, items)
+	return args.Error(0)
+}
+
+type mockHistoryStore[T db.Entity, U db.Identifier] struct {
+	mock.Mock
+}
+
+func (mh *mockHistoryStore[T, U]) Get(ctx context.Context, id U, version int) (*db.HistoryEntry[T], error) {
+	args := mh.Called(ctx, id, version)
+	return args.Get(0).(*db.HistoryEntry[T]), args.Error(1)
+}
+
+func (mh *mockHistoryStore[T, U]) List(ctx context.Context, id U) ([]db.HistoryEntry[T], error) {
+	args := mh.Called(ctx, id)
+	return args.Get(0).([]db.HistoryEntry[T]), args.Error(1)
+}
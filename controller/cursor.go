@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errInvalidCursor indicates that a cursor token was not created by this server, or was tampered with
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursor marks a position in a list of items, so that the page before or after it can be requested.
+// It is handed to clients as an opaque, signed token.
+type cursor struct {
+	// Backward indicates that the page before the position is requested, rather than the page after it.
+	Backward bool `json:"b,omitempty"`
+	// Sort and IncludeRemoved hold the list options the cursor was created for, since
+	// a position is meaningless for a list that is sorted or filtered differently.
+	Sort           string `json:"s,omitempty"`
+	IncludeRemoved bool   `json:"r,omitempty"`
+	// Position holds the values of the sort fields, and the key, of the item that marks the position.
+	Position []string `json:"p"`
+}
+
+// encodeCursor turns the cursor into a token that is signed with the given secret.
+func encodeCursor(secret []byte, c cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(signCursor(secret, payload)), nil
+}
+
+// decodeCursor reads a token created by encodeCursor, and verifies that it was signed with the given secret.
+func decodeCursor(secret []byte, token string) (cursor, error) {
+	encoding := base64.RawURLEncoding
+
+	rawPayload, rawSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return cursor{}, errInvalidCursor
+	}
+	payload, err := encoding.DecodeString(rawPayload)
+	if err != nil {
+		return cursor{}, errInvalidCursor
+	}
+	signature, err := encoding.DecodeString(rawSignature)
+	if err != nil {
+		return cursor{}, errInvalidCursor
+	}
+	if !hmac.Equal(signature, signCursor(secret, payload)) {
+		return cursor{}, errInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return cursor{}, errInvalidCursor
+	}
+
+	return c, nil
+}
+
+func signCursor(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// formatPositionValue turns a field value into its cursor representation.
+func formatPositionValue(value any) string {
+	switch value := value.(type) {
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// parsePositionValue reads a value created by formatPositionValue, returning a value of the same type as example.
+func parsePositionValue(example any, raw string) (any, error) {
+	switch example.(type) {
+	case string:
+		return raw, nil
+	case time.Time:
+		return time.Parse(time.RFC3339Nano, raw)
+	case uuid.UUID:
+		return uuid.Parse(raw)
+	}
+	return nil, fmt.Errorf("unsupported position value type %T", example)
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// eventStream writes Server-Sent Events to a response. Writes are buffered until flush is called,
+// which also reports the first error that occurred since the previous flush.
+type eventStream struct {
+	controller *http.ResponseController
+	writer     *bufio.Writer
+	err        error
+}
+
+// newEventStream sends the headers of an event stream, and returns the stream
+func newEventStream(w http.ResponseWriter) *eventStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keeps nginx from holding back events
+	w.WriteHeader(http.StatusOK)
+
+	return &eventStream{controller: http.NewResponseController(w), writer: bufio.NewWriter(w)}
+}
+
+// event writes an event of the given type, with data encoded as JSON. The id is left out if it is empty.
+func (s *eventStream) event(eventType, id string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		s.fail(fmt.Errorf("failed to encode event: %w", err))
+		return
+	}
+
+	if id != "" {
+		s.write("id: " + id + "\n")
+	}
+	s.write("event: " + eventType + "\n")
+	s.write("data: " + string(payload) + "\n\n")
+}
+
+// comment writes a comment, which clients ignore but which keeps the connection from being idle
+func (s *eventStream) comment(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		s.write(": " + line + "\n")
+	}
+	s.write("\n")
+}
+
+// flush sends the buffered events to the client
+func (s *eventStream) flush() error {
+	if s.err == nil {
+		s.fail(s.writer.Flush())
+	}
+	if s.err == nil {
+		s.fail(s.controller.Flush())
+	}
+
+	err := s.err
+	s.err = nil
+	return err
+}
+
+func (s *eventStream) write(text string) {
+	if s.err == nil {
+		_, s.err = s.writer.WriteString(text)
+	}
+}
+
+func (s *eventStream) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// errInvalidListRequest indicates that the query parameters of a list request could not be understood
+var errInvalidListRequest = errors.New("invalid list request")
+
+// listRequest holds the options of a request for a page of items
+type listRequest struct {
+	pageSize       int
+	sortSpec       string
+	sort           []db.Sort
+	includeRemoved bool
+	cursor         *cursor
+}
+
+// parseListRequest reads the `pageSize`, `sort`, `includeRemoved` and `cursor` query parameters.
+// sortFields maps the field names clients may sort on to the fields of the entity.
+//
+// `sort` is a comma separated list of field names, each of which may be prefixed with a '-' to sort in descending order.
+// `cursor` is a token from a previous page, and can't be combined with a different `sort` or `includeRemoved` value.
+func parseListRequest(values url.Values, sortFields map[string]db.Field, secret []byte) (listRequest, error) {
+	request := listRequest{pageSize: defaultPageSize}
+
+	if rawPageSize := values.Get("pageSize"); rawPageSize != "" {
+		pageSize, err := strconv.Atoi(rawPageSize)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return listRequest{}, fmt.Errorf("%w: pageSize must be between 1 and %d", errInvalidListRequest, maxPageSize)
+		}
+		request.pageSize = pageSize
+	}
+
+	if rawIncludeRemoved := values.Get("includeRemoved"); rawIncludeRemoved != "" {
+		includeRemoved, err := strconv.ParseBool(rawIncludeRemoved)
+		if err != nil {
+			return listRequest{}, fmt.Errorf("%w: includeRemoved must be a boolean", errInvalidListRequest)
+		}
+		request.includeRemoved = includeRemoved
+	}
+
+	request.sortSpec = values.Get("sort")
+	if token := values.Get("cursor"); token != "" {
+		c, err := decodeCursor(secret, token)
+		if err != nil {
+			return listRequest{}, fmt.Errorf("%w: %w", errInvalidListRequest, err)
+		}
+		if values.Has("sort") && request.sortSpec != c.Sort {
+			return listRequest{}, fmt.Errorf("%w: sort does not match the cursor", errInvalidListRequest)
+		}
+		if values.Has("includeRemoved") && request.includeRemoved != c.IncludeRemoved {
+			return listRequest{}, fmt.Errorf("%w: includeRemoved does not match the cursor", errInvalidListRequest)
+		}
+		request.sortSpec = c.Sort
+		request.includeRemoved = c.IncludeRemoved
+		request.cursor = &c
+	}
+
+	if request.sortSpec != "" {
+		for _, name := range strings.Split(request.sortSpec, ",") {
+			descending := strings.HasPrefix(name, "-")
+			field, ok := sortFields[strings.TrimPrefix(name, "-")]
+			if !ok {
+				return listRequest{}, fmt.Errorf("%w: can't sort on %q", errInvalidListRequest, name)
+			}
+			request.sort = append(request.sort, db.Sort{Field: field, Descending: descending})
+		}
+	}
+
+	return request, nil
+}
+
+// query builds the db.Query that fetches the requested page. One more item than the page size
+// is requested so that it can be determined whether there is another page after it.
+// fieldOf returns the value of a field, and key the key, of a zero value of the entity.
+func (lr listRequest) query(fieldOf func(db.Field) (any, bool), key any) (db.Query, error) {
+	query := db.Query{
+		IncludeRemoved: lr.includeRemoved,
+		Sort:           lr.sort,
+		Limit:          lr.pageSize + 1,
+	}
+	if lr.cursor == nil {
+		return query, nil
+	}
+
+	if len(lr.cursor.Position) != len(lr.sort)+1 {
+		return db.Query{}, fmt.Errorf("%w: %w", errInvalidListRequest, errInvalidCursor)
+	}
+	for i, raw := range lr.cursor.Position {
+		example := key
+		if i < len(lr.sort) {
+			example, _ = fieldOf(lr.sort[i].Field)
+		}
+
+		value, err := parsePositionValue(example, raw)
+		if err != nil {
+			return db.Query{}, fmt.Errorf("%w: %w", errInvalidListRequest, errInvalidCursor)
+		}
+		query.After = append(query.After, value)
+	}
+
+	// Paging backward is done by walking the list in the opposite order, and then reversing the results
+	query.Backward = lr.cursor.Backward
+
+	return query, nil
+}
+
+// listPage trims the results of the query built by lr.query to the requested page, and creates the
+// cursors for the pages around it. position returns the sort field values and key of an item.
+func listPage[T db.Entity](lr listRequest, results *db.Page[T], position func(item *T) []any, secret []byte) (pageResponse[T], error) {
+	items := results.Items
+	hasMore := len(items) > lr.pageSize
+	if hasMore {
+		items = items[:lr.pageSize]
+	}
+
+	backward := lr.cursor != nil && lr.cursor.Backward
+	if backward {
+		items = slices.Clone(items)
+		slices.Reverse(items)
+	}
+
+	// Walking forward there is a previous page if we started from a cursor, and walking backward there
+	// is always a next page. Otherwise, the extra item that was requested tells if there is more.
+	hasNext, hasPrev := hasMore, lr.cursor != nil
+	if backward {
+		hasNext, hasPrev = true, hasMore
+	}
+
+	page := pageResponse[T]{Items: items, Total: results.Total}
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	var err error
+	if hasNext {
+		if page.Next, err = lr.cursorAt(position(&items[len(items)-1]), false, secret); err != nil {
+			return pageResponse[T]{}, err
+		}
+	}
+	if hasPrev {
+		if page.Prev, err = lr.cursorAt(position(&items[0]), true, secret); err != nil {
+			return pageResponse[T]{}, err
+		}
+	}
+
+	return page, nil
+}
+
+// cursorAt creates a cursor token for the given position
+func (lr listRequest) cursorAt(position []any, backward bool, secret []byte) (string, error) {
+	c := cursor{
+		Backward:       backward,
+		Sort:           lr.sortSpec,
+		IncludeRemoved: lr.includeRemoved,
+	}
+	for _, value := range position {
+		c.Position = append(c.Position, formatPositionValue(value))
+	}
+
+	return encodeCursor(secret, c)
+}
@@ -0,0 +1,410 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// mergePatchType is the media type of a JSON Merge Patch (RFC 7396)
+	mergePatchType = "application/merge-patch+json"
+	// jsonPatchType is the media type of a JSON Patch (RFC 6902)
+	jsonPatchType = "application/json-patch+json"
+)
+
+var (
+	// errInvalidPatch indicates that a patch is malformed, such as an operation without a path
+	errInvalidPatch = errors.New("invalid patch")
+	// errPatchTestFailed indicates that a test operation of a JSON Patch didn't match the item it was applied to
+	errPatchTestFailed = errors.New("patch test failed")
+	// errUnprocessablePatch indicates that a well-formed patch can't be applied to an item, because it refers to
+	// a member that doesn't exist, changes a read-only field, or results in an invalid item
+	errUnprocessablePatch = errors.New("patch can't be applied")
+)
+
+// patch is a change to a JSON document, which has been decoded with json.Decoder.UseNumber
+type patch func(document any) (any, error)
+
+// parsePatch reads the patch in the body of a request, in any of the formats listed in patchTypes.
+// It returns errInvalidPatch if the patch can't be decoded, and nil along with a nil error if the Content-Type
+// of the request is not a patch format.
+func parsePatch(r *http.Request) (patch, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil
+	}
+
+	switch mediaType {
+	case mergePatchType:
+		var mergePatch any
+		if err := decodeJSON(r.Body, &mergePatch); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPatch, err)
+		}
+		return func(document any) (any, error) {
+			return applyMergePatch(document, mergePatch), nil
+		}, nil
+	case jsonPatchType:
+		var operations []patchOperation
+		if err := decodeJSON(r.Body, &operations); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPatch, err)
+		}
+		return func(document any) (any, error) {
+			return applyJSONPatch(document, operations)
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// patchTypes lists the media types parsePatch understands, as they are advertised in the Accept-Patch header
+const patchTypes = mergePatchType + ", " + jsonPatchType
+
+// decodeJSON decodes a JSON document, keeping numbers as json.Number so that they are compared exactly
+func decodeJSON(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// toDocument converts v to the generic form of its JSON representation, which patches are applied to
+func toDocument(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var document any
+	err = decodeJSON(bytes.NewReader(data), &document)
+	return document, err
+}
+
+// fromDocument converts a generic JSON document back into v. Members that v doesn't have are an error.
+func fromDocument(document any, v any) error {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// applyMergePatch applies a JSON Merge Patch to document, as described by RFC 7396. The objects of document
+// that are changed are copied, so that document itself is left as it is.
+func applyMergePatch(document, mergePatch any) any {
+	patchObject, ok := mergePatch.(map[string]any)
+	if !ok {
+		return mergePatch
+	}
+
+	original, _ := document.(map[string]any)
+	object := make(map[string]any, len(original))
+	for key, value := range original {
+		object[key] = value
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(object, key)
+			continue
+		}
+		object[key] = applyMergePatch(object[key], value)
+	}
+
+	return object
+}
+
+// patchOperation is a single operation of a JSON Patch
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies the operations of a JSON Patch to document in order, as described by RFC 6902.
+// The patch is applied as a whole or not at all, since document is only changed if every operation succeeds.
+func applyJSONPatch(document any, operations []patchOperation) (any, error) {
+	document, err := deepCopy(document)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		document, err = operation.apply(document)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return document, nil
+}
+
+func (o patchOperation) apply(document any) (any, error) {
+	if o.Path == nil {
+		return nil, fmt.Errorf("%w: %q operation has no path", errInvalidPatch, o.Op)
+	}
+	path, err := parsePointer(*o.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.Op {
+	case "add", "replace", "test":
+		if o.Value == nil {
+			return nil, fmt.Errorf("%w: %q operation has no value", errInvalidPatch, o.Op)
+		}
+		var value any
+		if err := decodeJSON(bytes.NewReader(*o.Value), &value); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidPatch, err)
+		}
+
+		switch o.Op {
+		case "add":
+			return addValue(document, path, value)
+		case "replace":
+			if _, err := getValue(document, path); err != nil {
+				return nil, err
+			}
+			return setValue(document, path, value)
+		default:
+			current, err := getValue(document, path)
+			if err != nil {
+				return nil, err
+			}
+			if !jsonEqual(current, value) {
+				return nil, fmt.Errorf("%w: value at %q differs", errPatchTestFailed, *o.Path)
+			}
+			return document, nil
+		}
+	case "remove":
+		return removeValue(document, path)
+	case "move", "copy":
+		if o.From == nil {
+			return nil, fmt.Errorf("%w: %q operation has no from", errInvalidPatch, o.Op)
+		}
+		from, err := parsePointer(*o.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := getValue(document, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if o.Op == "move" {
+			if len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+				return nil, fmt.Errorf("%w: can't move %q into itself", errUnprocessablePatch, *o.From)
+			}
+			if document, err = removeValue(document, from); err != nil {
+				return nil, err
+			}
+		} else if value, err = deepCopy(value); err != nil {
+			return nil, err
+		}
+		return addValue(document, path, value)
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", errInvalidPatch, o.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into the keys it refers to
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q doesn't start with a slash", errInvalidPatch, pointer)
+	}
+
+	keys := strings.Split(pointer[1:], "/")
+	for i := range keys {
+		keys[i] = strings.ReplaceAll(strings.ReplaceAll(keys[i], "~1", "/"), "~0", "~")
+	}
+	return keys, nil
+}
+
+// arrayIndex parses the key of an element of an array with the given length. The key "-" refers to the end of
+// the array, which is only valid if end is true.
+func arrayIndex(key string, length int, end bool) (int, error) {
+	if key == "-" && end {
+		return length, nil
+	}
+
+	index, err := strconv.Atoi(key)
+	if err != nil || index < 0 || (key != "0" && strings.HasPrefix(key, "0")) {
+		return 0, fmt.Errorf("%w: invalid array index %q", errUnprocessablePatch, key)
+	}
+	if index > length || (index == length && !end) {
+		return 0, fmt.Errorf("%w: array index %d is out of bounds", errUnprocessablePatch, index)
+	}
+	return index, nil
+}
+
+// getValue returns the value at path
+func getValue(document any, path []string) (any, error) {
+	for _, key := range path {
+		switch container := document.(type) {
+		case map[string]any:
+			value, ok := container[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q doesn't exist", errUnprocessablePatch, key)
+			}
+			document = value
+		case []any:
+			index, err := arrayIndex(key, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			document = container[index]
+		default:
+			return nil, fmt.Errorf("%w: %q is not in an object or array", errUnprocessablePatch, key)
+		}
+	}
+
+	return document, nil
+}
+
+// addValue adds value at path, replacing the member of an object or inserting into an array,
+// and returns the changed document
+func addValue(document any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := getValue(document, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	key := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case map[string]any:
+		container[key] = value
+		return document, nil
+	case []any:
+		index, err := arrayIndex(key, len(container), true)
+		if err != nil {
+			return nil, err
+		}
+		container = append(container[:index:index], append([]any{value}, container[index:]...)...)
+		return setValue(document, path[:len(path)-1], container)
+	default:
+		return nil, fmt.Errorf("%w: %q is not in an object or array", errUnprocessablePatch, key)
+	}
+}
+
+// setValue replaces the existing value at path, and returns the changed document
+func setValue(document any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := getValue(document, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	key := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case map[string]any:
+		container[key] = value
+	case []any:
+		index, err := arrayIndex(key, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = value
+	}
+	return document, nil
+}
+
+// removeValue removes the value at path, and returns the changed document
+func removeValue(document any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: the whole document can't be removed", errUnprocessablePatch)
+	}
+
+	parent, err := getValue(document, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	key := path[len(path)-1]
+
+	switch container := parent.(type) {
+	case map[string]any:
+		if _, ok := container[key]; !ok {
+			return nil, fmt.Errorf("%w: member %q doesn't exist", errUnprocessablePatch, key)
+		}
+		delete(container, key)
+		return document, nil
+	case []any:
+		index, err := arrayIndex(key, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		container = append(container[:index:index], container[index+1:]...)
+		return setValue(document, path[:len(path)-1], container)
+	default:
+		return nil, fmt.Errorf("%w: %q is not in an object or array", errUnprocessablePatch, key)
+	}
+}
+
+// deepCopy copies a generic JSON document, so that changing the copy leaves the original as it is
+func deepCopy(document any) (any, error) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var result any
+	err = decodeJSON(bytes.NewReader(data), &result)
+	return result, err
+}
+
+// jsonEqual reports whether two generic JSON documents are equal, comparing numbers by their value
+func jsonEqual(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
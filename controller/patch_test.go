@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyJSONPatch(t *testing.T) {
+	// The examples of RFC 6902, appendix A
+	tests := []struct {
+		name     string
+		document string
+		patch    string
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "Add Member",
+			document: `{"foo": "bar"}`,
+			patch:    `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:     `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:     "Add Array Element",
+			document: `{"foo": ["bar", "baz"]}`,
+			patch:    `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:     `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			name:     "Append Array Element",
+			document: `{"foo": ["bar"]}`,
+			patch:    `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:     `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+		{
+			name:     "Remove Member",
+			document: `{"baz": "qux", "foo": "bar"}`,
+			patch:    `[{"op": "remove", "path": "/baz"}]`,
+			want:     `{"foo": "bar"}`,
+		},
+		{
+			name:     "Remove Array Element",
+			document: `{"foo": ["bar", "qux", "baz"]}`,
+			patch:    `[{"op": "remove", "path": "/foo/1"}]`,
+			want:     `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:     "Replace",
+			document: `{"baz": "qux", "foo": "bar"}`,
+			patch:    `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:     `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name:     "Move Member",
+			document: `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch:    `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:     `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			name:     "Move Array Element",
+			document: `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch:    `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:     `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			name:     "Copy",
+			document: `{"foo": {"bar": 1}}`,
+			patch:    `[{"op": "copy", "from": "/foo", "path": "/baz"}, {"op": "replace", "path": "/baz/bar", "value": 2}]`,
+			want:     `{"foo": {"bar": 1}, "baz": {"bar": 2}}`,
+		},
+		{
+			name:     "Test",
+			document: `{"baz": "qux", "foo": ["a", 2, "c"], "n": 10}`,
+			patch:    `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}, {"op": "test", "path": "/n", "value": 1e1}]`,
+			want:     `{"baz": "qux", "foo": ["a", 2, "c"], "n": 10}`,
+		},
+		{
+			name:     "Test Failed",
+			document: `{"baz": "qux"}`,
+			patch:    `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			wantErr:  errPatchTestFailed,
+		},
+		{
+			name:     "Escaped Path",
+			document: `{"/": 9, "~1": 10}`,
+			patch:    `[{"op": "test", "path": "/~01", "value": 10}, {"op": "remove", "path": "/~1"}]`,
+			want:     `{"~1": 10}`,
+		},
+		{
+			name:     "Add to Nonexistent Target",
+			document: `{"foo": "bar"}`,
+			patch:    `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			wantErr:  errUnprocessablePatch,
+		},
+		{
+			name:     "Array Index Out of Bounds",
+			document: `{"foo": ["bar"]}`,
+			patch:    `[{"op": "add", "path": "/foo/2", "value": "qux"}]`,
+			wantErr:  errUnprocessablePatch,
+		},
+		{
+			name:     "Move Into Itself",
+			document: `{"foo": {"bar": 1}}`,
+			patch:    `[{"op": "move", "from": "/foo", "path": "/foo/baz"}]`,
+			wantErr:  errUnprocessablePatch,
+		},
+		{
+			name:     "Missing Value",
+			document: `{"foo": "bar"}`,
+			patch:    `[{"op": "add", "path": "/baz"}]`,
+			wantErr:  errInvalidPatch,
+		},
+		{
+			name:     "Invalid Path",
+			document: `{"foo": "bar"}`,
+			patch:    `[{"op": "remove", "path": "foo"}]`,
+			wantErr:  errInvalidPatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var document any
+			if err := decodeJSON(strings.NewReader(tt.document), &document); err != nil {
+				t.Fatalf("failed to decode document: %v", err)
+			}
+			var operations []patchOperation
+			if err := json.Unmarshal([]byte(tt.patch), &operations); err != nil {
+				t.Fatalf("failed to decode patch: %v", err)
+			}
+			original, _ := json.Marshal(document)
+
+			got, err := applyJSONPatch(document, operations)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else if assert.NoError(t, err) {
+				gotJSON, _ := json.Marshal(got)
+				assert.JSONEq(t, tt.want, string(gotJSON))
+			}
+
+			// The document that was patched is left as it was
+			after, _ := json.Marshal(document)
+			assert.JSONEq(t, string(original), string(after))
+		})
+	}
+}
+
+func Test_applyMergePatch(t *testing.T) {
+	// The examples of RFC 7396, appendix A
+	tests := []struct {
+		document, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			var document, patch any
+			assert.NoError(t, decodeJSON(strings.NewReader(tt.document), &document))
+			assert.NoError(t, decodeJSON(strings.NewReader(tt.patch), &patch))
+
+			original, _ := json.Marshal(document)
+			got, _ := json.Marshal(applyMergePatch(document, patch))
+			assert.JSONEq(t, tt.want, string(got))
+
+			after, _ := json.Marshal(document)
+			assert.JSONEq(t, string(original), string(after))
+		})
+	}
+}
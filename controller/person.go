@@ -1,173 +1,931 @@
 package controller
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/williabk198/go-api-server-template/auth"
 	"github.com/williabk198/go-api-server-template/db"
 )
 
+// personSortFields maps the names clients use to sort lists of people to the fields of db.Person
+var personSortFields = map[string]db.Field{
+	"id":        db.PersonID,
+	"firstName": db.PersonFirstName,
+	"lastName":  db.PersonLastName,
+	"dob":       db.PersonDateOfBirth,
+}
+
+// personFieldNames maps the fields of db.Person to the names clients know them by
+var personFieldNames = map[db.Field]string{
+	db.PersonID:          "id",
+	db.PersonFirstName:   "firstName",
+	db.PersonLastName:    "lastName",
+	db.PersonDateOfBirth: "dob",
+	db.PersonRemoved:     "removed",
+}
+
 type personDataHandler struct {
+	database        db.Database
 	personDatastore db.Datastore[db.Person, uuid.UUID]
+	personHistory   db.HistoryStore[db.Person, uuid.UUID]
+	personFeed      db.ChangeFeed[db.Person, uuid.UUID]
 	logger          *slog.Logger
+	cursorSecret    []byte
+	shutdown        context.Context
+	heartbeat       time.Duration
+	socketBuffer    int
+	streams         *sync.WaitGroup
+	errorFormat     ErrorFormat
+	clock           db.Clock
+}
+
+// now returns the current time according to the clock of the handler, or the system clock if it has none
+func (pdh personDataHandler) now() time.Time {
+	if pdh.clock == nil {
+		return time.Now()
+	}
+	return pdh.clock()
 }
 
 func (pdh personDataHandler) Add(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	jsonEncoder := json.NewEncoder(w)
+	encoder, ok := negotiate(w, r, pdh.errorFormat, itemCodecs)
+	if !ok {
+		return
+	}
 
 	var person person
-	err := json.NewDecoder(r.Body).Decode(&person)
+	err := decodeRequest(r, &person)
 	if err != nil {
-		pdh.logger.Error("failed to parse JSON request", "error", err)
-		sendErrorResponse(w, http.StatusBadRequest, jsonEncoder)
+		pdh.logger.Error("failed to parse request", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, statusForError(err), encoder)
 		return
 	}
 
-	dbPerson, err := person.asDatabaseModel()
+	dbPerson, err := person.asDatabaseModel(pdh.now())
 	if err != nil {
-		pdh.logger.Error("failed to read request data", "error", err)
-		sendErrorResponse(w, http.StatusUnprocessableEntity, jsonEncoder)
+		pdh.sendError(w, r, "failed to read request data", err, encoder)
 		return
 	}
 
 	err = pdh.personDatastore.Insert(ctx, dbPerson)
 	if err != nil {
-		pdh.logger.Error("failed to insert user into database", "error", err)
-		sendErrorResponse(w, http.StatusInternalServerError, jsonEncoder)
+		pdh.sendError(w, r, "failed to insert user into database", err, encoder)
 		return
 	}
 
+	w.Header().Set("ETag", entityTag(dbPerson.Version))
 	respData := pdh.personFromDatabaseModel(dbPerson)
-	sendDataResponse(respData, jsonEncoder)
+	sendDataResponse(respData, encoder)
+}
+
+func (pdh personDataHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	encoder, ok := negotiate(w, r, pdh.errorFormat, listCodecs)
+	if !ok {
+		return
+	}
+
+	listReq, err := parseListRequest(r.URL.Query(), personSortFields, pdh.cursorSecret)
+	if err != nil {
+		pdh.sendError(w, r, "failed to parse list request", err, encoder)
+		return
+	}
+
+	query, err := listReq.query(db.Person{}.Field, uuid.Nil)
+	if err != nil {
+		pdh.sendError(w, r, "failed to build query from list request", err, encoder)
+		return
+	}
+
+	results, err := pdh.personDatastore.Query(ctx, query)
+	if err != nil {
+		pdh.sendError(w, r, "failed to query people from database", err, encoder)
+		return
+	}
+
+	page, err := listPage(listReq, results, func(item *db.Person) []any {
+		position := make([]any, 0, len(listReq.sort)+1)
+		for _, sort := range listReq.sort {
+			value, _ := item.Field(sort.Field)
+			position = append(position, value)
+		}
+		return append(position, item.ID)
+	}, pdh.cursorSecret)
+	if err != nil {
+		pdh.sendError(w, r, "failed to create page cursors", err, encoder)
+		return
+	}
+
+	respData := pageResponse[person]{
+		Items: make([]person, 0, len(page.Items)),
+		Total: page.Total,
+		Next:  page.Next,
+		Prev:  page.Prev,
+	}
+	for i := range page.Items {
+		respData.Items = append(respData.Items, pdh.personFromDatabaseModel(&page.Items[i]))
+	}
+	sendDataResponse(respData, encoder)
 }
 
 func (pdh personDataHandler) GetSpecific(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	jsonEncoder := json.NewEncoder(w)
+	encoder, ok := negotiate(w, r, pdh.errorFormat, itemCodecs)
+	if !ok {
+		return
+	}
 	personID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
 		pdh.logger.Error("failed to parse UUID from URL parameter", "error", err)
-		sendErrorResponse(w, http.StatusNotFound, jsonEncoder)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
 		return
 	}
 
 	dbPerson, err := pdh.personDatastore.Get(ctx, personID)
 	if err != nil {
-		if errors.Is(err, db.ErrNoResultsFound) {
-			sendErrorResponse(w, http.StatusNotFound, jsonEncoder)
-			return
-		}
-		pdh.logger.Error("failed to get user from database", "error", err)
-		sendErrorResponse(w, http.StatusInternalServerError, jsonEncoder)
+		pdh.sendError(w, r, "failed to get user from database", err, encoder)
 		return
 	}
 
+	w.Header().Set("ETag", entityTag(dbPerson.Version))
 	respData := pdh.personFromDatabaseModel(dbPerson)
-	sendDataResponse(respData, jsonEncoder)
+	sendDataResponse(respData, encoder)
 }
 
 func (pdh personDataHandler) Remove(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	jsonEncoder := json.NewEncoder(w)
+	encoder, ok := negotiate(w, r, pdh.errorFormat, itemCodecs)
+	if !ok {
+		return
+	}
+	personID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		pdh.logger.Error("failed to parse UUID from URL parameter", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
+		return
+	}
+
+	precondition := parseIfMatch(r)
+	if precondition.present {
+		// The current version has to be checked in the same transaction as the removal, so that it can't change in between
+		err = pdh.database.WithTx(ctx, func(tx db.Database) error {
+			current, err := tx.Person().Get(ctx, personID)
+			if err != nil {
+				return err
+			}
+			if !precondition.matches(current.Version) {
+				return errPreconditionFailed
+			}
+			_, err = tx.Person().Remove(ctx, personID)
+			return err
+		})
+	} else {
+		_, err = pdh.personDatastore.Remove(ctx, personID)
+	}
+	if err != nil {
+		pdh.sendError(w, r, "failed to remove user from database", err, encoder)
+		return
+	}
+
+	encoder.Encode(baseResponse{Success: true})
+}
+
+// Restore brings back a removed person
+func (pdh personDataHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	encoder, ok := negotiate(w, r, pdh.errorFormat, itemCodecs)
+	if !ok {
+		return
+	}
 	personID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
 		pdh.logger.Error("failed to parse UUID from URL parameter", "error", err)
-		sendErrorResponse(w, http.StatusNotFound, jsonEncoder)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
 		return
 	}
 
-	_, err = pdh.personDatastore.Remove(ctx, personID)
+	dbPerson, err := pdh.personDatastore.Restore(ctx, personID)
 	if err != nil {
-		if errors.Is(err, db.ErrNoResultsFound) {
-			sendErrorResponse(w, http.StatusNotFound, jsonEncoder)
-			return
-		}
-		pdh.logger.Error("failed to get user from database", "error", err)
-		sendErrorResponse(w, http.StatusInternalServerError, jsonEncoder)
+		pdh.sendError(w, r, "failed to restore user in database", err, encoder)
 		return
 	}
 
-	jsonEncoder.Encode(baseResponse{Success: true})
+	w.Header().Set("ETag", entityTag(dbPerson.Version))
+	respData := pdh.personFromDatabaseModel(dbPerson)
+	sendDataResponse(respData, encoder)
+}
+
+// Purge permanently deletes a removed person. Only admins are allowed to do this.
+func (pdh personDataHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	encoder, ok := negotiate(w, r, pdh.errorFormat, itemCodecs)
+	if !ok {
+		return
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusUnauthorized, encoder)
+		return
+	}
+	if !principal.Admin {
+		pdh.logger.Warn("non-admin attempted to purge a user", "principal", principal.Name)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusForbidden, encoder)
+		return
+	}
+
+	personID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		pdh.logger.Error("failed to parse UUID from URL parameter", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
+		return
+	}
+
+	err = pdh.personDatastore.Purge(ctx, personID)
+	if err != nil {
+		pdh.sendError(w, r, "failed to purge user from database", err, encoder)
+		return
+	}
+
+	encoder.Encode(baseResponse{Success: true})
 }
 
 func (pdh personDataHandler) Update(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	jsonEncoder := json.NewEncoder(w)
+	encoder, ok := negotiate(w, r, pdh.errorFormat, itemCodecs)
+	if !ok {
+		return
+	}
+	personID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		pdh.logger.Error("failed to parse UUID from URL parameter", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
+		return
+	}
+
 	var person person
-	err := json.NewDecoder(r.Body).Decode(&person)
+	err = decodeRequest(r, &person)
 	if err != nil {
-		pdh.logger.Error("failed to parse JSON request", "error", err)
-		sendErrorResponse(w, http.StatusBadRequest, jsonEncoder)
+		pdh.logger.Error("failed to parse request", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, statusForError(err), encoder)
 		return
 	}
 
-	dbPerson, err := person.asDatabaseModel()
+	dbPerson, err := person.asDatabaseModel(pdh.now())
 	if err != nil {
-		pdh.logger.Error("failed to read request data", "error", err)
-		sendErrorResponse(w, http.StatusUnprocessableEntity, jsonEncoder)
+		pdh.sendError(w, r, "failed to read request data", err, encoder)
 		return
 	}
+	// The URL decides which person is updated. The body may leave out the ID, but must not name someone else.
+	if dbPerson.ID != uuid.Nil && dbPerson.ID != personID {
+		mismatch := validationError{{Field: "id", Code: codeReadOnly, Message: "must match the ID in the URL"}}
+		pdh.sendError(w, r, "failed to read request data", mismatch, encoder)
+		return
+	}
+	dbPerson.ID = personID
+
+	// When the client sent If-Match, the update must be based on one of the versions it lists.
+	// Passing that version on to the datastore makes sure it doesn't change before the update is made.
+	if precondition := parseIfMatch(r); precondition.present {
+		current, err := pdh.personDatastore.Get(ctx, personID)
+		if err != nil {
+			pdh.sendError(w, r, "failed to get user from database", err, encoder)
+			return
+		}
+		if !precondition.matches(current.Version) {
+			sendErrorResponse(w, r, pdh.errorFormat, http.StatusPreconditionFailed, encoder)
+			return
+		}
+		dbPerson.Version = current.Version
+	}
 
 	err = pdh.personDatastore.Update(ctx, dbPerson)
 	if err != nil {
-		if errors.Is(err, db.ErrNoResultsFound) {
-			sendErrorResponse(w, http.StatusNotFound, jsonEncoder)
-			return
+		pdh.sendError(w, r, "failed to update user in database", err, encoder)
+		return
+	}
+
+	w.Header().Set("ETag", entityTag(dbPerson.Version))
+	respData := pdh.personFromDatabaseModel(dbPerson)
+	sendDataResponse(respData, encoder)
+}
+
+// personReadOnlyFields are the members of a person that a patch must not change
+var personReadOnlyFields = []string{"id", "removed", "createdAt", "updatedAt", "removedAt"}
+
+// Patch changes some fields of a person, using a JSON Merge Patch or a JSON Patch depending on the Content-Type.
+// The patch is applied to the person as it would be sent in an update, and may not change its read-only fields.
+func (pdh personDataHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	encoder, ok := negotiate(w, r, pdh.errorFormat, itemCodecs)
+	if !ok {
+		return
+	}
+	personID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		pdh.logger.Error("failed to parse UUID from URL parameter", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
+		return
+	}
+
+	apply, err := parsePatch(r)
+	if err != nil {
+		pdh.logger.Error("failed to parse patch", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusBadRequest, encoder)
+		return
+	}
+	if apply == nil {
+		w.Header().Set("Accept-Patch", patchTypes)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusUnsupportedMediaType, encoder)
+		return
+	}
+
+	// The patch has to be applied in the same transaction as the update, so that the person can't change in between
+	precondition := parseIfMatch(r)
+	var dbPerson *db.Person
+	err = pdh.database.WithTx(ctx, func(tx db.Database) error {
+		current, err := tx.Person().Get(ctx, personID)
+		if err != nil {
+			return err
+		}
+		if !precondition.matches(current.Version) {
+			return errPreconditionFailed
+		}
+
+		dbPerson, err = pdh.patchPerson(current, apply)
+		if err != nil {
+			return err
+		}
+		return tx.Person().Update(ctx, dbPerson)
+	})
+	if err != nil {
+		pdh.sendError(w, r, "failed to patch user in database", err, encoder)
+		return
+	}
+
+	w.Header().Set("ETag", entityTag(dbPerson.Version))
+	respData := pdh.personFromDatabaseModel(dbPerson)
+	sendDataResponse(respData, encoder)
+}
+
+// patchPerson applies a patch to a person, and returns the result, ready to be passed to the datastore
+func (pdh personDataHandler) patchPerson(current *db.Person, apply patch) (*db.Person, error) {
+	original := pdh.personFromDatabaseModel(current)
+	original.DateOfBirth = "" // Patches use the same date format as requests
+	if !current.DateOfBirth.IsZero() {
+		original.DateOfBirth = current.DateOfBirth.Format(requestDateFormat)
+	}
+
+	document, err := toDocument(original)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := apply(document)
+	if err != nil {
+		return nil, err
+	}
+
+	originalObject := document.(map[string]any)
+	patchedObject, ok := patched.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: the result is not an object", errUnprocessablePatch)
+	}
+	var v validator
+	for _, field := range personReadOnlyFields {
+		value, ok := patchedObject[field]
+		originalValue, originalOK := originalObject[field]
+		if ok != originalOK || !jsonEqual(value, originalValue) {
+			v.add(field, codeReadOnly, "is read-only")
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errUnprocessablePatch, err)
+	}
+
+	var result person
+	if err := fromDocument(patched, &result); err != nil {
+		return nil, fmt.Errorf("%w: %w", errUnprocessablePatch, err)
+	}
+	dbPerson, err := result.asDatabaseModel(pdh.now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errUnprocessablePatch, err)
+	}
+
+	dbPerson.Removed = current.Removed
+	dbPerson.Version = current.Version
+	return dbPerson, nil
+}
+
+// History lists the changes that were made to a person, oldest first
+func (pdh personDataHandler) History(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	encoder, ok := negotiate(w, r, pdh.errorFormat, listCodecs)
+	if !ok {
+		return
+	}
+	personID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		pdh.logger.Error("failed to parse UUID from URL parameter", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
+		return
+	}
+
+	entries, err := pdh.personHistory.List(ctx, personID)
+	if err != nil {
+		pdh.sendError(w, r, "failed to get user history from database", err, encoder)
+		return
+	}
+
+	respData := make([]historyEntry[person], 0, len(entries))
+	for i := range entries {
+		respData = append(respData, pdh.historyEntryFromDatabaseModel(&entries[i]))
+	}
+	sendDataResponse(respData, encoder)
+}
+
+// Revert changes a person back to how it was at the given version of its history. This is recorded as a new change,
+// so the version of the person is incremented as usual.
+func (pdh personDataHandler) Revert(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	encoder, ok := negotiate(w, r, pdh.errorFormat, itemCodecs)
+	if !ok {
+		return
+	}
+	personID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		pdh.logger.Error("failed to parse UUID from URL parameter", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		pdh.logger.Error("failed to parse version from URL parameter", "error", err)
+		sendErrorResponse(w, r, pdh.errorFormat, http.StatusNotFound, encoder)
+		return
+	}
+
+	precondition := parseIfMatch(r)
+	var dbPerson *db.Person
+	err = pdh.database.WithTx(ctx, func(tx db.Database) error {
+		entry, err := tx.PersonHistory().Get(ctx, personID, version)
+		if err != nil {
+			return err
 		}
-		pdh.logger.Error("failed to get user from database", "error", err)
-		sendErrorResponse(w, http.StatusInternalServerError, jsonEncoder)
+		current, err := tx.Person().Get(ctx, personID)
+		if err != nil {
+			return err
+		}
+		if precondition.present && !precondition.matches(current.Version) {
+			return errPreconditionFailed
+		}
+
+		dbPerson = &entry.Item
+		dbPerson.Version = current.Version
+		return tx.Person().Update(ctx, dbPerson)
+	})
+	if err != nil {
+		pdh.sendError(w, r, "failed to revert user in database", err, encoder)
 		return
 	}
 
+	w.Header().Set("ETag", entityTag(dbPerson.Version))
 	respData := pdh.personFromDatabaseModel(dbPerson)
-	sendDataResponse(respData, jsonEncoder)
+	sendDataResponse(respData, encoder)
+}
+
+// Events streams the changes made to people as Server-Sent Events, until the client disconnects or the server shuts
+// down. The stream can be limited to some people by listing their IDs in the id query parameter. The ID of each event
+// is its sequence, which clients pass back in the Last-Event-ID header to resume after the last event they received.
+// If the events since then are no longer known, the stream starts with a reset event, telling the client to reload
+// the people it is interested in.
+func (pdh personDataHandler) Events(w http.ResponseWriter, r *http.Request) {
+	encoder := jsonResponse(w)
+
+	ids := make(map[uuid.UUID]bool)
+	for _, value := range r.URL.Query()["id"] {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			pdh.logger.Error("failed to parse UUID from query parameter", "error", err)
+			sendErrorResponse(w, r, pdh.errorFormat, http.StatusBadRequest, encoder)
+			return
+		}
+		ids[id] = true
+	}
+
+	var opts db.WatchOptions
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		sequence, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			pdh.logger.Error("failed to parse Last-Event-ID header", "error", err)
+			sendErrorResponse(w, r, pdh.errorFormat, http.StatusBadRequest, encoder)
+			return
+		}
+		opts.From = sequence + 1
+	}
+
+	// The stream ends when the client disconnects, or when the server shuts down
+	pdh.streams.Add(1)
+	defer pdh.streams.Done()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stop := context.AfterFunc(pdh.shutdown, cancel)
+	defer stop()
+
+	subscription, err := pdh.personFeed.Watch(ctx, opts)
+	reset := errors.Is(err, db.ErrSequenceExpired)
+	if reset {
+		subscription, err = pdh.personFeed.Watch(ctx, db.WatchOptions{})
+	}
+	if err != nil {
+		pdh.sendError(w, r, "failed to watch people in database", err, encoder)
+		return
+	}
+	defer subscription.Close()
+
+	stream := newEventStream(w)
+	if reset {
+		stream.event("reset", "", struct{}{})
+	}
+	if err := stream.flush(); err != nil {
+		pdh.logger.Error("failed to start event stream", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(pdh.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				if err := subscription.Err(); err != nil && ctx.Err() == nil {
+					pdh.logger.Warn("person event stream ended", "error", err)
+				}
+				return
+			}
+			if len(ids) > 0 && !ids[event.ID] {
+				continue
+			}
+			stream.event(string(event.Change), strconv.FormatUint(event.Sequence, 10), pdh.changeEventFromDatabaseModel(&event))
+		case <-heartbeat.C:
+			stream.comment("heartbeat")
+		}
+
+		if err := stream.flush(); err != nil {
+			return // The client is gone
+		}
+	}
+}
+
+// Socket serves a WebSocket over which clients subscribe to the changes made to some or all people, using the JSON
+// messages described by socketMessage. Unlike Events, changes that were made while the client wasn't connected
+// aren't sent, so clients should load the people they are interested in after subscribing to them.
+func (pdh personDataHandler) Socket(w http.ResponseWriter, r *http.Request) {
+	conn, err := socketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already responded with an error
+		pdh.logger.Error("failed to upgrade to a socket", "error", err)
+		return
+	}
+
+	// The socket is closed when the client disconnects, or when the server shuts down
+	pdh.streams.Add(1)
+	defer pdh.streams.Done()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stop := context.AfterFunc(pdh.shutdown, cancel)
+	defer stop()
+
+	session := &socketSession[db.Person, uuid.UUID, person]{
+		conn:      conn,
+		logger:    pdh.logger,
+		heartbeat: pdh.heartbeat,
+		parseID:   uuid.Parse,
+		convert:   pdh.changeEventFromDatabaseModel,
+		send:      make(chan socketMessage[person], pdh.socketBuffer),
+		ids:       make(map[uuid.UUID]bool),
+	}
+
+	subscription, err := pdh.personFeed.Watch(ctx, db.WatchOptions{})
+	if err != nil {
+		pdh.logger.Error("failed to watch people in database", "error", err)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "events are unavailable"),
+			time.Now().Add(socketWriteTimeout))
+		conn.Close()
+		return
+	}
+	defer subscription.Close()
+
+	session.serve(ctx, subscription)
+}
+
+// personBatchItem is a valid operation of a batch request, ready to be passed to the datastore
+type personBatchItem struct {
+	index  int
+	op     string
+	person *db.Person
+}
+
+// Batch applies a list of inserts, updates and removes of people, and reports the outcome of each of them.
+// Consecutive operations of the same kind are passed to the datastore as a single batch.
+func (pdh personDataHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	encoder, ok := negotiate(w, r, pdh.errorFormat, listCodecs)
+	if !ok {
+		return
+	}
+
+	batchReq, err := parseBatchRequest[person](r)
+	if err != nil {
+		pdh.sendError(w, r, "failed to parse batch request", err, encoder)
+		return
+	}
+
+	results := make([]batchResult[person], len(batchReq.operations))
+	items := make([]personBatchItem, 0, len(batchReq.operations))
+	for i, operation := range batchReq.operations {
+		results[i].Index = i
+		item, statusCode, problems := pdh.parseBatchOperation(operation)
+		if statusCode != 0 {
+			failBatchResult(&results[i], statusCode)
+			results[i].Errors = problems
+			continue
+		}
+		item.index = i
+		items = append(items, item)
+	}
+
+	// run applies the valid operations, in order, to the given datastore
+	run := func(store db.Datastore[db.Person, uuid.UUID]) error {
+		for start := 0; start < len(items); {
+			end := start + 1
+			for end < len(items) && items[end].op == items[start].op {
+				end++
+			}
+			if err := pdh.runBatch(ctx, store, items[start:end], results); err != nil {
+				return err
+			}
+			start = end
+		}
+		return nil
+	}
+
+	switch {
+	case batchReq.atomic && len(items) < len(results): // Some operations are invalid, so none may be applied
+		err = errBatchFailed
+	case batchReq.atomic:
+		err = pdh.database.WithTx(ctx, func(tx db.Database) error {
+			if err := run(tx.Person()); err != nil {
+				return err
+			}
+			for _, result := range results {
+				if result.Status >= 400 {
+					return errBatchFailed
+				}
+			}
+			return nil
+		})
+	default:
+		err = run(pdh.personDatastore)
+	}
+	if errors.Is(err, errBatchFailed) {
+		// Nothing was applied, so the operations that did succeed have to be reported as failed too
+		for i := range results {
+			if results[i].Status < 400 {
+				failBatchResult(&results[i], http.StatusFailedDependency)
+			}
+		}
+		err = nil
+	}
+	if err != nil {
+		pdh.sendError(w, r, "failed to apply batch to database", err, encoder)
+		return
+	}
+
+	sendBatchResponse(w, batchReq.atomic, results, encoder)
+}
+
+// parseBatchOperation checks a single operation of a batch request. It returns the status code to report
+// for the operation if it is invalid, and 0 if it is valid. If the data of the operation is invalid, the problems
+// with it are returned as well.
+func (pdh personDataHandler) parseBatchOperation(operation batchOperation[person]) (personBatchItem, int, validationError) {
+	item := personBatchItem{op: operation.Op, person: &db.Person{}}
+
+	switch operation.Op {
+	case batchInsert, batchUpdate:
+		if operation.Data == nil {
+			return item, http.StatusBadRequest, nil
+		}
+		dbPerson, err := operation.Data.asDatabaseModel(pdh.now())
+		if err != nil {
+			var invalid validationError
+			if errors.As(err, &invalid) {
+				return item, http.StatusUnprocessableEntity, invalid.within("data")
+			}
+			pdh.logger.Error("failed to read batch operation data", "error", err)
+			return item, http.StatusUnprocessableEntity, nil
+		}
+		item.person = dbPerson
+		item.person.ID = uuid.Nil
+	case batchRemove:
+	default:
+		return item, http.StatusBadRequest, nil
+	}
+
+	if operation.Op != batchInsert {
+		id, err := uuid.Parse(operation.ID)
+		if err != nil {
+			return item, http.StatusNotFound, nil
+		}
+		item.person.ID = id
+	}
+
+	return item, 0, nil
+}
+
+// runBatch applies a run of operations of the same kind to the given datastore, and stores their outcome in results.
+// An error is only returned if the batch failed as a whole.
+func (pdh personDataHandler) runBatch(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], items []personBatchItem, results []batchResult[person]) error {
+	people := make([]*db.Person, len(items))
+	for i := range items {
+		people[i] = items[i].person
+	}
+
+	var err error
+	successStatus := http.StatusOK
+	switch items[0].op {
+	case batchInsert:
+		err = store.InsertBatch(ctx, people)
+		successStatus = http.StatusCreated
+	case batchUpdate:
+		err = store.UpdateBatch(ctx, people)
+	case batchRemove:
+		ids := make([]uuid.UUID, len(people))
+		for i := range people {
+			ids[i] = people[i].ID
+		}
+		var removed []*db.Person
+		removed, err = store.RemoveBatch(ctx, ids)
+		for i := range removed {
+			if removed[i] != nil {
+				people[i] = removed[i]
+			}
+		}
+	}
+
+	errs, err := batchErrors(err, len(items))
+	if err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		result := &results[item.index]
+		if errs[i] != nil {
+			statusCode := statusForError(errs[i])
+			if statusCode >= http.StatusInternalServerError {
+				pdh.logger.Error("failed to apply batch operation to database", "op", item.op, "error", errs[i])
+			}
+			failBatchResult(result, statusCode)
+			continue
+		}
+
+		data := pdh.personFromDatabaseModel(people[i])
+		*result = batchResult[person]{Index: item.index, Status: successStatus, ID: data.ID, Data: &data}
+	}
+
+	return nil
+}
+
+// sendError responds to a request that failed with err, using the status code that statusForError picks for it.
+// Only errors that are the fault of the server are logged, along with msg.
+// Invalid request data is sent back along with the problems that were found in it.
+func (pdh personDataHandler) sendError(w http.ResponseWriter, r *http.Request, msg string, err error, encoder responseEncoder) {
+	var invalid validationError
+	if errors.As(err, &invalid) {
+		sendValidationErrorResponse(w, r, pdh.errorFormat, invalid, encoder)
+		return
+	}
+
+	statusCode := statusForError(err)
+	if statusCode >= http.StatusInternalServerError {
+		pdh.logger.Error(msg, "error", err)
+	}
+	sendErrorResponse(w, r, pdh.errorFormat, statusCode, encoder)
+}
+
+func (pdh personDataHandler) historyEntryFromDatabaseModel(entry *db.HistoryEntry[db.Person]) historyEntry[person] {
+	result := historyEntry[person]{
+		Version:   entry.Version,
+		Change:    string(entry.Change),
+		Principal: entry.Principal,
+		Timestamp: formatTimestamp(entry.Timestamp),
+		Data:      pdh.personFromDatabaseModel(&entry.Item),
+		Diff:      make([]fieldChange, 0, len(entry.Diff)),
+	}
+
+	// Values are formatted the same way as they are in a person
+	format := func(value any) any {
+		if date, ok := value.(time.Time); ok {
+			return date.Format(responseDateFormat)
+		}
+		return value
+	}
+	for _, change := range entry.Diff {
+		result.Diff = append(result.Diff, fieldChange{
+			Field: personFieldNames[change.Field],
+			Old:   format(change.Old),
+			New:   format(change.New),
+		})
+	}
+
+	return result
+}
+
+func (pdh personDataHandler) changeEventFromDatabaseModel(event *db.Event[db.Person, uuid.UUID]) changeEvent[person] {
+	result := changeEvent[person]{
+		Change:    string(event.Change),
+		ID:        event.ID.String(),
+		Principal: event.Principal,
+		Timestamp: formatTimestamp(event.Timestamp),
+	}
+	if event.After != nil {
+		data := pdh.personFromDatabaseModel(event.After)
+		result.Data = &data
+	}
+
+	return result
 }
 
 func (pdh personDataHandler) personFromDatabaseModel(dbUser *db.Person) person {
-	return person{
+	result := person{
 		ID:          dbUser.ID.String(),
 		FirstName:   dbUser.FirstName,
 		LastName:    dbUser.LastName,
-		DateOfBirth: dbUser.DateOfBirth.Format("1/2/2006"),
+		DateOfBirth: dbUser.DateOfBirth.Format(responseDateFormat),
 		Removed:     *dbUser.Removed,
+		CreatedAt:   formatTimestamp(dbUser.CreatedAt),
+		UpdatedAt:   formatTimestamp(dbUser.UpdatedAt),
+	}
+	if dbUser.RemovedAt != nil {
+		result.RemovedAt = formatTimestamp(*dbUser.RemovedAt)
 	}
+
+	return result
 }
 
 type person struct {
-	ID          string `json:"id"`
-	FirstName   string `json:"firstName"`
-	LastName    string `json:"lastName"`
-	DateOfBirth string `json:"dob"`
-	Removed     bool   `json:"removed"`
+	ID          string `json:"id" xml:"id"`
+	FirstName   string `json:"firstName" xml:"firstName"`
+	LastName    string `json:"lastName" xml:"lastName"`
+	DateOfBirth string `json:"dob" xml:"dob"`
+	Removed     bool   `json:"removed" xml:"removed"`
+
+	// The timestamps are managed by the datastore. They are read-only, so asDatabaseModel ignores them.
+	CreatedAt string `json:"createdAt,omitempty" xml:"createdAt,omitempty"`
+	UpdatedAt string `json:"updatedAt,omitempty" xml:"updatedAt,omitempty"`
+	RemovedAt string `json:"removedAt,omitempty" xml:"removedAt,omitempty"`
 }
 
-func (p person) asDatabaseModel() (*db.Person, error) {
-	var dateOfBirth time.Time
-	// var removed bool
-	var err error
+const (
+	// maxNameLength is the maximum number of characters in the first or last name of a person
+	maxNameLength = 100
+)
 
-	id := uuid.Nil
-	if p.ID != "" {
-		id, err = uuid.Parse(p.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse field 'id': %w", err)
-		}
-	}
+// earliestDateOfBirth is the earliest date of birth a person may have
+var earliestDateOfBirth = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	if p.DateOfBirth != "" {
-		dateOfBirth, err = time.Parse(requestDateFormat, p.DateOfBirth)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse field 'dob': %w", err)
-		}
+// validate checks the fields of a person that clients may set, and returns a validationError listing every problem with them.
+// now is the current time, after which no one can be born.
+func (p person) validate(now time.Time) error {
+	var v validator
+	check(&v, "id", p.ID, uuidFormat())
+	check(&v, "firstName", p.FirstName, required[string](), maxLength(maxNameLength), printable())
+	check(&v, "lastName", p.LastName, required[string](), maxLength(maxNameLength), printable())
+	check(&v, "dob", p.DateOfBirth, dateFormat(requestDateFormat), dateRange(requestDateFormat, earliestDateOfBirth, now))
+	return v.err()
+}
+
+// asDatabaseModel validates p, as of the given time, and converts it to a db.Person
+func (p person) asDatabaseModel(now time.Time) (*db.Person, error) {
+	if err := p.validate(now); err != nil {
+		return nil, err
 	}
 
+	// Both fields are optional, and validate made sure that they can be parsed if set.
+	// Empty ones fail to parse, which leaves them at uuid.Nil and the zero time.
+	id, _ := uuid.Parse(p.ID)
+	dateOfBirth, _ := time.Parse(requestDateFormat, p.DateOfBirth)
+
 	return &db.Person{
 		ID:          id,
 		FirstName:   p.FirstName,
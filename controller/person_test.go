@@ -1,19 +1,27 @@
 package controller
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/williabk198/go-api-server-template/auth"
 	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/memdb"
 )
 
 func Test_personDataHandler_Add(t *testing.T) {
@@ -35,6 +43,8 @@ func Test_personDataHandler_Add(t *testing.T) {
 		// So, we need to replicate that here.
 		person := args.Get(1).(*db.Person)
 		person.ID = testUUID
+		person.CreatedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		person.UpdatedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
 	}).Return(error(nil)) // Set what the `Insert` function should return if the `On` conditions were met.
 
 	mockPersonStore.On("Insert", mock.Anything, &db.Person{
@@ -58,10 +68,13 @@ func Test_personDataHandler_Add(t *testing.T) {
 			},
 			args: args{
 				w: httptest.NewRecorder(),
+				// The timestamps are read-only, so they must not make it to the datastore
 				r: httptest.NewRequest(http.MethodPost, "/person", encodeJSONBody(t, person{
 					FirstName:   "Testy",
 					LastName:    "McTesterson",
 					DateOfBirth: "1970-01-01",
+					CreatedAt:   "1999-01-01T00:00:00Z",
+					RemovedAt:   "1999-01-01T00:00:00Z",
 				})),
 			},
 			wantResp: wantResp[dataResponse[person]]{
@@ -73,6 +86,8 @@ func Test_personDataHandler_Add(t *testing.T) {
 						FirstName:   "Testy",
 						LastName:    "McTesterson",
 						DateOfBirth: "1/1/1970",
+						CreatedAt:   "2024-01-01T12:00:00Z",
+						UpdatedAt:   "2024-01-01T12:00:00Z",
 					},
 				},
 			},
@@ -149,6 +164,7 @@ func Test_personDataHandler_GetSpecific(t *testing.T) {
 	testUUID, _ := uuid.NewRandom()
 	errorUUID, _ := uuid.NewRandom()
 	dneUUID, _ := uuid.NewRandom()
+	unavailableUUID, _ := uuid.NewRandom()
 
 	testLogger := slog.Default()
 	mockPersonDatastore := &mockDatastore[db.Person, uuid.UUID]{}
@@ -159,6 +175,7 @@ func Test_personDataHandler_GetSpecific(t *testing.T) {
 			LastName:    "Tester",
 			DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 			Removed:     db.NewBool(false),
+			Version:     7,
 		},
 		error(nil),
 	)
@@ -170,6 +187,10 @@ func Test_personDataHandler_GetSpecific(t *testing.T) {
 		(*db.Person)(nil),
 		db.ErrNoResultsFound,
 	)
+	mockPersonDatastore.On("Get", mock.Anything, unavailableUUID).Return(
+		(*db.Person)(nil),
+		fmt.Errorf("failed to get person: %w", db.ErrUnavailable),
+	)
 
 	tests := []struct {
 		name      string
@@ -191,6 +212,7 @@ func Test_personDataHandler_GetSpecific(t *testing.T) {
 			urlParams: map[string]string{"id": testUUID.String()},
 			wantResp: wantResp[dataResponse[person]]{
 				statusCode: http.StatusOK,
+				headers:    map[string]string{"ETag": `"7"`},
 				data: dataResponse[person]{
 					baseResponse: baseResponse{Success: true},
 					Data: person{
@@ -256,6 +278,24 @@ func Test_personDataHandler_GetSpecific(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Database Unavailable",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person/{id}", nil),
+			},
+			urlParams: map[string]string{"id": unavailableUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusServiceUnavailable,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "the service is temporarily unavailable, please try again later"},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -277,6 +317,7 @@ func Test_personDataHandler_Remove(t *testing.T) {
 	testUUID, _ := uuid.NewRandom()
 	errorUUID, _ := uuid.NewRandom()
 	dneUUID, _ := uuid.NewRandom()
+	removedUUID, _ := uuid.NewRandom()
 
 	testLogger := slog.Default()
 	mockPersonDatastore := &mockDatastore[db.Person, uuid.UUID]{}
@@ -298,6 +339,22 @@ func Test_personDataHandler_Remove(t *testing.T) {
 		(*db.Person)(nil),
 		db.ErrNoResultsFound,
 	)
+	mockPersonDatastore.On("Remove", mock.Anything, removedUUID).Return(
+		(*db.Person)(nil),
+		db.ErrAlreadyRemoved,
+	)
+	mockPersonDatastore.On("Get", mock.Anything, testUUID).Return(
+		&db.Person{
+			ID:          testUUID,
+			FirstName:   "Some",
+			LastName:    "Tester",
+			DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+			Removed:     db.NewBool(false),
+			Version:     1,
+		},
+		error(nil),
+	)
+	mockDB := &mockDatabase{person: mockPersonDatastore}
 
 	tests := []struct {
 		name      string
@@ -324,6 +381,44 @@ func Test_personDataHandler_Remove(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "If-Match Matches",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withHeader(httptest.NewRequest(http.MethodDelete, "/person/{id}", nil), "If-Match", `"1"`),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusOK,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Success: true},
+				},
+			},
+		},
+		{
+			name: "If-Match Stale",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withHeader(httptest.NewRequest(http.MethodDelete, "/person/{id}", nil), "If-Match", `"2", W/"1"`),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusPreconditionFailed,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "the resource was changed by another request"},
+				},
+			},
+		},
 		{
 			name: "Bad UUID",
 			pdh: personDataHandler{
@@ -378,6 +473,24 @@ func Test_personDataHandler_Remove(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Already Removed",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodDelete, "/person/{id}", nil),
+			},
+			urlParams: map[string]string{"id": removedUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusConflict,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "the request conflicts with the current state of the resource"},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -394,158 +507,1960 @@ func Test_personDataHandler_Remove(t *testing.T) {
 	}
 }
 
-func Test_personDataHandler_Update(t *testing.T) {
+func Test_personDataHandler_Restore(t *testing.T) {
+
 	testUUID, _ := uuid.NewRandom()
 	errorUUID, _ := uuid.NewRandom()
 	dneUUID, _ := uuid.NewRandom()
+	activeUUID, _ := uuid.NewRandom()
 
 	testLogger := slog.Default()
-	mockUserStore := &mockDatastore[db.Person, uuid.UUID]{}
-	mockUserStore.On("Update", mock.Anything, &db.Person{
-		ID:          testUUID,
-		FirstName:   "Another",
-		LastName:    "Tester",
-		DateOfBirth: time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC),
-		Removed:     db.NewBool(false),
-	}).Return(error(nil))
-	mockUserStore.On("Update", mock.Anything, &db.Person{
-		ID:          errorUUID,
-		FirstName:   "Another",
-		LastName:    "Tester",
-		DateOfBirth: time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC),
-		Removed:     db.NewBool(false),
-	}).Return(fmt.Errorf("mock error"))
-	mockUserStore.On("Update", mock.Anything, &db.Person{
-		ID:          dneUUID,
-		FirstName:   "Another",
-		LastName:    "Tester",
-		DateOfBirth: time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC),
-		Removed:     db.NewBool(false),
-	}).Return(db.ErrNoResultsFound)
+	mockPersonDatastore := &mockDatastore[db.Person, uuid.UUID]{}
+	mockPersonDatastore.On("Restore", mock.Anything, testUUID).Return(
+		&db.Person{
+			ID:          testUUID,
+			FirstName:   "Some",
+			LastName:    "Tester",
+			DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+			Removed:     db.NewBool(false),
+			Version:     3,
+		},
+		error(nil),
+	)
+	mockPersonDatastore.On("Restore", mock.Anything, errorUUID).Return(
+		(*db.Person)(nil),
+		fmt.Errorf("mockError"),
+	)
+	mockPersonDatastore.On("Restore", mock.Anything, dneUUID).Return(
+		(*db.Person)(nil),
+		db.ErrNoResultsFound,
+	)
+	mockPersonDatastore.On("Restore", mock.Anything, activeUUID).Return(
+		(*db.Person)(nil),
+		db.ErrNotRemoved,
+	)
 
 	tests := []struct {
-		name     string
-		pdh      personDataHandler
-		args     args
-		wantResp wantResp[dataResponse[person]]
+		name      string
+		pdh       personDataHandler
+		args      args
+		urlParams map[string]string
+		wantResp  wantResp[dataResponse[person]]
 	}{
 		{
 			name: "Success",
 			pdh: personDataHandler{
-				personDatastore: mockUserStore,
+				personDatastore: mockPersonDatastore,
 				logger:          testLogger,
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
-					ID:          testUUID.String(),
-					FirstName:   "Another",
-					LastName:    "Tester",
-					DateOfBirth: "1992-01-27",
-					Removed:     false,
-				})),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/restore", nil),
 			},
+			urlParams: map[string]string{"id": testUUID.String()},
 			wantResp: wantResp[dataResponse[person]]{
 				statusCode: http.StatusOK,
+				headers:    map[string]string{"ETag": `"3"`},
 				data: dataResponse[person]{
 					baseResponse: baseResponse{Success: true},
 					Data: person{
 						ID:          testUUID.String(),
-						FirstName:   "Another",
+						FirstName:   "Some",
 						LastName:    "Tester",
-						DateOfBirth: "1/27/1992",
+						DateOfBirth: "1/1/1970",
 						Removed:     false,
 					},
 				},
 			},
 		},
 		{
-			name: "Bad Request Format",
+			name: "Bad UUID",
 			pdh: personDataHandler{
-				personDatastore: mockUserStore,
+				personDatastore: mockPersonDatastore,
 				logger:          testLogger,
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest(http.MethodPut, "/person/{id}", strings.NewReader("malformed data")),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/restore", nil),
 			},
+			urlParams: map[string]string{"id": "badUUID"},
 			wantResp: wantResp[dataResponse[person]]{
-				statusCode: http.StatusBadRequest,
+				statusCode: http.StatusNotFound,
 				data: dataResponse[person]{
-					baseResponse: baseResponse{Message: "failed to read request"},
+					baseResponse: baseResponse{Message: "not found"},
 				},
 			},
 		},
 		{
-			name: "Bad UUID in Request",
+			name: "Database Error",
 			pdh: personDataHandler{
-				personDatastore: mockUserStore,
+				personDatastore: mockPersonDatastore,
 				logger:          testLogger,
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
-					ID: "BadUUID",
-				})),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/restore", nil),
 			},
+			urlParams: map[string]string{"id": errorUUID.String()},
 			wantResp: wantResp[dataResponse[person]]{
-				statusCode: http.StatusUnprocessableEntity,
+				statusCode: http.StatusInternalServerError,
 				data: dataResponse[person]{
-					baseResponse: baseResponse{Message: "malformed request data"},
+					baseResponse: baseResponse{Message: "server encountered an error processing the request"},
 				},
 			},
 		},
 		{
-			name: "Database Error",
+			name: "ID not in Database",
 			pdh: personDataHandler{
-				personDatastore: mockUserStore,
+				personDatastore: mockPersonDatastore,
 				logger:          testLogger,
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
-					ID:          errorUUID.String(),
-					FirstName:   "Another",
-					LastName:    "Tester",
-					DateOfBirth: "1992-01-27",
-					Removed:     false,
-				})),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/restore", nil),
 			},
+			urlParams: map[string]string{"id": dneUUID.String()},
 			wantResp: wantResp[dataResponse[person]]{
-				statusCode: http.StatusInternalServerError,
+				statusCode: http.StatusNotFound,
 				data: dataResponse[person]{
-					baseResponse: baseResponse{Message: "server encountered an error processing the request"},
+					baseResponse: baseResponse{Message: "not found"},
 				},
 			},
 		},
 		{
-			name: "ID not in Database",
+			name: "Not Removed",
 			pdh: personDataHandler{
-				personDatastore: mockUserStore,
+				personDatastore: mockPersonDatastore,
 				logger:          testLogger,
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
-					ID:          dneUUID.String(),
-					FirstName:   "Another",
-					LastName:    "Tester",
-					DateOfBirth: "1992-01-27",
-					Removed:     false,
-				})),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/restore", nil),
 			},
+			urlParams: map[string]string{"id": activeUUID.String()},
 			wantResp: wantResp[dataResponse[person]]{
-				statusCode: http.StatusNotFound,
+				statusCode: http.StatusConflict,
 				data: dataResponse[person]{
-					baseResponse: baseResponse{Message: "not found"},
+					baseResponse: baseResponse{Message: "the request conflicts with the current state of the resource"},
 				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.pdh.Update(tt.args.w, tt.args.r)
+			/*Setup request context and headers*/
+			chiContext := chi.NewRouteContext()
+			for k, v := range tt.urlParams {
+				chiContext.URLParams.Add(k, v)
+			}
+			tt.args.r = tt.args.r.WithContext(context.WithValue(tt.args.r.Context(), chi.RouteCtxKey, chiContext))
+
+			tt.pdh.Restore(tt.args.w, tt.args.r)
 			assertResponse(t, tt.wantResp, tt.args.w)
 		})
 	}
 }
+
+func Test_personDataHandler_Purge(t *testing.T) {
+
+	testUUID, _ := uuid.NewRandom()
+	errorUUID, _ := uuid.NewRandom()
+	dneUUID, _ := uuid.NewRandom()
+	activeUUID, _ := uuid.NewRandom()
+
+	admin := auth.Principal{Name: "admin", Admin: true}
+	testLogger := slog.Default()
+	mockPersonDatastore := &mockDatastore[db.Person, uuid.UUID]{}
+	mockPersonDatastore.On("Purge", mock.Anything, testUUID).Return(error(nil))
+	mockPersonDatastore.On("Purge", mock.Anything, errorUUID).Return(fmt.Errorf("mockError"))
+	mockPersonDatastore.On("Purge", mock.Anything, dneUUID).Return(db.ErrNoResultsFound)
+	mockPersonDatastore.On("Purge", mock.Anything, activeUUID).Return(db.ErrNotRemoved)
+
+	tests := []struct {
+		name      string
+		pdh       personDataHandler
+		args      args
+		urlParams map[string]string
+		wantResp  wantResp[baseResponse]
+	}{
+		{
+			name: "Success",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withPrincipal(httptest.NewRequest(http.MethodDelete, "/admin/person/{id}", nil), admin),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[baseResponse]{
+				statusCode: http.StatusOK,
+				data:       baseResponse{Success: true},
+			},
+		},
+		{
+			name: "Anonymous",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodDelete, "/admin/person/{id}", nil),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[baseResponse]{
+				statusCode: http.StatusUnauthorized,
+				data:       baseResponse{Message: "authentication required"},
+			},
+		},
+		{
+			name: "Not an Admin",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withPrincipal(httptest.NewRequest(http.MethodDelete, "/admin/person/{id}", nil), auth.Principal{Name: "someone"}),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[baseResponse]{
+				statusCode: http.StatusForbidden,
+				data:       baseResponse{Message: "permission denied"},
+			},
+		},
+		{
+			name: "Database Error",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withPrincipal(httptest.NewRequest(http.MethodDelete, "/admin/person/{id}", nil), admin),
+			},
+			urlParams: map[string]string{"id": errorUUID.String()},
+			wantResp: wantResp[baseResponse]{
+				statusCode: http.StatusInternalServerError,
+				data:       baseResponse{Message: "server encountered an error processing the request"},
+			},
+		},
+		{
+			name: "ID not in Database",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withPrincipal(httptest.NewRequest(http.MethodDelete, "/admin/person/{id}", nil), admin),
+			},
+			urlParams: map[string]string{"id": dneUUID.String()},
+			wantResp: wantResp[baseResponse]{
+				statusCode: http.StatusNotFound,
+				data:       baseResponse{Message: "not found"},
+			},
+		},
+		{
+			name: "Not Removed",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withPrincipal(httptest.NewRequest(http.MethodDelete, "/admin/person/{id}", nil), admin),
+			},
+			urlParams: map[string]string{"id": activeUUID.String()},
+			wantResp: wantResp[baseResponse]{
+				statusCode: http.StatusConflict,
+				data:       baseResponse{Message: "the request conflicts with the current state of the resource"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			/*Setup request context and headers*/
+			chiContext := chi.NewRouteContext()
+			for k, v := range tt.urlParams {
+				chiContext.URLParams.Add(k, v)
+			}
+			tt.args.r = tt.args.r.WithContext(context.WithValue(tt.args.r.Context(), chi.RouteCtxKey, chiContext))
+
+			tt.pdh.Purge(tt.args.w, tt.args.r)
+			assertResponse(t, tt.wantResp, tt.args.w)
+		})
+	}
+}
+
+func Test_personDataHandler_Update(t *testing.T) {
+	testUUID, _ := uuid.NewRandom()
+	errorUUID, _ := uuid.NewRandom()
+	dneUUID, _ := uuid.NewRandom()
+	conflictUUID, _ := uuid.NewRandom()
+
+	testLogger := slog.Default()
+	mockUserStore := &mockDatastore[db.Person, uuid.UUID]{}
+	mockUserStore.On("Update", mock.Anything, &db.Person{
+		ID:          testUUID,
+		FirstName:   "Another",
+		LastName:    "Tester",
+		DateOfBirth: time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+	}).Return(error(nil))
+	mockUserStore.On("Update", mock.Anything, &db.Person{
+		ID:          errorUUID,
+		FirstName:   "Another",
+		LastName:    "Tester",
+		DateOfBirth: time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+	}).Return(fmt.Errorf("mock error"))
+	mockUserStore.On("Update", mock.Anything, &db.Person{
+		ID:          dneUUID,
+		FirstName:   "Another",
+		LastName:    "Tester",
+		DateOfBirth: time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+	}).Return(db.ErrNoResultsFound)
+	mockUserStore.On("Get", mock.Anything, testUUID).Return(
+		&db.Person{ID: testUUID, FirstName: "Some", LastName: "Tester", Removed: db.NewBool(false), Version: 3},
+		error(nil),
+	)
+	mockUserStore.On("Update", mock.Anything, &db.Person{
+		ID:          testUUID,
+		FirstName:   "Another",
+		LastName:    "Tester",
+		DateOfBirth: time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     3,
+	}).Run(func(args mock.Arguments) {
+		person := args.Get(1).(*db.Person)
+		person.Version = 4
+	}).Return(error(nil))
+	mockUserStore.On("Get", mock.Anything, conflictUUID).Return(
+		&db.Person{ID: conflictUUID, FirstName: "Some", LastName: "Tester", Removed: db.NewBool(false), Version: 5},
+		error(nil),
+	)
+	mockUserStore.On("Update", mock.Anything, &db.Person{
+		ID:          conflictUUID,
+		FirstName:   "Another",
+		LastName:    "Tester",
+		DateOfBirth: time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     5,
+	}).Return(db.ErrVersionConflict)
+
+	tests := []struct {
+		name      string
+		pdh       personDataHandler
+		args      args
+		urlParams map[string]string
+		wantResp  wantResp[dataResponse[person]]
+	}{
+		{
+			name: "Success",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID:          testUUID.String(),
+					FirstName:   "Another",
+					LastName:    "Tester",
+					DateOfBirth: "1992-01-27",
+					Removed:     false,
+				})),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusOK,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Success: true},
+					Data: person{
+						ID:          testUUID.String(),
+						FirstName:   "Another",
+						LastName:    "Tester",
+						DateOfBirth: "1/27/1992",
+						Removed:     false,
+					},
+				},
+			},
+		},
+		{
+			name: "If-Match Matches",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withHeader(httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID:          testUUID.String(),
+					FirstName:   "Another",
+					LastName:    "Tester",
+					DateOfBirth: "1992-01-27",
+				})), "If-Match", `"2", "3"`),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusOK,
+				headers:    map[string]string{"ETag": `"4"`},
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Success: true},
+					Data: person{
+						ID:          testUUID.String(),
+						FirstName:   "Another",
+						LastName:    "Tester",
+						DateOfBirth: "1/27/1992",
+					},
+				},
+			},
+		},
+		{
+			name: "If-Match Stale",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withHeader(httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID:          testUUID.String(),
+					FirstName:   "Another",
+					LastName:    "Tester",
+					DateOfBirth: "1992-01-27",
+				})), "If-Match", `"2"`),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusPreconditionFailed,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "the resource was changed by another request"},
+				},
+			},
+		},
+		{
+			name: "Version Conflict",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withHeader(httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID:          conflictUUID.String(),
+					FirstName:   "Another",
+					LastName:    "Tester",
+					DateOfBirth: "1992-01-27",
+				})), "If-Match", "*"),
+			},
+			urlParams: map[string]string{"id": conflictUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusPreconditionFailed,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "the resource was changed by another request"},
+				},
+			},
+		},
+		{
+			name: "Bad Request Format",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPut, "/person/{id}", strings.NewReader("malformed data")),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusBadRequest,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "failed to read request"},
+				},
+			},
+		},
+		{
+			name: "Bad UUID in Request",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID: "BadUUID",
+				})),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusUnprocessableEntity,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "malformed request data"},
+				},
+			},
+		},
+		{
+			name: "Database Error",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID:          errorUUID.String(),
+					FirstName:   "Another",
+					LastName:    "Tester",
+					DateOfBirth: "1992-01-27",
+					Removed:     false,
+				})),
+			},
+			urlParams: map[string]string{"id": errorUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusInternalServerError,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "server encountered an error processing the request"},
+				},
+			},
+		},
+		{
+			name: "ID not in Database",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID:          dneUUID.String(),
+					FirstName:   "Another",
+					LastName:    "Tester",
+					DateOfBirth: "1992-01-27",
+					Removed:     false,
+				})),
+			},
+			urlParams: map[string]string{"id": dneUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusNotFound,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "not found"},
+				},
+			},
+		},
+		{
+			name: "ID Only in URL",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					FirstName:   "Another",
+					LastName:    "Tester",
+					DateOfBirth: "1992-01-27",
+				})),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusOK,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Success: true},
+					Data: person{
+						ID:          testUUID.String(),
+						FirstName:   "Another",
+						LastName:    "Tester",
+						DateOfBirth: "1/27/1992",
+					},
+				},
+			},
+		},
+		{
+			name: "ID Mismatch",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withHeader(httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID:          errorUUID.String(),
+					FirstName:   "Another",
+					LastName:    "Tester",
+					DateOfBirth: "1992-01-27",
+				})), "If-Match", `"3"`),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusUnprocessableEntity,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "malformed request data"},
+				},
+			},
+		},
+		{
+			name: "Bad UUID in URL",
+			pdh: personDataHandler{
+				personDatastore: mockUserStore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPut, "/person/{id}", encodeJSONBody(t, person{
+					ID:        testUUID.String(),
+					FirstName: "Another",
+					LastName:  "Tester",
+				})),
+			},
+			urlParams: map[string]string{"id": "BadUUID"},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusNotFound,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "not found"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chiContext := chi.NewRouteContext()
+			for k, v := range tt.urlParams {
+				chiContext.URLParams.Add(k, v)
+			}
+			tt.args.r = tt.args.r.WithContext(context.WithValue(tt.args.r.Context(), chi.RouteCtxKey, chiContext))
+
+			tt.pdh.Update(tt.args.w, tt.args.r)
+			assertResponse(t, tt.wantResp, tt.args.w)
+		})
+	}
+}
+
+func Test_personDataHandler_Update_RemovedPerson(t *testing.T) {
+	ctx := context.Background()
+	database := memdb.NewSession()
+	pdh := personDataHandler{personDatastore: database.Person(), logger: slog.Default()}
+
+	stored := &db.Person{FirstName: "Some", LastName: "Tester"}
+	if err := database.Person().Insert(ctx, stored); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+	if _, err := database.Person().Remove(ctx, stored.ID); err != nil {
+		t.Fatalf("failed to remove person: %v", err)
+	}
+
+	// The removed field of the request is left out, which must not bring the person back
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPut, "/person/"+stored.ID.String(), strings.NewReader(
+		fmt.Sprintf(`{"id": %q, "firstName": "Another", "lastName": "Tester"}`, stored.ID),
+	))
+	r = withURLParam(r, "id", stored.ID.String())
+	pdh.Update(w, r)
+
+	var got dataResponse[person]
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, "Another", got.Data.FirstName)
+	assert.True(t, got.Data.Removed)
+
+	current, err := database.Person().Get(ctx, stored.ID)
+	assert.NoError(t, err)
+	assert.True(t, *current.Removed)
+	assert.Equal(t, "Another", current.FirstName)
+}
+
+func Test_personDataHandler_GetAll(t *testing.T) {
+	testSecret := []byte("test secret")
+	testLogger := slog.Default()
+
+	alpha := db.Person{ID: uuid.New(), FirstName: "Some", LastName: "Alpha", DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), Removed: db.NewBool(false)}
+	bravo := db.Person{ID: uuid.New(), FirstName: "Some", LastName: "Bravo", DateOfBirth: time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC), Removed: db.NewBool(false)}
+	charlie := db.Person{ID: uuid.New(), FirstName: "Some", LastName: "Charlie", DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), Removed: db.NewBool(false)}
+
+	byLastName := []db.Sort{{Field: db.PersonLastName}}
+	nextCursor, _ := encodeCursor(testSecret, cursor{Sort: "lastName", Position: []string{"Bravo", bravo.ID.String()}})
+	prevCursor, _ := encodeCursor(testSecret, cursor{Backward: true, Sort: "lastName", Position: []string{"Charlie", charlie.ID.String()}})
+
+	mockPersonDatastore := &mockDatastore[db.Person, uuid.UUID]{}
+	mockPersonDatastore.On("Query", mock.Anything, db.Query{Sort: byLastName, Limit: 3}).Return(
+		&db.Page[db.Person]{Items: []db.Person{alpha, bravo, charlie}, Total: 3},
+		error(nil),
+	)
+	mockPersonDatastore.On("Query", mock.Anything, db.Query{Sort: byLastName, Limit: 3, After: []any{"Bravo", bravo.ID}}).Return(
+		&db.Page[db.Person]{Items: []db.Person{charlie}, Total: 3},
+		error(nil),
+	)
+	mockPersonDatastore.On("Query", mock.Anything, db.Query{Sort: byLastName, Limit: 3, After: []any{"Charlie", charlie.ID}, Backward: true}).Return(
+		&db.Page[db.Person]{Items: []db.Person{bravo, alpha}, Total: 3},
+		error(nil),
+	)
+	mockPersonDatastore.On("Query", mock.Anything, db.Query{Limit: 21, IncludeRemoved: true}).Return(
+		(*db.Page[db.Person])(nil),
+		fmt.Errorf("mock error"),
+	)
+	mockPersonDatastore.On("Query", mock.Anything, db.Query{Limit: 5}).Return(
+		(*db.Page[db.Person])(nil),
+		fmt.Errorf("%w: connection refused", db.ErrUnavailable),
+	)
+
+	tests := []struct {
+		name     string
+		pdh      personDataHandler
+		args     args
+		wantResp wantResp[dataResponse[pageResponse[person]]]
+	}{
+		{
+			name: "First Page",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    testSecret,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?pageSize=2&sort=lastName", nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusOK,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Success: true},
+					Data: pageResponse[person]{
+						Items: []person{
+							{ID: alpha.ID.String(), FirstName: "Some", LastName: "Alpha", DateOfBirth: "1/1/1970"},
+							{ID: bravo.ID.String(), FirstName: "Some", LastName: "Bravo", DateOfBirth: "1/1/1980"},
+						},
+						Total: 3,
+						Next:  nextCursor,
+					},
+				},
+			},
+		},
+		{
+			name: "Next Page",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    testSecret,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?pageSize=2&cursor="+nextCursor, nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusOK,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Success: true},
+					Data: pageResponse[person]{
+						Items: []person{
+							{ID: charlie.ID.String(), FirstName: "Some", LastName: "Charlie", DateOfBirth: "1/1/1990"},
+						},
+						Total: 3,
+						Prev:  prevCursor,
+					},
+				},
+			},
+		},
+		{
+			name: "Previous Page",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    testSecret,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?pageSize=2&cursor="+prevCursor, nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusOK,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Success: true},
+					Data: pageResponse[person]{
+						Items: []person{
+							{ID: alpha.ID.String(), FirstName: "Some", LastName: "Alpha", DateOfBirth: "1/1/1970"},
+							{ID: bravo.ID.String(), FirstName: "Some", LastName: "Bravo", DateOfBirth: "1/1/1980"},
+						},
+						Total: 3,
+						Next:  nextCursor,
+					},
+				},
+			},
+		},
+		{
+			name: "Bad Page Size",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    testSecret,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?pageSize=1000", nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusBadRequest,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Message: "failed to read request"},
+				},
+			},
+		},
+		{
+			name: "Unknown Sort Field",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    testSecret,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?sort=-favoriteColor", nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusBadRequest,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Message: "failed to read request"},
+				},
+			},
+		},
+		{
+			name: "Cursor From Another Secret",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    []byte("another secret"),
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?cursor="+nextCursor, nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusBadRequest,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Message: "failed to read request"},
+				},
+			},
+		},
+		{
+			name: "Cursor With Different Sort",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    testSecret,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?sort=dob&cursor="+nextCursor, nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusBadRequest,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Message: "failed to read request"},
+				},
+			},
+		},
+		{
+			name: "Database Error",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    testSecret,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?includeRemoved=true", nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusInternalServerError,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Message: "server encountered an error processing the request"},
+				},
+			},
+		},
+		{
+			name: "Database Unavailable",
+			pdh: personDataHandler{
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+				cursorSecret:    testSecret,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person?pageSize=4", nil),
+			},
+			wantResp: wantResp[dataResponse[pageResponse[person]]]{
+				statusCode: http.StatusServiceUnavailable,
+				data: dataResponse[pageResponse[person]]{
+					baseResponse: baseResponse{Message: "the service is temporarily unavailable, please try again later"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pdh.GetAll(tt.args.w, tt.args.r)
+			assertResponse(t, tt.wantResp, tt.args.w)
+		})
+	}
+}
+
+func Test_personDataHandler_History(t *testing.T) {
+
+	testUUID, _ := uuid.NewRandom()
+	errorUUID, _ := uuid.NewRandom()
+	dneUUID, _ := uuid.NewRandom()
+
+	inserted := db.Person{
+		ID:          testUUID,
+		FirstName:   "Some",
+		LastName:    "Tester",
+		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     1,
+	}
+	updated := inserted
+	updated.FirstName = "Another"
+	updated.Version = 2
+
+	testLogger := slog.Default()
+	mockPersonHistory := &mockHistoryStore[db.Person, uuid.UUID]{}
+	mockPersonHistory.On("List", mock.Anything, testUUID).Return(
+		[]db.HistoryEntry[db.Person]{
+			{
+				Version:   1,
+				Change:    db.ChangeInsert,
+				Principal: "tester",
+				Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+				Item:      inserted,
+				Diff:      []db.FieldChange{{Field: db.PersonDateOfBirth, Old: time.Time{}, New: inserted.DateOfBirth}},
+			},
+			{
+				Version:   2,
+				Change:    db.ChangeUpdate,
+				Timestamp: time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC),
+				Item:      updated,
+				Diff:      []db.FieldChange{{Field: db.PersonFirstName, Old: "Some", New: "Another"}},
+			},
+		},
+		error(nil),
+	)
+	mockPersonHistory.On("List", mock.Anything, errorUUID).Return(
+		([]db.HistoryEntry[db.Person])(nil),
+		fmt.Errorf("mockError"),
+	)
+	mockPersonHistory.On("List", mock.Anything, dneUUID).Return(
+		([]db.HistoryEntry[db.Person])(nil),
+		db.ErrNoResultsFound,
+	)
+
+	tests := []struct {
+		name      string
+		pdh       personDataHandler
+		args      args
+		urlParams map[string]string
+		wantResp  wantResp[dataResponse[[]historyEntry[person]]]
+	}{
+		{
+			name: "Success",
+			pdh: personDataHandler{
+				personHistory: mockPersonHistory,
+				logger:        testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person/{id}/history", nil),
+			},
+			urlParams: map[string]string{"id": testUUID.String()},
+			wantResp: wantResp[dataResponse[[]historyEntry[person]]]{
+				statusCode: http.StatusOK,
+				data: dataResponse[[]historyEntry[person]]{
+					baseResponse: baseResponse{Success: true},
+					Data: []historyEntry[person]{
+						{
+							Version:   1,
+							Change:    "insert",
+							Principal: "tester",
+							Timestamp: "2024-01-01T12:00:00Z",
+							Data: person{
+								ID:          testUUID.String(),
+								FirstName:   "Some",
+								LastName:    "Tester",
+								DateOfBirth: "1/1/1970",
+							},
+							Diff: []fieldChange{{Field: "dob", Old: "1/1/0001", New: "1/1/1970"}},
+						},
+						{
+							Version:   2,
+							Change:    "update",
+							Timestamp: "2024-01-02T12:00:00Z",
+							Data: person{
+								ID:          testUUID.String(),
+								FirstName:   "Another",
+								LastName:    "Tester",
+								DateOfBirth: "1/1/1970",
+							},
+							Diff: []fieldChange{{Field: "firstName", Old: "Some", New: "Another"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "Bad UUID",
+			pdh: personDataHandler{
+				personHistory: mockPersonHistory,
+				logger:        testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person/{id}/history", nil),
+			},
+			urlParams: map[string]string{"id": "badUUID"},
+			wantResp: wantResp[dataResponse[[]historyEntry[person]]]{
+				statusCode: http.StatusNotFound,
+				data: dataResponse[[]historyEntry[person]]{
+					baseResponse: baseResponse{Message: "not found"},
+				},
+			},
+		},
+		{
+			name: "Database Error",
+			pdh: personDataHandler{
+				personHistory: mockPersonHistory,
+				logger:        testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person/{id}/history", nil),
+			},
+			urlParams: map[string]string{"id": errorUUID.String()},
+			wantResp: wantResp[dataResponse[[]historyEntry[person]]]{
+				statusCode: http.StatusInternalServerError,
+				data: dataResponse[[]historyEntry[person]]{
+					baseResponse: baseResponse{Message: "server encountered an error processing the request"},
+				},
+			},
+		},
+		{
+			name: "ID not in Database",
+			pdh: personDataHandler{
+				personHistory: mockPersonHistory,
+				logger:        testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/person/{id}/history", nil),
+			},
+			urlParams: map[string]string{"id": dneUUID.String()},
+			wantResp: wantResp[dataResponse[[]historyEntry[person]]]{
+				statusCode: http.StatusNotFound,
+				data: dataResponse[[]historyEntry[person]]{
+					baseResponse: baseResponse{Message: "not found"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			/*Setup request context and headers*/
+			chiContext := chi.NewRouteContext()
+			for k, v := range tt.urlParams {
+				chiContext.URLParams.Add(k, v)
+			}
+			tt.args.r = tt.args.r.WithContext(context.WithValue(tt.args.r.Context(), chi.RouteCtxKey, chiContext))
+
+			tt.pdh.History(tt.args.w, tt.args.r)
+			assertResponse(t, tt.wantResp, tt.args.w)
+		})
+	}
+}
+
+func Test_personDataHandler_Revert(t *testing.T) {
+
+	testUUID, _ := uuid.NewRandom()
+	dneUUID, _ := uuid.NewRandom()
+
+	original := db.Person{
+		ID:          testUUID,
+		FirstName:   "Some",
+		LastName:    "Tester",
+		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     1,
+	}
+	current := original
+	current.FirstName = "Another"
+	current.Version = 3
+
+	testLogger := slog.Default()
+	mockPersonDatastore := &mockDatastore[db.Person, uuid.UUID]{}
+	mockPersonDatastore.On("Get", mock.Anything, testUUID).Return(&current, error(nil))
+	mockPersonDatastore.On("Update", mock.Anything, mock.MatchedBy(func(p *db.Person) bool {
+		return p.ID == testUUID && p.FirstName == "Some" && p.Version == 3
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*db.Person).Version = 4
+	}).Return(error(nil))
+
+	mockPersonHistory := &mockHistoryStore[db.Person, uuid.UUID]{}
+	mockPersonHistory.On("Get", mock.Anything, testUUID, 1).Return(
+		&db.HistoryEntry[db.Person]{Version: 1, Change: db.ChangeInsert, Item: original},
+		error(nil),
+	)
+	mockPersonHistory.On("Get", mock.Anything, testUUID, 7).Return(
+		(*db.HistoryEntry[db.Person])(nil),
+		db.ErrNoResultsFound,
+	)
+	mockPersonHistory.On("Get", mock.Anything, dneUUID, 1).Return(
+		(*db.HistoryEntry[db.Person])(nil),
+		db.ErrNoResultsFound,
+	)
+	mockDB := &mockDatabase{person: mockPersonDatastore, personHistory: mockPersonHistory}
+
+	tests := []struct {
+		name      string
+		pdh       personDataHandler
+		args      args
+		urlParams map[string]string
+		wantResp  wantResp[dataResponse[person]]
+	}{
+		{
+			name: "Success",
+			pdh: personDataHandler{
+				database: mockDB,
+				logger:   testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/history/{version}/revert", nil),
+			},
+			urlParams: map[string]string{"id": testUUID.String(), "version": "1"},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusOK,
+				headers:    map[string]string{"ETag": `"4"`},
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Success: true},
+					Data: person{
+						ID:          testUUID.String(),
+						FirstName:   "Some",
+						LastName:    "Tester",
+						DateOfBirth: "1/1/1970",
+					},
+				},
+			},
+		},
+		{
+			name: "If-Match Stale",
+			pdh: personDataHandler{
+				database: mockDB,
+				logger:   testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: withHeader(httptest.NewRequest(http.MethodPost, "/person/{id}/history/{version}/revert", nil), "If-Match", `"2"`),
+			},
+			urlParams: map[string]string{"id": testUUID.String(), "version": "1"},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusPreconditionFailed,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "the resource was changed by another request"},
+				},
+			},
+		},
+		{
+			name: "Bad Version",
+			pdh: personDataHandler{
+				database: mockDB,
+				logger:   testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/history/{version}/revert", nil),
+			},
+			urlParams: map[string]string{"id": testUUID.String(), "version": "latest"},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusNotFound,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "not found"},
+				},
+			},
+		},
+		{
+			name: "Version not in History",
+			pdh: personDataHandler{
+				database: mockDB,
+				logger:   testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/history/{version}/revert", nil),
+			},
+			urlParams: map[string]string{"id": testUUID.String(), "version": "7"},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusNotFound,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "not found"},
+				},
+			},
+		},
+		{
+			name: "ID not in Database",
+			pdh: personDataHandler{
+				database: mockDB,
+				logger:   testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/{id}/history/{version}/revert", nil),
+			},
+			urlParams: map[string]string{"id": dneUUID.String(), "version": "1"},
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusNotFound,
+				data: dataResponse[person]{
+					baseResponse: baseResponse{Message: "not found"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			/*Setup request context and headers*/
+			chiContext := chi.NewRouteContext()
+			for k, v := range tt.urlParams {
+				chiContext.URLParams.Add(k, v)
+			}
+			tt.args.r = tt.args.r.WithContext(context.WithValue(tt.args.r.Context(), chi.RouteCtxKey, chiContext))
+
+			tt.pdh.Revert(tt.args.w, tt.args.r)
+			assertResponse(t, tt.wantResp, tt.args.w)
+		})
+	}
+}
+
+func Test_personDataHandler_Batch(t *testing.T) {
+
+	testUUID, _ := uuid.NewRandom()
+	removeUUID, _ := uuid.NewRandom()
+	dneUUID, _ := uuid.NewRandom()
+
+	testLogger := slog.Default()
+	mockPersonDatastore := &mockDatastore[db.Person, uuid.UUID]{}
+	mockPersonDatastore.On("InsertBatch", mock.Anything, mock.MatchedBy(func(people []*db.Person) bool {
+		return len(people) == 1 && people[0].FirstName == "Testy"
+	})).Run(func(args mock.Arguments) {
+		// Using `Run` here since `InsertBatch` mutates the passed in people
+		people := args.Get(1).([]*db.Person)
+		people[0].ID = testUUID
+		people[0].Version = 1
+	}).Return(error(nil))
+	mockPersonDatastore.On("InsertBatch", mock.Anything, mock.MatchedBy(func(people []*db.Person) bool {
+		return len(people) == 1 && people[0].FirstName == "Error"
+	})).Return(fmt.Errorf("mockError"))
+	mockPersonDatastore.On("InsertBatch", mock.Anything, mock.MatchedBy(func(people []*db.Person) bool {
+		return len(people) == 1 && people[0].FirstName == "Unavailable"
+	})).Return(fmt.Errorf("%w: connection refused", db.ErrUnavailable))
+	mockPersonDatastore.On("RemoveBatch", mock.Anything, []uuid.UUID{removeUUID}).Return(
+		[]*db.Person{{
+			ID:          removeUUID,
+			FirstName:   "Some",
+			LastName:    "Tester",
+			DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+			Removed:     db.NewBool(true),
+			Version:     2,
+		}},
+		error(nil),
+	)
+	mockPersonDatastore.On("RemoveBatch", mock.Anything, []uuid.UUID{dneUUID}).Return(
+		[]*db.Person{nil},
+		db.NewBatchError([]error{db.ErrNoResultsFound}),
+	)
+	mockDB := &mockDatabase{person: mockPersonDatastore}
+
+	insertOp := map[string]any{"op": "insert", "data": map[string]any{"firstName": "Testy", "lastName": "McTesterson", "dob": "1970-01-01"}}
+	removeOp := map[string]any{"op": "remove", "id": removeUUID.String()}
+	dneOp := map[string]any{"op": "remove", "id": dneUUID.String()}
+
+	inserted := person{ID: testUUID.String(), FirstName: "Testy", LastName: "McTesterson", DateOfBirth: "1/1/1970"}
+	removed := person{ID: removeUUID.String(), FirstName: "Some", LastName: "Tester", DateOfBirth: "1/1/1970", Removed: true}
+
+	tests := []struct {
+		name     string
+		pdh      personDataHandler
+		args     args
+		wantResp wantResp[dataResponse[[]batchResult[person]]]
+	}{
+		{
+			name: "Success",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/batch", encodeJSONBody(t, []any{insertOp, removeOp})),
+			},
+			wantResp: wantResp[dataResponse[[]batchResult[person]]]{
+				statusCode: http.StatusOK,
+				data: dataResponse[[]batchResult[person]]{
+					baseResponse: baseResponse{Success: true},
+					Data: []batchResult[person]{
+						{Index: 0, Status: http.StatusCreated, ID: testUUID.String(), Data: &inserted},
+						{Index: 1, Status: http.StatusOK, ID: removeUUID.String(), Data: &removed},
+					},
+				},
+			},
+		},
+		{
+			name: "Atomic Failure",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/batch?mode=atomic", encodeJSONBody(t, []any{insertOp, dneOp})),
+			},
+			wantResp: wantResp[dataResponse[[]batchResult[person]]]{
+				statusCode: http.StatusNotFound,
+				data: dataResponse[[]batchResult[person]]{
+					Data: []batchResult[person]{
+						{Index: 0, Status: http.StatusFailedDependency, Error: "not applied because another item in the batch failed"},
+						{Index: 1, Status: http.StatusNotFound, Error: "not found"},
+					},
+				},
+			},
+		},
+		{
+			name: "Best Effort Failure",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/batch?mode=best-effort", encodeJSONBody(t, []any{insertOp, dneOp})),
+			},
+			wantResp: wantResp[dataResponse[[]batchResult[person]]]{
+				statusCode: http.StatusMultiStatus,
+				data: dataResponse[[]batchResult[person]]{
+					Data: []batchResult[person]{
+						{Index: 0, Status: http.StatusCreated, ID: testUUID.String(), Data: &inserted},
+						{Index: 1, Status: http.StatusNotFound, Error: "not found"},
+					},
+				},
+			},
+		},
+		{
+			name: "Invalid Operation",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/batch", encodeJSONBody(t, []any{
+					insertOp,
+					map[string]any{"op": "explode"},
+					map[string]any{"op": "insert", "data": map[string]any{"dob": "01/01/1970"}},
+				})),
+			},
+			wantResp: wantResp[dataResponse[[]batchResult[person]]]{
+				statusCode: http.StatusBadRequest,
+				data: dataResponse[[]batchResult[person]]{
+					Data: []batchResult[person]{
+						{Index: 0, Status: http.StatusFailedDependency, Error: "not applied because another item in the batch failed"},
+						{Index: 1, Status: http.StatusBadRequest, Error: "failed to read request"},
+						{Index: 2, Status: http.StatusUnprocessableEntity, Error: "malformed request data", Errors: []fieldError{
+							{Field: "data.firstName", Code: codeRequired, Message: "is required"},
+							{Field: "data.lastName", Code: codeRequired, Message: "is required"},
+							{Field: "data.dob", Code: codeInvalidFormat, Message: "must be a date formatted as 2006-01-02"},
+						}},
+					},
+				},
+			},
+		},
+		{
+			name: "Unknown Mode",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/batch?mode=sometimes", encodeJSONBody(t, []any{insertOp})),
+			},
+			wantResp: wantResp[dataResponse[[]batchResult[person]]]{
+				statusCode: http.StatusBadRequest,
+				data: dataResponse[[]batchResult[person]]{
+					baseResponse: baseResponse{Message: "failed to read request"},
+				},
+			},
+		},
+		{
+			name: "Empty Batch",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/batch", strings.NewReader("[]")),
+			},
+			wantResp: wantResp[dataResponse[[]batchResult[person]]]{
+				statusCode: http.StatusBadRequest,
+				data: dataResponse[[]batchResult[person]]{
+					baseResponse: baseResponse{Message: "failed to read request"},
+				},
+			},
+		},
+		{
+			name: "Database Error",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/batch", encodeJSONBody(t, []any{
+					map[string]any{"op": "insert", "data": map[string]any{"firstName": "Error", "lastName": "Tester"}},
+				})),
+			},
+			wantResp: wantResp[dataResponse[[]batchResult[person]]]{
+				statusCode: http.StatusInternalServerError,
+				data: dataResponse[[]batchResult[person]]{
+					baseResponse: baseResponse{Message: "server encountered an error processing the request"},
+				},
+			},
+		},
+		{
+			name: "Database Unavailable",
+			pdh: personDataHandler{
+				database:        mockDB,
+				personDatastore: mockPersonDatastore,
+				logger:          testLogger,
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodPost, "/person/batch?mode=best-effort", encodeJSONBody(t, []any{
+					map[string]any{"op": "insert", "data": map[string]any{"firstName": "Unavailable", "lastName": "Tester"}},
+				})),
+			},
+			wantResp: wantResp[dataResponse[[]batchResult[person]]]{
+				statusCode: http.StatusServiceUnavailable,
+				data: dataResponse[[]batchResult[person]]{
+					baseResponse: baseResponse{Message: "the service is temporarily unavailable, please try again later"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pdh.Batch(tt.args.w, tt.args.r)
+			assertResponse(t, tt.wantResp, tt.args.w)
+		})
+	}
+}
+
+// sseEvent is a single event read from an event stream
+type sseEvent struct {
+	id, event string
+	data      changeEvent[person]
+	comment   string
+}
+
+// readSSEEvent reads the next event or comment from an event stream
+func readSSEEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "":
+			event.comment = value
+		case "id":
+			event.id = value
+		case "event":
+			event.event = value
+		case "data":
+			if err := json.Unmarshal([]byte(value), &event.data); err != nil {
+				t.Fatalf("failed to decode event data %q: %v", value, err)
+			}
+		}
+	}
+}
+
+func Test_personDataHandler_Events(t *testing.T) {
+	ctx := context.Background()
+	database := memdb.NewSession()
+	shutdownCtx, shutdown := context.WithCancel(ctx)
+	defer shutdown()
+
+	pdh := personDataHandler{
+		personFeed: database.PersonFeed(),
+		logger:     slog.Default(),
+		shutdown:   shutdownCtx,
+		heartbeat:  10 * time.Millisecond,
+		streams:    &sync.WaitGroup{},
+	}
+	server := httptest.NewServer(http.HandlerFunc(pdh.Events))
+	defer server.Close()
+
+	// open starts streaming events. The stream is established once the response headers arrive.
+	open := func(t *testing.T, query string, lastEventID string) (*http.Response, *bufio.Reader) {
+		t.Helper()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+query, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to open event stream: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	// next reads the next event from the stream, skipping heartbeats
+	next := func(t *testing.T, reader *bufio.Reader) sseEvent {
+		t.Helper()
+
+		for {
+			if event := readSSEEvent(t, reader); event.comment != "heartbeat" {
+				return event
+			}
+		}
+	}
+
+	watched := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	other := &db.Person{FirstName: "Some", LastName: "Tester"}
+	for _, p := range []*db.Person{watched, other} {
+		if err := database.Person().Insert(ctx, p); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+
+	var events []sseEvent
+	t.Run("Filter", func(t *testing.T) {
+		resp, reader := open(t, "?id="+watched.ID.String(), "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		other.FirstName = "Another"
+		assert.NoError(t, database.Person().Update(ctx, other))
+		watched.FirstName = "Another"
+		assert.NoError(t, database.Person().Update(ctx, watched))
+		_, err := database.Person().Remove(ctx, watched.ID)
+		assert.NoError(t, err)
+
+		for _, want := range []string{"update", "remove"} {
+			event := next(t, reader)
+			assert.Equal(t, want, event.event)
+			assert.Equal(t, want, event.data.Change)
+			assert.Equal(t, watched.ID.String(), event.data.ID)
+			if assert.NotNil(t, event.data.Data) {
+				assert.Equal(t, "Another", event.data.Data.FirstName)
+			}
+			events = append(events, event)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		if len(events) < 2 {
+			t.Skip("no events to resume from")
+		}
+
+		_, reader := open(t, "?id="+watched.ID.String(), events[0].id)
+		assert.Equal(t, events[1], next(t, reader))
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		// The feed only keeps the most recent events, so the first ones are forgotten after enough changes
+		for i := 0; i < 2048; i++ {
+			assert.NoError(t, database.Person().Update(ctx, other))
+		}
+
+		_, reader := open(t, "", "1")
+		event := next(t, reader)
+		assert.Equal(t, "reset", event.event)
+		assert.Empty(t, event.id)
+
+		_, err := database.Person().Restore(ctx, watched.ID)
+		assert.NoError(t, err)
+		event = next(t, reader)
+		assert.Equal(t, "restore", event.event)
+		assert.Equal(t, watched.ID.String(), event.data.ID)
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		_, reader := open(t, "", "")
+		assert.Equal(t, "heartbeat", readSSEEvent(t, reader).comment)
+	})
+
+	t.Run("InvalidRequest", func(t *testing.T) {
+		resp, _ := open(t, "?id=not-a-uuid", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp, _ = open(t, "", "not-a-sequence")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		_, reader := open(t, "", "")
+		shutdown()
+
+		// Only heartbeats may arrive before the stream ends
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				assert.ErrorIs(t, err, io.EOF)
+				return
+			}
+			assert.Contains(t, []string{": heartbeat\n", "\n"}, line)
+		}
+	})
+}
+
+func Test_personDataHandler_Socket(t *testing.T) {
+	ctx := context.Background()
+	database := memdb.NewSession()
+	shutdownCtx, shutdown := context.WithCancel(ctx)
+	defer shutdown()
+
+	streams := &sync.WaitGroup{}
+	pdh := personDataHandler{
+		personFeed:   database.PersonFeed(),
+		logger:       slog.Default(),
+		shutdown:     shutdownCtx,
+		heartbeat:    time.Minute,
+		socketBuffer: 4,
+		streams:      streams,
+	}
+	server := httptest.NewServer(http.HandlerFunc(pdh.Socket))
+	defer server.Close()
+
+	dial := func(t *testing.T) *websocket.Conn {
+		t.Helper()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("failed to dial socket: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		return conn
+	}
+	// call sends a message, and returns the reply to it, if it has a Ref. The ping that follows the message makes sure
+	// it was applied even if there's no reply, since messages are handled in order.
+	call := func(t *testing.T, conn *websocket.Conn, msg socketMessage[person]) socketMessage[person] {
+		t.Helper()
+
+		for _, m := range []socketMessage[person]{msg, {Type: socketPing, Ref: "sync"}} {
+			if err := conn.WriteJSON(m); err != nil {
+				t.Fatalf("failed to send message: %v", err)
+			}
+		}
+
+		var reply socketMessage[person]
+		for {
+			var got socketMessage[person]
+			if err := conn.ReadJSON(&got); err != nil {
+				t.Fatalf("failed to read message: %v", err)
+			}
+			switch got.Ref {
+			case "sync":
+				return reply
+			case msg.Ref:
+				if msg.Ref != "" {
+					reply = got
+				}
+			}
+		}
+	}
+	insert := func(t *testing.T, p *db.Person) {
+		t.Helper()
+		if err := database.Person().Insert(ctx, p); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+
+	watched := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	other := &db.Person{FirstName: "Some", LastName: "Tester"}
+	insert(t, watched)
+	insert(t, other)
+
+	t.Run("Subscribe", func(t *testing.T) {
+		conn := dial(t)
+		call(t, conn, socketMessage[person]{Type: socketSubscribe, IDs: []string{watched.ID.String()}})
+
+		other.FirstName = "Another"
+		assert.NoError(t, database.Person().Update(ctx, other))
+		watched.FirstName = "Another"
+		assert.NoError(t, database.Person().Update(ctx, watched))
+
+		var msg socketMessage[person]
+		assert.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, socketEvent, msg.Type)
+		assert.NotZero(t, msg.Sequence)
+		if assert.NotNil(t, msg.Event) {
+			assert.Equal(t, "update", msg.Event.Change)
+			assert.Equal(t, watched.ID.String(), msg.Event.ID)
+			assert.Equal(t, "Another", msg.Event.Data.FirstName)
+		}
+
+		// After unsubscribing, only the subscription to every person is left
+		call(t, conn, socketMessage[person]{Type: socketSubscribe})
+		call(t, conn, socketMessage[person]{Type: socketUnsubscribe, IDs: []string{watched.ID.String()}})
+		assert.NoError(t, database.Person().Update(ctx, other))
+		assert.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, other.ID.String(), msg.Event.ID)
+
+		// Nothing is sent once every subscription is gone, so the reply to the ping comes first
+		call(t, conn, socketMessage[person]{Type: socketUnsubscribe})
+		assert.NoError(t, database.Person().Update(ctx, other))
+		reply := call(t, conn, socketMessage[person]{Type: socketPing, Ref: "1"})
+		assert.Equal(t, socketMessage[person]{Type: socketPing, Ref: "1"}, reply)
+	})
+
+	t.Run("InvalidMessage", func(t *testing.T) {
+		conn := dial(t)
+
+		reply := call(t, conn, socketMessage[person]{Type: socketSubscribe, Ref: "1", IDs: []string{watched.ID.String(), "not-a-uuid"}})
+		assert.Equal(t, socketError, reply.Type)
+		assert.Equal(t, "1", reply.Ref)
+		assert.NotEmpty(t, reply.Error)
+
+		reply = call(t, conn, socketMessage[person]{Type: "publish", Ref: "2"})
+		assert.Equal(t, socketError, reply.Type)
+
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+		var msg socketMessage[person]
+		assert.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, socketError, msg.Type)
+
+		// Nothing of the invalid subscription was applied
+		assert.NoError(t, database.Person().Update(ctx, watched))
+		reply = call(t, conn, socketMessage[person]{Type: socketPing, Ref: "3"})
+		assert.Equal(t, socketPing, reply.Type)
+	})
+
+	t.Run("SlowClient", func(t *testing.T) {
+		conn := dial(t)
+		call(t, conn, socketMessage[person]{Type: socketSubscribe})
+
+		// The client doesn't read while the events pile up
+		for i := 0; i < 5000; i++ {
+			assert.NoError(t, database.Person().Update(ctx, other))
+		}
+
+		var err error
+		for err == nil {
+			_, _, err = conn.ReadMessage()
+		}
+		assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "unexpected error: %v", err)
+	})
+
+	t.Run("Shutdown", func(t *testing.T) {
+		conn := dial(t)
+		call(t, conn, socketMessage[person]{Type: socketSubscribe})
+		shutdown()
+
+		var err error
+		for err == nil {
+			_, _, err = conn.ReadMessage()
+		}
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error: %v", err)
+
+		drainCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		assert.NoError(t, controller{streams: streams}.Drain(drainCtx))
+	})
+}
+
+func Test_personDataHandler_Patch(t *testing.T) {
+
+	testUUID, _ := uuid.NewRandom()
+	dneUUID, _ := uuid.NewRandom()
+
+	current := db.Person{
+		ID:          testUUID,
+		FirstName:   "Some",
+		LastName:    "Tester",
+		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     3,
+	}
+
+	testLogger := slog.Default()
+	mockPersonDatastore := &mockDatastore[db.Person, uuid.UUID]{}
+	mockPersonDatastore.On("Get", mock.Anything, testUUID).Return(&current, error(nil))
+	mockPersonDatastore.On("Get", mock.Anything, dneUUID).Return((*db.Person)(nil), db.ErrNoResultsFound)
+	// Fields that aren't patched must keep their value, rather than being reset like they would be by an update
+	mockPersonDatastore.On("Update", mock.Anything, mock.MatchedBy(func(p *db.Person) bool {
+		return p.ID == testUUID && p.FirstName == "Another" && p.LastName == "Tester" &&
+			p.DateOfBirth.Equal(current.DateOfBirth) && !*p.Removed && p.Version == 3
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*db.Person).Version = 4
+	}).Return(error(nil))
+	mockDB := &mockDatabase{person: mockPersonDatastore}
+
+	patched := dataResponse[person]{
+		baseResponse: baseResponse{Success: true},
+		Data: person{
+			ID:          testUUID.String(),
+			FirstName:   "Another",
+			LastName:    "Tester",
+			DateOfBirth: "1/1/1970",
+		},
+	}
+	request := func(contentType string, body string) *http.Request {
+		return withHeader(httptest.NewRequest(http.MethodPatch, "/person/{id}", strings.NewReader(body)), "Content-Type", contentType)
+	}
+	failed := func(statusCode int, message string) wantResp[dataResponse[person]] {
+		return wantResp[dataResponse[person]]{
+			statusCode: statusCode,
+			data:       dataResponse[person]{baseResponse: baseResponse{Message: message}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		id       uuid.UUID
+		r        *http.Request
+		wantResp wantResp[dataResponse[person]]
+	}{
+		{
+			name: "Merge Patch",
+			id:   testUUID,
+			r:    request(mergePatchType, `{"firstName": "Another"}`),
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusOK,
+				headers:    map[string]string{"ETag": `"4"`},
+				data:       patched,
+			},
+		},
+		{
+			name: "JSON Patch",
+			id:   testUUID,
+			r: request(jsonPatchType+"; charset=utf-8", `[
+				{"op": "test", "path": "/id", "value": "`+testUUID.String()+`"},
+				{"op": "test", "path": "/firstName", "value": "Some"},
+				{"op": "replace", "path": "/firstName", "value": "Another"}
+			]`),
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusOK,
+				headers:    map[string]string{"ETag": `"4"`},
+				data:       patched,
+			},
+		},
+		{
+			name:     "Test Failed",
+			id:       testUUID,
+			r:        request(jsonPatchType, `[{"op": "test", "path": "/firstName", "value": "Testy"}, {"op": "replace", "path": "/firstName", "value": "Another"}]`),
+			wantResp: failed(http.StatusConflict, "the request conflicts with the current state of the resource"),
+		},
+		{
+			name:     "Read-only ID",
+			id:       testUUID,
+			r:        request(mergePatchType, `{"id": "`+dneUUID.String()+`"}`),
+			wantResp: failed(http.StatusUnprocessableEntity, "malformed request data"),
+		},
+		{
+			name:     "Read-only Removed",
+			id:       testUUID,
+			r:        request(jsonPatchType, `[{"op": "replace", "path": "/removed", "value": true}]`),
+			wantResp: failed(http.StatusUnprocessableEntity, "malformed request data"),
+		},
+		{
+			name:     "Read-only Timestamp",
+			id:       testUUID,
+			r:        request(jsonPatchType, `[{"op": "add", "path": "/removedAt", "value": "2024-01-01T00:00:00Z"}]`),
+			wantResp: failed(http.StatusUnprocessableEntity, "malformed request data"),
+		},
+		{
+			name:     "Unknown Field",
+			id:       testUUID,
+			r:        request(jsonPatchType, `[{"op": "add", "path": "/nickname", "value": "Testy"}]`),
+			wantResp: failed(http.StatusUnprocessableEntity, "malformed request data"),
+		},
+		{
+			name:     "Missing Member",
+			id:       testUUID,
+			r:        request(jsonPatchType, `[{"op": "remove", "path": "/nickname"}]`),
+			wantResp: failed(http.StatusUnprocessableEntity, "malformed request data"),
+		},
+		{
+			name:     "Invalid Result",
+			id:       testUUID,
+			r:        request(mergePatchType, `{"dob": "1/1/1970"}`),
+			wantResp: failed(http.StatusUnprocessableEntity, "malformed request data"),
+		},
+		{
+			name:     "Invalid Operation",
+			id:       testUUID,
+			r:        request(jsonPatchType, `[{"op": "rename", "path": "/firstName"}]`),
+			wantResp: failed(http.StatusBadRequest, "failed to read request"),
+		},
+		{
+			name:     "Malformed Patch",
+			id:       testUUID,
+			r:        request(jsonPatchType, `{"op": "replace"`),
+			wantResp: failed(http.StatusBadRequest, "failed to read request"),
+		},
+		{
+			name: "Unsupported Content Type",
+			id:   testUUID,
+			r:    request("application/json", `{"firstName": "Another"}`),
+			wantResp: wantResp[dataResponse[person]]{
+				statusCode: http.StatusUnsupportedMediaType,
+				headers:    map[string]string{"Accept-Patch": patchTypes},
+				data:       dataResponse[person]{baseResponse: baseResponse{Message: "unsupported content type"}},
+			},
+		},
+		{
+			name:     "If-Match Stale",
+			id:       testUUID,
+			r:        withHeader(request(mergePatchType, `{"firstName": "Another"}`), "If-Match", `"2"`),
+			wantResp: failed(http.StatusPreconditionFailed, "the resource was changed by another request"),
+		},
+		{
+			name:     "ID not in Database",
+			id:       dneUUID,
+			r:        request(mergePatchType, `{"firstName": "Another"}`),
+			wantResp: failed(http.StatusNotFound, "not found"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			/*Setup request context and headers*/
+			chiContext := chi.NewRouteContext()
+			chiContext.URLParams.Add("id", tt.id.String())
+			r := tt.r.WithContext(context.WithValue(tt.r.Context(), chi.RouteCtxKey, chiContext))
+			w := httptest.NewRecorder()
+
+			pdh := personDataHandler{database: mockDB, logger: testLogger}
+			pdh.Patch(w, r)
+			assertResponse(t, tt.wantResp, w)
+		})
+	}
+}
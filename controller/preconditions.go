@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errPreconditionFailed indicates that the If-Match header of a request didn't match the current version of an item
+var errPreconditionFailed = errors.New("precondition failed")
+
+// entityTag formats the version of an item as a strong HTTP entity tag
+func entityTag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch holds the entity tags from the If-Match header of a request
+type ifMatch struct {
+	present  bool
+	wildcard bool
+	versions []int
+}
+
+// parseIfMatch reads the If-Match header of a request. Since If-Match uses strong comparison,
+// weak entity tags, along with tags that were not created by entityTag, can never match.
+func parseIfMatch(r *http.Request) ifMatch {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return ifMatch{}
+	}
+
+	precondition := ifMatch{present: true}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			precondition.wildcard = true
+			continue
+		}
+
+		unquoted, ok := strings.CutPrefix(tag, `"`)
+		if !ok {
+			continue
+		}
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+		if !ok {
+			continue
+		}
+		if version, err := strconv.Atoi(unquoted); err == nil {
+			precondition.versions = append(precondition.versions, version)
+		}
+	}
+
+	return precondition
+}
+
+// matches reports whether an item at the given version satisfies the precondition
+func (im ifMatch) matches(version int) bool {
+	if !im.present || im.wildcard {
+		return true
+	}
+	for _, v := range im.versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
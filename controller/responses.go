@@ -1,25 +1,105 @@
 package controller
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/xml"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/williabk198/go-api-server-template/db"
 )
 
 // baseResponse is a type provides a basic response message back to the client
 type baseResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"msg,omitempty"`
+	Success bool   `json:"success" xml:"success"`
+	Message string `json:"msg,omitempty" xml:"msg,omitempty"`
+}
+
+// validationResponse is an error response to invalid request data, which lists every problem with it.
+// It is only sent in the legacy error format, since problem details hold the same list.
+type validationResponse struct {
+	baseResponse
+	Errors validationError `json:"errors" xml:"errors"`
 }
 
 // dataResponse is a type that will provide the requested data back to the client
 type dataResponse[T any] struct {
 	baseResponse
-	Data T `json:"data"`
+	Data T `json:"data" xml:"data"`
+}
+
+// listItems implements lister, if the data is a list of items or a page of one
+func (d dataResponse[T]) listItems() any {
+	if list, ok := any(d.Data).(lister); ok {
+		return list.listItems()
+	}
+	return d.Data
+}
+
+// pageResponse is a page of a list of items, along with the cursors that request the pages around it
+type pageResponse[T any] struct {
+	Items []T    `json:"items" xml:"items>item"`
+	Total int    `json:"total" xml:"total"`
+	Next  string `json:"next,omitempty" xml:"next,omitempty"`
+	Prev  string `json:"prev,omitempty" xml:"prev,omitempty"`
+}
+
+// listItems implements lister. Only the items of the page are listed, so a list sent as CSV has no cursors.
+func (p pageResponse[T]) listItems() any {
+	return p.Items
+}
+
+// historyEntry is a single change in the history of an item
+type historyEntry[T any] struct {
+	Version   int           `json:"version" xml:"version"`
+	Change    string        `json:"change" xml:"change"`
+	Principal string        `json:"principal,omitempty" xml:"principal,omitempty"`
+	Timestamp string        `json:"timestamp" xml:"timestamp"`
+	Data      T             `json:"data" xml:"data"`
+	Diff      []fieldChange `json:"diff" xml:"diff>change"`
+}
+
+// changeEvent is a change made to an item, as it is sent on an event stream
+type changeEvent[T any] struct {
+	Change    string `json:"change"`
+	ID        string `json:"id"`
+	Principal string `json:"principal,omitempty"`
+	Timestamp string `json:"timestamp"`
+	Data      *T     `json:"data,omitempty"` // The item after the change, which is left out if it was purged
+}
+
+// fieldChange is the change a history entry made to a single field
+type fieldChange struct {
+	Field string `json:"field" xml:"field"`
+	Old   any    `json:"old" xml:"old"`
+	New   any    `json:"new" xml:"new"`
+}
+
+// batchResult is the outcome of a single operation in a batch
+type batchResult[T any] struct {
+	Index  int    `json:"index" xml:"index"`
+	Status int    `json:"status" xml:"status"`
+	ID     string `json:"id,omitempty" xml:"id,omitempty"`
+	Data   *T     `json:"data,omitempty" xml:"data,omitempty"`
+	Error  string `json:"error,omitempty" xml:"error,omitempty"`
+	// Errors lists the problems with the data of an operation that was rejected because the data is invalid
+	Errors validationError `json:"errors,omitempty" xml:"errors,omitempty"`
+}
+
+// formatTimestamp formats a point in time for a response, or returns an empty string if it isn't set
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
 }
 
 // sendDataResponse is a convenience function that sends a response back to the client with the requested data
-func sendDataResponse[T any](respData T, jsonEncoder *json.Encoder) error {
-	return jsonEncoder.Encode(
+func sendDataResponse[T any](respData T, encoder responseEncoder) error {
+	return encoder.Encode(
 		dataResponse[T]{
 			baseResponse: baseResponse{
 				Success: true,
@@ -29,19 +109,135 @@ func sendDataResponse[T any](respData T, jsonEncoder *json.Encoder) error {
 	)
 }
 
+// errorMessages holds the message that is sent back to the client along with each error status code
+var errorMessages = map[int]string{
+	http.StatusBadRequest:           "failed to read request",
+	http.StatusUnauthorized:         "authentication required",
+	http.StatusForbidden:            "permission denied",
+	http.StatusNotFound:             "not found",
+	http.StatusNotAcceptable:        "none of the accepted content types can be sent",
+	http.StatusConflict:             "the request conflicts with the current state of the resource",
+	http.StatusPreconditionFailed:   "the resource was changed by another request",
+	http.StatusUnsupportedMediaType: "unsupported content type",
+	http.StatusUnprocessableEntity:  "malformed request data",
+	http.StatusFailedDependency:     "not applied because another item in the batch failed",
+	http.StatusInternalServerError:  "server encountered an error processing the request",
+	http.StatusServiceUnavailable:   "the service is temporarily unavailable, please try again later",
+	http.StatusGatewayTimeout:       "the request took too long to process",
+}
+
+// statusForError maps an error that occurred while handling a request, usually one returned by the database,
+// to the status code of the response. Errors that aren't known to be the client's fault are internal server errors.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, db.ErrNoResultsFound):
+		return http.StatusNotFound
+	case errors.Is(err, errPreconditionFailed), errors.Is(err, db.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.As(err, new(validationError)):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errMalformedBody), errors.Is(err, errInvalidListRequest), errors.Is(err, errInvalidBatchRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, errUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, errInvalidPatch):
+		return http.StatusBadRequest
+	case errors.Is(err, errPatchTestFailed):
+		return http.StatusConflict
+	case errors.Is(err, errUnprocessablePatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrInvalidArgument):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, db.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, db.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// problem is an error response in the format of problem details (RFC 9457). Its type is always "about:blank",
+// since the status code says what kind of problem it is, while the extension members hold whatever else
+// there is to know about it.
+type problem struct {
+	Type     string `json:"type" xml:"type"`
+	Title    string `json:"title" xml:"title"`
+	Status   int    `json:"status" xml:"status"`
+	Detail   string `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string `json:"instance,omitempty" xml:"instance,omitempty"`
+	// RequestID identifies the request, as set by the RequestID middleware of chi
+	RequestID string          `json:"requestId,omitempty" xml:"requestId,omitempty"`
+	Errors    validationError `json:"errors,omitempty" xml:"errors,omitempty"`
+}
+
+// xmlName implements xmlNamer, since problem details in XML have a root element of their own
+func (p problem) xmlName() xml.Name {
+	return xml.Name{Space: problemXMLNamespace, Local: "problem"}
+}
+
+// newProblem returns the problem details of a request that failed with the given status code
+func newProblem(r *http.Request, statusCode int) problem {
+	return problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    errorMessage(statusCode),
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// errorMessage returns the message that is sent back to the client along with an error status code
+func errorMessage(statusCode int) string {
+	if message, ok := errorMessages[statusCode]; ok {
+		return message
+	}
+	return strings.ToLower(http.StatusText(statusCode))
+}
+
+// acceptsProblems reports whether the client that made r should be sent problem details in the media type of c.
+// Only clients that explicitly refuse them, with a quality of zero, are sent the legacy shape.
+func acceptsProblems(r *http.Request, c codec) bool {
+	return !parseAccept(r).refuses(c.problemType)
+}
+
 // sendErrorResponse is a convenience to send an error back to the client
-func sendErrorResponse(w http.ResponseWriter, statusCode int, jsonEncoder *json.Encoder) error {
-	w.WriteHeader(statusCode)
-	switch statusCode {
-	case http.StatusBadRequest:
-		return jsonEncoder.Encode(baseResponse{Message: "failed to read request"})
-	case http.StatusNotFound:
-		return jsonEncoder.Encode(baseResponse{Message: "not found"})
-	case http.StatusUnprocessableEntity:
-		return jsonEncoder.Encode(baseResponse{Message: "malformed request data"})
-	case http.StatusInternalServerError:
-		return jsonEncoder.Encode(baseResponse{Message: "server encountered an error processing the request"})
+func sendErrorResponse(w http.ResponseWriter, r *http.Request, format ErrorFormat, statusCode int, encoder responseEncoder) error {
+	return sendProblem(w, r, format, newProblem(r, statusCode), encoder)
+}
+
+// sendValidationErrorResponse sends the problems found in invalid request data back to the client
+func sendValidationErrorResponse(w http.ResponseWriter, r *http.Request, format ErrorFormat, problems validationError, encoder responseEncoder) error {
+	details := newProblem(r, http.StatusUnprocessableEntity)
+	details.Errors = problems
+	return sendProblem(w, r, format, details, encoder)
+}
+
+// sendProblem sends an error back to the client in the media type of encoder, or in JSON if that media type
+// can't express errors. It is sent as problem details unless the legacy format is configured or the client
+// refuses them.
+func sendProblem(w http.ResponseWriter, r *http.Request, format ErrorFormat, details problem, encoder responseEncoder) error {
+	if encoder.codec.problemType == "" {
+		encoder = jsonResponse(w)
+	}
+
+	if format == ErrorFormatLegacy || !acceptsProblems(r, encoder.codec) {
+		w.Header().Set("Content-Type", encoder.codec.mediaType)
+		w.WriteHeader(details.Status)
+		if details.Errors != nil {
+			return encoder.Encode(validationResponse{baseResponse: baseResponse{Message: details.Detail}, Errors: details.Errors})
+		}
+		return encoder.Encode(baseResponse{Message: details.Detail})
 	}
 
-	return nil
+	w.Header().Set("Content-Type", encoder.codec.problemType)
+	w.WriteHeader(details.Status)
+	return encoder.Encode(details)
 }
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+func Test_statusForError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "No Results", err: db.ErrNoResultsFound, want: http.StatusNotFound},
+		{name: "Precondition Failed", err: errPreconditionFailed, want: http.StatusPreconditionFailed},
+		{name: "Version Conflict", err: fmt.Errorf("failed to update person: %w", db.ErrVersionConflict), want: http.StatusPreconditionFailed},
+		{name: "Invalid Data", err: validationError{{Field: "firstName", Code: codeRequired, Message: "is required"}}, want: http.StatusUnprocessableEntity},
+		{name: "Invalid Patched Data", err: fmt.Errorf("%w: %w", errUnprocessablePatch, validationError{}), want: http.StatusUnprocessableEntity},
+		{name: "Malformed Body", err: fmt.Errorf("%w: unexpected EOF", errMalformedBody), want: http.StatusBadRequest},
+		{name: "Invalid List Request", err: fmt.Errorf("%w: %w", errInvalidListRequest, errInvalidCursor), want: http.StatusBadRequest},
+		{name: "Invalid Batch Request", err: fmt.Errorf("%w: unknown batch mode", errInvalidBatchRequest), want: http.StatusBadRequest},
+		{name: "Invalid Patch", err: fmt.Errorf("operation 0: %w", errInvalidPatch), want: http.StatusBadRequest},
+		{name: "Patch Test Failed", err: errPatchTestFailed, want: http.StatusConflict},
+		{name: "Unprocessable Patch", err: errUnprocessablePatch, want: http.StatusUnprocessableEntity},
+		{name: "Already Removed", err: db.ErrAlreadyRemoved, want: http.StatusConflict},
+		{name: "Conflict", err: fmt.Errorf("%w: unique violation", db.ErrConflict), want: http.StatusConflict},
+		{name: "Invalid Query", err: fmt.Errorf("%w: unknown field", db.ErrInvalidQuery), want: http.StatusBadRequest},
+		{name: "Invalid Argument", err: db.ErrInvalidArgument, want: http.StatusUnprocessableEntity},
+		{name: "Permission Denied", err: db.ErrPermissionDenied, want: http.StatusForbidden},
+		{name: "Unavailable", err: fmt.Errorf("%w: connection refused", db.ErrUnavailable), want: http.StatusServiceUnavailable},
+		{name: "Deadline Exceeded", err: db.ErrDeadlineExceeded, want: http.StatusGatewayTimeout},
+		{name: "Context Deadline Exceeded", err: context.DeadlineExceeded, want: http.StatusGatewayTimeout},
+		{name: "Unknown", err: fmt.Errorf("mockError"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statusForError(tt.err))
+		})
+	}
+}
+
+func Test_sendErrorResponse(t *testing.T) {
+	request := func(accept ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/person/123?sort=id", nil)
+		for _, value := range accept {
+			r.Header.Add("Accept", value)
+		}
+		return r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, "host/abc-000001"))
+	}
+	notFound := problem{
+		Type:      "about:blank",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "not found",
+		Instance:  "/person/123",
+		RequestID: "host/abc-000001",
+	}
+
+	tests := []struct {
+		name       string
+		r          *http.Request
+		format     ErrorFormat
+		statusCode int
+		want       any
+	}{
+		{name: "Problem", r: request(), statusCode: http.StatusNotFound, want: notFound},
+		{name: "Problem Accepted", r: request("application/json", "application/problem+json"), statusCode: http.StatusNotFound, want: notFound},
+		{name: "Any Type Accepted", r: request("application/json, */*;q=0.1"), statusCode: http.StatusNotFound, want: notFound},
+		{
+			name:       "Unknown Status",
+			r:          request(),
+			statusCode: http.StatusTooManyRequests,
+			want: problem{
+				Type:      "about:blank",
+				Title:     "Too Many Requests",
+				Status:    http.StatusTooManyRequests,
+				Detail:    "too many requests",
+				Instance:  "/person/123",
+				RequestID: "host/abc-000001",
+			},
+		},
+		{name: "Legacy Format", r: request(), format: ErrorFormatLegacy, statusCode: http.StatusNotFound, want: baseResponse{Message: "not found"}},
+		{name: "Legacy Format Even If Accepted", r: request(problemJSONType), format: ErrorFormatLegacy, statusCode: http.StatusNotFound, want: baseResponse{Message: "not found"}},
+		{name: "Only JSON Accepted", r: request("application/json"), statusCode: http.StatusNotFound, want: notFound},
+		{name: "Problem Refused", r: request("application/problem+json;q=0, application/json"), statusCode: http.StatusNotFound, want: baseResponse{Message: "not found"}},
+		{name: "Legacy Unknown Status", r: request(), format: ErrorFormatLegacy, statusCode: http.StatusTooManyRequests, want: baseResponse{Message: "too many requests"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			assert.NoError(t, sendErrorResponse(w, tt.r, tt.format, tt.statusCode, jsonResponse(w)))
+			assertErrorBody(t, w, tt.statusCode, tt.want)
+		})
+	}
+}
+
+func Test_sendValidationErrorResponse(t *testing.T) {
+	problems := validationError{{Field: "firstName", Code: codeRequired, Message: "is required"}}
+
+	t.Run("Problem", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/person", nil)
+		assert.NoError(t, sendValidationErrorResponse(w, r, ErrorFormatProblem, problems, jsonResponse(w)))
+		assertErrorBody(t, w, http.StatusUnprocessableEntity, problem{
+			Type:     "about:blank",
+			Title:    "Unprocessable Entity",
+			Status:   http.StatusUnprocessableEntity,
+			Detail:   "malformed request data",
+			Instance: "/person",
+			Errors:   problems,
+		})
+	})
+
+	t.Run("Legacy", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/person", nil)
+		assert.NoError(t, sendValidationErrorResponse(w, r, ErrorFormatLegacy, problems, jsonResponse(w)))
+		assertErrorBody(t, w, http.StatusUnprocessableEntity, validationResponse{
+			baseResponse: baseResponse{Message: "malformed request data"},
+			Errors:       problems,
+		})
+	})
+}
+
+// assertErrorBody checks the status code of an error response, and that its body is want, as either
+// problem details or a legacy error response depending on the type of want
+func assertErrorBody(t *testing.T, w *httptest.ResponseRecorder, statusCode int, want any) {
+	t.Helper()
+
+	assert.Equal(t, statusCode, w.Code)
+	switch want := want.(type) {
+	case problem:
+		assert.Equal(t, problemJSONType, w.Header().Get("Content-Type"))
+		var got problem
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, want, got)
+	case baseResponse:
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var got baseResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, want, got)
+	case validationResponse:
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var got validationResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, want, got)
+	}
+}
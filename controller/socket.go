@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// The types of the messages of the socket protocol
+const (
+	// socketSubscribe asks for the events of the items listed in IDs, or of every item if IDs is empty
+	socketSubscribe = "subscribe"
+	// socketUnsubscribe stops the events of the items listed in IDs, or every event if IDs is empty.
+	// Unsubscribing from an item doesn't exclude it from a subscription to every item.
+	socketUnsubscribe = "unsubscribe"
+	// socketEvent is sent by the server for every change made to an item the client subscribed to
+	socketEvent = "event"
+	// socketError is sent by the server when it couldn't handle a message
+	socketError = "error"
+	// socketPing is answered with another ping, so that clients can tell whether the connection still works
+	socketPing = "ping"
+)
+
+const (
+	// socketWriteTimeout is how long writing a single message to a socket may take
+	socketWriteTimeout = 10 * time.Second
+	// socketCloseTimeout is how long the client has to acknowledge that the socket is closed
+	socketCloseTimeout = time.Second
+	// socketReadLimit is the maximum size of a message sent by a client
+	socketReadLimit = 64 << 10
+)
+
+// socketMessage is a message sent over a socket, in either direction
+type socketMessage[T any] struct {
+	Type string `json:"type"`
+	// Ref is chosen by the client, and copied to the replies to its message
+	Ref      string          `json:"ref,omitempty"`
+	IDs      []string        `json:"ids,omitempty"`
+	Sequence uint64          `json:"sequence,omitempty"`
+	Event    *changeEvent[T] `json:"event,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// socketUpgrader accepts sockets from any origin, like the CORS settings of the router do for other requests
+var socketUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// errSocketTooSlow ends a socket whose client doesn't keep up with its events
+var errSocketTooSlow = errors.New("client is too slow")
+
+// socketSession serves the socket protocol to a single client. It reads the messages of the client and the events
+// of the feed in one goroutine, and writes to the client from another through a bounded buffer, so that a client
+// that doesn't keep up is disconnected rather than holding up the feed.
+type socketSession[T db.Entity, U db.Identifier, R any] struct {
+	conn      *websocket.Conn
+	logger    *slog.Logger
+	heartbeat time.Duration
+	parseID   func(string) (U, error)
+	convert   func(*db.Event[T, U]) changeEvent[R]
+
+	send chan socketMessage[R]
+	all  bool
+	ids  map[U]bool
+}
+
+// serve runs the session until ctx is done, the client disconnects or falls behind, or the subscription ends.
+// The socket is closed when it returns.
+func (s *socketSession[T, U, R]) serve(ctx context.Context, subscription db.Subscription[T, U]) {
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writer sync.WaitGroup
+	incoming := make(chan socketMessage[R])
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		defer cancel()
+		s.read(sessionCtx, incoming)
+	}()
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		defer cancel()
+		s.write(sessionCtx)
+	}()
+
+	err := s.run(sessionCtx, incoming, subscription)
+	cancel()
+
+	// Let the client know why the socket is closed, and give it a moment to acknowledge.
+	// This fails if the client already closed the socket, in which case there's nothing to wait for.
+	code, text := websocket.CloseNormalClosure, ""
+	switch {
+	case errors.Is(err, errSocketTooSlow), errors.Is(err, db.ErrSubscriberOverflow):
+		code, text = websocket.ClosePolicyViolation, errSocketTooSlow.Error()
+	case errors.Is(err, db.ErrUnavailable), errors.Is(err, db.ErrFeedClosed):
+		code, text = websocket.CloseTryAgainLater, "events are unavailable"
+	case err != nil:
+		s.logger.Warn("person socket ended", "error", err)
+		code = websocket.CloseInternalServerErr
+	case ctx.Err() != nil:
+		code, text = websocket.CloseGoingAway, "server is shutting down"
+	}
+	closeMessage := websocket.FormatCloseMessage(code, text)
+	if s.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(socketWriteTimeout)) == nil {
+		select {
+		case <-readerDone:
+		case <-time.After(socketCloseTimeout):
+		}
+	}
+
+	s.conn.Close()
+	<-readerDone
+	writer.Wait()
+}
+
+// run handles the messages of the client and the events of the subscription until the session ends.
+// It returns nil if the session ended because ctx is done or the client disconnected.
+func (s *socketSession[T, U, R]) run(ctx context.Context, incoming <-chan socketMessage[R], subscription db.Subscription[T, U]) error {
+	for {
+		var reply *socketMessage[R]
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-incoming:
+			reply = s.handle(msg)
+		case event, ok := <-subscription.Events():
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return subscription.Err()
+			}
+			if s.all || s.ids[event.ID] {
+				data := s.convert(&event)
+				reply = &socketMessage[R]{Type: socketEvent, Sequence: event.Sequence, Event: &data}
+			}
+		}
+
+		if reply == nil {
+			continue
+		}
+		select {
+		case s.send <- *reply:
+		default:
+			return errSocketTooSlow
+		}
+	}
+}
+
+// handle applies a message of the client, and returns the reply to it, if any
+func (s *socketSession[T, U, R]) handle(msg socketMessage[R]) *socketMessage[R] {
+	fail := func(text string) *socketMessage[R] {
+		return &socketMessage[R]{Type: socketError, Ref: msg.Ref, Error: text}
+	}
+
+	switch msg.Type {
+	case socketSubscribe, socketUnsubscribe:
+	case socketPing:
+		return &socketMessage[R]{Type: socketPing, Ref: msg.Ref}
+	case "":
+		return fail("message is not valid")
+	default:
+		return fail("unknown message type " + msg.Type)
+	}
+
+	// Every ID is checked before any of them are applied, so that a message is either applied as a whole or not at all
+	ids := make([]U, 0, len(msg.IDs))
+	for _, value := range msg.IDs {
+		id, err := s.parseID(value)
+		if err != nil {
+			return fail("invalid id " + value)
+		}
+		ids = append(ids, id)
+	}
+
+	switch {
+	case msg.Type == socketSubscribe && len(ids) == 0:
+		s.all = true
+	case msg.Type == socketSubscribe:
+		for _, id := range ids {
+			s.ids[id] = true
+		}
+	case len(ids) == 0:
+		s.all = false
+		clear(s.ids)
+	default:
+		for _, id := range ids {
+			delete(s.ids, id)
+		}
+	}
+	return nil
+}
+
+// read passes the messages of the client to run until the client disconnects or ctx is done.
+// The client has to answer the pings sent by write, or send something else, within two heartbeats.
+func (s *socketSession[T, U, R]) read(ctx context.Context, incoming chan<- socketMessage[R]) {
+	s.conn.SetReadLimit(socketReadLimit)
+	extend := func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(2 * s.heartbeat))
+	}
+	extend("")
+	s.conn.SetPongHandler(extend)
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		extend("")
+
+		var msg socketMessage[R]
+		if err := json.Unmarshal(data, &msg); err != nil {
+			msg = socketMessage[R]{} // Reported as invalid by handle
+		}
+
+		select {
+		case incoming <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// write sends the buffered messages to the client, along with a ping every heartbeat, until ctx is done
+func (s *socketSession[T, U, R]) write(ctx context.Context) {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-s.send:
+			s.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			err = s.conn.WriteJSON(msg)
+		case <-ticker.C:
+			err = s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteTimeout))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
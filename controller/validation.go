@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// The codes of the problems validation reports, so that clients can handle them without parsing the messages
+const (
+	codeRequired          = "required"
+	codeTooLong           = "too_long"
+	codeInvalidFormat     = "invalid_format"
+	codeOutOfRange        = "out_of_range"
+	codeInvalidCharacters = "invalid_characters"
+	codeReadOnly          = "read_only"
+)
+
+// fieldError is a single problem with a field of the request data
+type fieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Code    string `json:"code" xml:"code"`
+	Message string `json:"message" xml:"message"`
+}
+
+// validationError lists every problem found in the request data. It is sent back to the client as is, along with
+// a 422 status code.
+type validationError []fieldError
+
+func (e validationError) Error() string {
+	problems := make([]string, len(e))
+	for i, problem := range e {
+		problems[i] = fmt.Sprintf("%s: %s", problem.Field, problem.Message)
+	}
+	return "invalid request data: " + strings.Join(problems, "; ")
+}
+
+// xmlProblems is the XML form of a validationError, in which every problem is an error element
+type xmlProblems struct {
+	Problems []fieldError `xml:"error"`
+}
+
+// MarshalXML implements xml.Marshaler. The errors>error tag of encoding/xml would do the same, but it writes
+// an empty errors element when there are no problems, even if the field is tagged omitempty.
+func (e validationError) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	return encoder.EncodeElement(xmlProblems{Problems: e}, start)
+}
+
+// UnmarshalXML implements xml.Unmarshaler
+func (e *validationError) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var problems xmlProblems
+	if err := decoder.DecodeElement(&problems, &start); err != nil {
+		return err
+	}
+
+	*e = problems.Problems
+	return nil
+}
+
+// within returns the problems with the paths of their fields prefixed by path, for data that is nested in a request
+func (e validationError) within(path string) validationError {
+	result := make(validationError, len(e))
+	for i, problem := range e {
+		problem.Field = path + "." + problem.Field
+		result[i] = problem
+	}
+	return result
+}
+
+// rule checks a single value, and returns the code and message of the problem with it, or an empty code if there is none.
+// Rules other than required accept the zero value, so that they can be applied to optional fields.
+type rule[V any] func(value V) (code, message string)
+
+// validator collects the problems found in the fields of the request data, so that they are all reported at once
+type validator struct {
+	problems validationError
+}
+
+// check applies the rules to the value of a field in order, and records the first one that fails.
+// Since the rest are skipped, later rules may assume that earlier ones passed, for example that a date can be parsed.
+func check[V any](v *validator, field string, value V, rules ...rule[V]) {
+	for _, rule := range rules {
+		if code, message := rule(value); code != "" {
+			v.add(field, code, message)
+			return
+		}
+	}
+}
+
+// add records a problem with a field
+func (v *validator) add(field, code, message string) {
+	v.problems = append(v.problems, fieldError{Field: field, Code: code, Message: message})
+}
+
+// err returns a validationError if any problems were found, and nil otherwise
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return v.problems
+}
+
+// required rejects the zero value
+func required[V comparable]() rule[V] {
+	return func(value V) (string, string) {
+		var zero V
+		if value == zero {
+			return codeRequired, "is required"
+		}
+		return "", ""
+	}
+}
+
+// maxLength rejects strings that are longer than n characters
+func maxLength(n int) rule[string] {
+	return func(value string) (string, string) {
+		if utf8.RuneCountInString(value) > n {
+			return codeTooLong, fmt.Sprintf("must be at most %d characters long", n)
+		}
+		return "", ""
+	}
+}
+
+// printable rejects strings that hold control characters, such as line breaks, or that aren't valid UTF-8
+func printable() rule[string] {
+	return func(value string) (string, string) {
+		if !utf8.ValidString(value) || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return codeInvalidCharacters, "must not contain control characters"
+		}
+		return "", ""
+	}
+}
+
+// uuidFormat rejects strings that aren't a UUID
+func uuidFormat() rule[string] {
+	return func(value string) (string, string) {
+		if value == "" {
+			return "", ""
+		}
+		if _, err := uuid.Parse(value); err != nil {
+			return codeInvalidFormat, "must be a UUID"
+		}
+		return "", ""
+	}
+}
+
+// dateFormat rejects strings that aren't a date in the given layout
+func dateFormat(layout string) rule[string] {
+	return func(value string) (string, string) {
+		if value == "" {
+			return "", ""
+		}
+		if _, err := time.Parse(layout, value); err != nil {
+			return codeInvalidFormat, "must be a date formatted as " + layout
+		}
+		return "", ""
+	}
+}
+
+// dateRange rejects dates in the given layout that are before earliest or after latest.
+// Values that can't be parsed are left to dateFormat.
+func dateRange(layout string, earliest, latest time.Time) rule[string] {
+	return func(value string) (string, string) {
+		date, err := time.Parse(layout, value)
+		if value == "" || err != nil {
+			return "", ""
+		}
+		if date.Before(earliest) || date.After(latest) {
+			return codeOutOfRange, fmt.Sprintf("must be between %s and %s", earliest.Format(layout), latest.Format(layout))
+		}
+		return "", ""
+	}
+}
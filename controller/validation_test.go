@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_person_validate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		person person
+		want   validationError
+	}{
+		{
+			name:   "Valid",
+			person: person{ID: "b5b2f0a4-4b7e-4a0e-9b0e-3f7c1f4e2d11", FirstName: "Testy", LastName: "McTesterson", DateOfBirth: "1970-01-01"},
+		},
+		{
+			name:   "Optional Fields",
+			person: person{FirstName: "Testy", LastName: "McTesterson"},
+		},
+		{
+			name:   "Multibyte Name",
+			person: person{FirstName: strings.Repeat("é", maxNameLength), LastName: "McTesterson"},
+		},
+		{
+			name:   "Every Field",
+			person: person{ID: "not-a-uuid", LastName: strings.Repeat("a", 10<<10), DateOfBirth: "1/1/1970"},
+			want: validationError{
+				{Field: "id", Code: codeInvalidFormat, Message: "must be a UUID"},
+				{Field: "firstName", Code: codeRequired, Message: "is required"},
+				{Field: "lastName", Code: codeTooLong, Message: "must be at most 100 characters long"},
+				{Field: "dob", Code: codeInvalidFormat, Message: "must be a date formatted as 2006-01-02"},
+			},
+		},
+		{
+			name:   "Control Characters",
+			person: person{FirstName: "Testy\n", LastName: "Mc\x00Testerson"},
+			want: validationError{
+				{Field: "firstName", Code: codeInvalidCharacters, Message: "must not contain control characters"},
+				{Field: "lastName", Code: codeInvalidCharacters, Message: "must not contain control characters"},
+			},
+		},
+		{
+			name:   "Born Today",
+			person: person{FirstName: "Testy", LastName: "McTesterson", DateOfBirth: "2024-06-01"},
+		},
+		{
+			name:   "Earliest Date of Birth",
+			person: person{FirstName: "Testy", LastName: "McTesterson", DateOfBirth: "1900-01-01"},
+		},
+		{
+			name:   "Future Date of Birth",
+			person: person{FirstName: "Testy", LastName: "McTesterson", DateOfBirth: "2024-06-02"},
+			want:   validationError{{Field: "dob", Code: codeOutOfRange, Message: "must be between 1900-01-01 and 2024-06-01"}},
+		},
+		{
+			name:   "Early Date of Birth",
+			person: person{FirstName: "Testy", LastName: "McTesterson", DateOfBirth: "1899-12-31"},
+			want:   validationError{{Field: "dob", Code: codeOutOfRange, Message: "must be between 1900-01-01 and 2024-06-01"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.person.validate(now)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func Test_validationError_within(t *testing.T) {
+	problems := validationError{{Field: "firstName", Code: codeRequired, Message: "is required"}}
+
+	assert.Equal(t, validationError{{Field: "data.firstName", Code: codeRequired, Message: "is required"}}, problems.within("data"))
+	assert.Equal(t, "firstName", problems[0].Field, "the original problems must not be changed")
+}
+
+func Test_personDataHandler_Add_InvalidData(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+	pdh := personDataHandler{logger: slog.Default(), clock: clock}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/person", strings.NewReader(`{"firstName": "", "lastName": "Tester\n", "dob": "2024-06-02"}`))
+
+	pdh.Add(w, r)
+
+	assertResponse(t, wantResp[validationResponse]{
+		statusCode: http.StatusUnprocessableEntity,
+		data: validationResponse{
+			baseResponse: baseResponse{Message: "malformed request data"},
+			Errors: []fieldError{
+				{Field: "firstName", Code: codeRequired, Message: "is required"},
+				{Field: "lastName", Code: codeInvalidCharacters, Message: "must not contain control characters"},
+				{Field: "dob", Code: codeOutOfRange, Message: "must be between 1900-01-01 and 2024-06-01"},
+			},
+		},
+	}, w)
+}
@@ -10,8 +10,10 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/williabk198/go-api-server-template/auth"
 	"github.com/williabk198/go-api-server-template/controller"
-	"github.com/williabk198/go-api-server-template/db/dummydb"
+	"github.com/williabk198/go-api-server-template/db/cache"
 	"github.com/williabk198/go-api-server-template/router"
 )
 
@@ -24,14 +26,84 @@ func Start() {
 		AddSource: true,
 		Level:     slog.LevelDebug,
 	}))
-	database := dummydb.NewSession() // Update
-	controls := controller.NewController(logger, database)
-	routes := router.NewRouter(controls)
+	database, closeDatabase, err := openDatabase(context.Background(), logger)
+	if err != nil {
+		logger.Error("failed to open the database", "error", err)
+		return
+	}
+	defer func() {
+		if err := closeDatabase(); err != nil {
+			logger.Warn("encountered error while closing the database", "error", err)
+		}
+	}()
+
+	// Every attempt made by the retries is measured, and the cache goes in front of both,
+	// so that the metrics only show the calls that reach the database
+	registry := newRegistry()
+	database, err = instrumentDatabase(database, registry, logger)
+	if err != nil {
+		logger.Error("failed to instrument the database", "error", err)
+		return
+	}
+	database, err = guardDatabase(database, registry, logger)
+	if err != nil {
+		logger.Error("invalid database resilience configuration", "error", err)
+		return
+	}
+
+	cacheOpts, err := cacheOptions()
+	if err != nil {
+		logger.Error("invalid cache configuration", "error", err)
+		return
+	}
+	if cacheOpts != nil {
+		cached := cache.Wrap(database, cacheOpts...)
+		if err := registerCacheStats(registry, cached); err != nil {
+			logger.Error("failed to register the cache metrics", "error", err)
+			return
+		}
+		defer func() {
+			stats := cached.PersonStats()
+			logger.Info("person cache statistics", "hits", stats.Hits, "misses", stats.Misses)
+		}()
+		database = cached
+	}
+
+	// Event streams never finish on their own, so they are ended once a graceful shutdown starts
+	streamCtx, stopStreams := context.WithCancel(context.Background())
+	defer stopStreams()
+
+	controllerOpts := []controller.Option{controller.WithShutdown(streamCtx)}
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		controllerOpts = append(controllerOpts, controller.WithCursorSecret([]byte(secret)))
+	}
+
+	switch format := os.Getenv("ERROR_FORMAT"); format {
+	case "", "problem":
+	case "legacy":
+		controllerOpts = append(controllerOpts, controller.WithErrorFormat(controller.ErrorFormatLegacy))
+	default:
+		logger.Error("invalid error format configuration", "error", fmt.Errorf("ERROR_FORMAT must be problem or legacy, got %q", format))
+		return
+	}
+
+	controls := controller.NewController(logger, database, controllerOpts...)
+
+	routerOpts := []router.Option{
+		router.WithMetrics(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})),
+	}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		routerOpts = append(routerOpts, router.WithAuthenticator(auth.BearerTokens{
+			token: {Name: "admin", Admin: true},
+		}))
+	}
+	routes := router.NewRouter(controls, routerOpts...)
 
 	server := http.Server{
 		Addr:    fmt.Sprintf(":%s", os.Getenv("PORT")),
 		Handler: routes,
 	}
+	server.RegisterOnShutdown(stopStreams)
 
 	// Create an error channel so that fatal server errors can be logged appropriately
 	errChan := make(chan error)
@@ -63,8 +135,12 @@ func Start() {
 	defer cancelCtx()
 
 	// Try to let active requests to finish up and then close.
-	err := server.Shutdown(ctx)
+	err = server.Shutdown(ctx)
 	if err != nil {
 		logger.Warn("encountered error during server shutdown", "error", err)
 	}
+	// Sockets are taken over from the server, so it doesn't wait for them to close
+	if err := controls.Drain(ctx); err != nil {
+		logger.Warn("encountered error while closing event streams and sockets", "error", err)
+	}
 }
@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/cache"
+	"github.com/williabk198/go-api-server-template/db/filedb"
+	"github.com/williabk198/go-api-server-template/db/memdb"
+	"github.com/williabk198/go-api-server-template/db/migrations"
+	"github.com/williabk198/go-api-server-template/db/postgres"
+	"github.com/williabk198/go-api-server-template/db/resilience"
+	"github.com/williabk198/go-api-server-template/db/sqlite"
+)
+
+const (
+	// defaultSQLitePath is the database file used by the sqlite driver when DB_SOURCE is not set
+	defaultSQLitePath = "data.db"
+	// defaultLogPath is the log file used by the file driver when DB_SOURCE is not set
+	defaultLogPath = "data.log"
+)
+
+// openDatabase creates the db.Database selected by the DB_DRIVER environment variable.
+// DB_SOURCE holds the connection string for postgres, or the path of the database file for sqlite and file.
+// SQL databases are migrated to the latest version first, unless DB_MIGRATE is set to false.
+// The returned function must be called to release the database connection once the server is done with it.
+func openDatabase(ctx context.Context, logger *slog.Logger) (db.Database, func() error, error) {
+	source := os.Getenv("DB_SOURCE")
+	driver := os.Getenv("DB_DRIVER")
+
+	switch driver {
+	case "", "memory":
+		return memdb.NewSession(), func() error { return nil }, nil
+	case "file":
+		if source == "" {
+			source = defaultLogPath
+		}
+		fileDB, err := filedb.Open(source, filedb.WithLogger(logger))
+		if err != nil {
+			return nil, nil, err
+		}
+		return fileDB, fileDB.Close, nil
+	case "sqlite", "postgres":
+	default:
+		return nil, nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+
+	conn, migrator, err := openSQL(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if os.Getenv("DB_MIGRATE") != "false" {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		for _, migration := range applied {
+			logger.Info("applied database migration", "version", migration.Version, "name", migration.Name)
+		}
+	}
+
+	var database db.Database
+	if driver == "sqlite" {
+		database, err = sqlite.NewSession(ctx, conn)
+	} else {
+		database, err = postgres.NewSession(ctx, conn)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return database, conn.Close, nil
+}
+
+// openSQL connects to the SQL database selected by the DB_DRIVER and DB_SOURCE environment variables,
+// and returns the Migrator for it. The connection must be closed once it is no longer needed.
+func openSQL(ctx context.Context) (*sql.DB, *migrations.Migrator, error) {
+	source := os.Getenv("DB_SOURCE")
+
+	var conn *sql.DB
+	var migrator *migrations.Migrator
+	var err error
+
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "sqlite":
+		if source == "" {
+			source = defaultSQLitePath
+		}
+		if conn, err = sqlite.Open(ctx, source); err != nil {
+			return nil, nil, err
+		}
+		migrator, err = sqlite.Migrator(conn)
+	case "postgres":
+		if conn, err = postgres.Open(ctx, source); err != nil {
+			return nil, nil, err
+		}
+		migrator, err = postgres.Migrator(conn)
+	default:
+		return nil, nil, fmt.Errorf("%q is not a SQL database driver", driver)
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, migrator, nil
+}
+
+// cacheOptions returns the options of the read-through cache that is put in front of the database.
+// CACHE_SIZE is the number of items cached per datastore, and CACHE_TTL how long they stay cached, as a duration
+// such as "30s". Caching is disabled, and nil is returned, if CACHE_SIZE is not set or zero.
+func cacheOptions() ([]cache.Option, error) {
+	size := os.Getenv("CACHE_SIZE")
+	if size == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(size)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("CACHE_SIZE must be a non-negative number, got %q", size)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	opts := []cache.Option{cache.WithSize(n)}
+
+	if ttl := os.Getenv("CACHE_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("CACHE_TTL must be a non-negative duration, got %q", ttl)
+		}
+		opts = append(opts, cache.WithTTL(d))
+	}
+
+	return opts, nil
+}
+
+// guardDatabase wraps database so that reads that fail with a transient error are retried, and every operation fails
+// fast while the database is down. DB_RETRIES is the number of attempts made at a read (default 3), and
+// DB_BREAKER_THRESHOLD the number of consecutive transient errors (default 5) that open the circuit breaker
+// for DB_BREAKER_COOLDOWN (default 10s). A threshold of 0 disables the circuit breaker.
+func guardDatabase(database db.Database, registry prometheus.Registerer, logger *slog.Logger) (db.Database, error) {
+	metrics, err := resilience.NewMetrics(registry)
+	if err != nil {
+		return nil, err
+	}
+	opts := []resilience.Option{resilience.WithLogger(logger), resilience.WithMetrics(metrics)}
+
+	if value := os.Getenv("DB_RETRIES"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 1 {
+			return nil, fmt.Errorf("DB_RETRIES must be a positive number, got %q", value)
+		}
+		opts = append(opts, resilience.WithRetries(attempts, resilience.DefaultBaseDelay, resilience.DefaultMaxDelay))
+	}
+
+	threshold, cooldown := resilience.DefaultFailureThreshold, resilience.DefaultCooldown
+	if value := os.Getenv("DB_BREAKER_THRESHOLD"); value != "" {
+		if threshold, err = strconv.Atoi(value); err != nil || threshold < 0 {
+			return nil, fmt.Errorf("DB_BREAKER_THRESHOLD must be a non-negative number, got %q", value)
+		}
+	}
+	if value := os.Getenv("DB_BREAKER_COOLDOWN"); value != "" {
+		if cooldown, err = time.ParseDuration(value); err != nil || cooldown <= 0 {
+			return nil, fmt.Errorf("DB_BREAKER_COOLDOWN must be a positive duration, got %q", value)
+		}
+	}
+	opts = append(opts, resilience.WithBreaker(threshold, cooldown))
+
+	return resilience.Wrap(database, resilience.NewGuard(opts...)), nil
+}
@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/cache"
+	"github.com/williabk198/go-api-server-template/db/instrument"
+)
+
+// defaultSlowThreshold is how long a database operation may take before it is logged, when SLOW_QUERY_THRESHOLD is not set
+const defaultSlowThreshold = 200 * time.Millisecond
+
+// newRegistry creates the registry of the metrics that the server exposes, which includes the Go runtime and process metrics.
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
+// instrumentDatabase wraps database so that its operations are recorded in registry. Operations that take longer
+// than SLOW_QUERY_THRESHOLD, a duration such as "250ms", are logged. Setting it to 0 disables the log.
+func instrumentDatabase(database db.Database, registry prometheus.Registerer, logger *slog.Logger) (db.Database, error) {
+	threshold := defaultSlowThreshold
+	if value := os.Getenv("SLOW_QUERY_THRESHOLD"); value != "" {
+		var err error
+		threshold, err = time.ParseDuration(value)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("SLOW_QUERY_THRESHOLD must be a non-negative duration, got %q", value)
+		}
+	}
+
+	metrics, err := instrument.NewMetrics(registry)
+	if err != nil {
+		return nil, err
+	}
+
+	return instrument.Wrap(database, metrics, instrument.WithSlowThreshold(logger, threshold)), nil
+}
+
+// registerCacheStats exposes the hit and miss counters of the cache in registry
+func registerCacheStats(registry prometheus.Registerer, cached *cache.Database) error {
+	labels := prometheus.Labels{"entity": "person"}
+	hits := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   "cache",
+		Name:        "hits_total",
+		Help:        "The number of reads that were answered from the cache.",
+		ConstLabels: labels,
+	}, func() float64 { return float64(cached.PersonStats().Hits) })
+	misses := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   "cache",
+		Name:        "misses_total",
+		Help:        "The number of reads that had to go to the database.",
+		ConstLabels: labels,
+	}, func() float64 { return float64(cached.PersonStats().Misses) })
+
+	for _, collector := range []prometheus.Collector{hits, misses} {
+		if err := registry.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Migrate runs a migration command against the SQL database selected by the DB_DRIVER and DB_SOURCE
+// environment variables, and writes its outcome to out. The supported commands are:
+//
+//	up        applies every pending migration
+//	down [n]  rolls back the latest n migrations, or just the latest one if n is not given
+//	status    lists every migration, and whether it is applied
+func Migrate(ctx context.Context, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command, expected up, down or status")
+	}
+
+	conn, migrator, err := openSQL(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch command := args[0]; command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to roll back: %q", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range rolledBack {
+			fmt.Fprintf(out, "rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied at " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%s: %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// BatchError is returned by the batch operations of a Datastore when some of the items in the batch failed.
+// It holds the error of every item at the index of that item, which is nil for the items that succeeded.
+type BatchError []error
+
+// Error implements error.
+func (b BatchError) Error() string {
+	failed := 0
+	var first error
+	for _, err := range b {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+
+	return fmt.Sprintf("%d of %d items in the batch failed, the first with: %v", failed, len(b), first)
+}
+
+// Unwrap returns the errors of the items that failed, so that errors.Is and errors.As look at every one of them.
+func (b BatchError) Unwrap() []error {
+	var result []error
+	for _, err := range b {
+		if err != nil {
+			result = append(result, err)
+		}
+	}
+	return result
+}
+
+// NewBatchError returns a BatchError that holds the given errors, or nil if none of them is set.
+func NewBatchError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return BatchError(errs)
+		}
+	}
+	return nil
+}
+
+// Batch calls fn with the index of every item of a batch of n items, and collects the errors it returns
+// into a BatchError. It is the loop that datastores without a native way of processing batches fall back to.
+// Batch stops, and returns the context's error instead, as soon as ctx is done.
+func Batch(ctx context.Context, n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	for i := range errs {
+		if err := ContextError(ctx); err != nil {
+			return err
+		}
+		errs[i] = fn(i)
+	}
+
+	return NewBatchError(errs)
+}
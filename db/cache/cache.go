@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+const (
+	// DefaultSize is the number of items a cache holds unless WithSize is used
+	DefaultSize = 1000
+	// DefaultTTL is how long an item stays cached unless WithTTL is used
+	DefaultTTL = time.Minute
+)
+
+// Option configures a cache when it is created
+type Option func(*options)
+
+type options struct {
+	size  int
+	ttl   time.Duration
+	clock db.Clock
+}
+
+// WithSize sets the maximum number of items held by the cache of each datastore.
+// Once it is reached, the least recently used item is evicted to make room for a new one.
+func WithSize(size int) Option {
+	return func(o *options) {
+		o.size = size
+	}
+}
+
+// WithTTL sets how long an item stays cached after it was read from the underlying datastore.
+// A TTL of zero keeps items cached until they are evicted or changed.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithClock sets the clock that is used to expire cached items. By default the system clock is used.
+func WithClock(clock db.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		size:  DefaultSize,
+		ttl:   DefaultTTL,
+		clock: db.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.size < 1 {
+		o.size = 1
+	}
+	return o
+}
+
+// Schema describes how a Datastore accesses the items it caches.
+type Schema[T db.Entity, U db.Identifier] struct {
+	// Key returns the identifier of item.
+	Key func(item *T) U
+	// Copy returns a deep copy of item, so that cached items never share memory with the ones handed to callers.
+	Copy func(item *T) *T
+}
+
+// PersonSchema is the Schema of db.Person.
+var PersonSchema = Schema[db.Person, uuid.UUID]{
+	Key: func(item *db.Person) uuid.UUID {
+		return item.ID
+	},
+	Copy: func(item *db.Person) *db.Person {
+		result := *item
+		if item.Removed != nil {
+			result.Removed = db.NewBool(*item.Removed)
+		}
+		if item.RemovedAt != nil {
+			removedAt := *item.RemovedAt
+			result.RemovedAt = &removedAt
+		}
+		return &result
+	},
+}
+
+// Stats counts how often a cache was able to answer a read by itself.
+type Stats struct {
+	// Hits is the number of reads that were answered from the cache.
+	Hits uint64
+	// Misses is the number of reads that had to go to the underlying datastore, including the ones
+	// that shared the read of another caller.
+	Misses uint64
+}
@@ -0,0 +1,338 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/dbtest"
+	"github.com/williabk198/go-api-server-template/db/memdb"
+)
+
+func TestPersonDatastore(t *testing.T) {
+	dbtest.RunPersonDatastore(t, Wrap(memdb.NewSession()))
+}
+
+func TestPersonQuery(t *testing.T) {
+	dbtest.RunPersonQuery(t, Wrap(memdb.NewSession()))
+}
+
+func TestPersonBatch(t *testing.T) {
+	dbtest.RunPersonBatch(t, Wrap(memdb.NewSession()))
+}
+
+func TestPersonErrors(t *testing.T) {
+	dbtest.RunPersonErrors(t, Wrap(memdb.NewSession()))
+}
+
+func TestPersonHistory(t *testing.T) {
+	dbtest.RunPersonHistory(t, Wrap(memdb.NewSession()))
+}
+
+func TestPersonTimestamps(t *testing.T) {
+	dbtest.RunPersonTimestamps(t, func(clock db.Clock) db.Database {
+		return Wrap(memdb.NewSession(memdb.WithClock(clock)))
+	})
+}
+
+func TestPersonFeed(t *testing.T) {
+	dbtest.RunPersonFeed(t, Wrap(memdb.NewSession()))
+}
+
+func TestTx(t *testing.T) {
+	dbtest.RunTx(t, Wrap(memdb.NewSession()))
+}
+
+// countingDatastore counts the calls to Get, and makes them wait for release if it is set
+type countingDatastore struct {
+	db.Datastore[db.Person, uuid.UUID]
+	mu      sync.Mutex
+	gets    int
+	release chan struct{}
+}
+
+func (c *countingDatastore) Get(ctx context.Context, id uuid.UUID) (*db.Person, error) {
+	c.mu.Lock()
+	c.gets++
+	c.mu.Unlock()
+
+	if c.release != nil {
+		<-c.release
+	}
+	return c.Datastore.Get(ctx, id)
+}
+
+func (c *countingDatastore) getCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gets
+}
+
+// newTestDatastore returns a cached datastore, the backend it wraps, and a person that is stored in it
+func newTestDatastore(t *testing.T, opts ...Option) (*Datastore[db.Person, uuid.UUID], *countingDatastore, *db.Person) {
+	t.Helper()
+
+	backend := &countingDatastore{Datastore: memdb.NewSession().Person()}
+	person := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	if err := backend.Insert(context.Background(), person); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+
+	return New[db.Person, uuid.UUID](backend, PersonSchema, opts...), backend, person
+}
+
+func TestDatastore_Get(t *testing.T) {
+	ctx := context.Background()
+	store, backend, person := newTestDatastore(t)
+
+	for i := 0; i < 3; i++ {
+		got, err := store.Get(ctx, person.ID)
+		if err != nil {
+			t.Fatalf("failed to get person: %v", err)
+		}
+		assert.Equal(t, "Testy", got.FirstName)
+	}
+
+	assert.Equal(t, 1, backend.getCount())
+	assert.Equal(t, Stats{Hits: 2, Misses: 1}, store.Stats())
+
+	// Errors are not cached
+	for i := 0; i < 2; i++ {
+		_, err := store.Get(ctx, uuid.New())
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	}
+	assert.Equal(t, 3, backend.getCount())
+}
+
+func TestDatastore_Copies(t *testing.T) {
+	ctx := context.Background()
+	store, _, person := newTestDatastore(t)
+
+	got, err := store.Get(ctx, person.ID)
+	if err != nil {
+		t.Fatalf("failed to get person: %v", err)
+	}
+	got.FirstName = "Changed"
+	*got.Removed = true
+
+	got, err = store.Get(ctx, person.ID)
+	if err != nil {
+		t.Fatalf("failed to get person: %v", err)
+	}
+	assert.Equal(t, "Testy", got.FirstName)
+	assert.False(t, *got.Removed)
+}
+
+func TestDatastore_Invalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error
+		want   func(t *testing.T, got *db.Person, err error)
+	}{
+		{
+			name: "Update",
+			change: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				person.FirstName = "Updated"
+				return store.Update(ctx, person)
+			},
+			want: func(t *testing.T, got *db.Person, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "Updated", got.FirstName)
+			},
+		},
+		{
+			name: "UpdateBatch",
+			change: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				person.FirstName = "Updated"
+				return store.UpdateBatch(ctx, []*db.Person{person})
+			},
+			want: func(t *testing.T, got *db.Person, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "Updated", got.FirstName)
+			},
+		},
+		{
+			name: "Remove",
+			change: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				_, err := store.Remove(ctx, person.ID)
+				return err
+			},
+			want: func(t *testing.T, got *db.Person, err error) {
+				assert.NoError(t, err)
+				assert.True(t, *got.Removed)
+			},
+		},
+		{
+			name: "RemoveBatch",
+			change: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				_, err := store.RemoveBatch(ctx, []uuid.UUID{person.ID})
+				return err
+			},
+			want: func(t *testing.T, got *db.Person, err error) {
+				assert.NoError(t, err)
+				assert.True(t, *got.Removed)
+			},
+		},
+		{
+			name: "Purge",
+			change: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				if _, err := store.Remove(ctx, person.ID); err != nil {
+					return err
+				}
+				return store.Purge(ctx, person.ID)
+			},
+			want: func(t *testing.T, got *db.Person, err error) {
+				assert.ErrorIs(t, err, db.ErrNoResultsFound)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, _, person := newTestDatastore(t)
+
+			// Populate the cache
+			if _, err := store.Get(ctx, person.ID); err != nil {
+				t.Fatalf("failed to get person: %v", err)
+			}
+
+			if err := tt.change(ctx, store, person); err != nil {
+				t.Fatalf("failed to change person: %v", err)
+			}
+
+			got, err := store.Get(ctx, person.ID)
+			tt.want(t, got, err)
+		})
+	}
+}
+
+func TestDatastore_TTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store, backend, person := newTestDatastore(t, WithTTL(time.Minute), WithClock(func() time.Time { return now }))
+
+	store.Get(ctx, person.ID)
+	now = now.Add(59 * time.Second)
+	store.Get(ctx, person.ID)
+	assert.Equal(t, 1, backend.getCount())
+
+	now = now.Add(time.Second)
+	store.Get(ctx, person.ID)
+	assert.Equal(t, 2, backend.getCount())
+	assert.Equal(t, Stats{Hits: 1, Misses: 2}, store.Stats())
+}
+
+func TestDatastore_Eviction(t *testing.T) {
+	ctx := context.Background()
+	store, backend, first := newTestDatastore(t, WithSize(2))
+
+	second := &db.Person{FirstName: "Second"}
+	third := &db.Person{FirstName: "Third"}
+	for _, person := range []*db.Person{second, third} {
+		if err := backend.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+
+	store.Get(ctx, first.ID)
+	store.Get(ctx, second.ID)
+	store.Get(ctx, first.ID) // second is now the least recently used
+	store.Get(ctx, third.ID) // and gets evicted
+	assert.Equal(t, 2, store.items.len())
+	assert.Equal(t, 3, backend.getCount())
+
+	store.Get(ctx, first.ID)
+	store.Get(ctx, third.ID)
+	assert.Equal(t, 3, backend.getCount())
+
+	store.Get(ctx, second.ID)
+	assert.Equal(t, 4, backend.getCount())
+}
+
+func TestDatastore_ConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	store, backend, person := newTestDatastore(t)
+	backend.release = make(chan struct{})
+
+	const readers = 10
+	var wg sync.WaitGroup
+	wg.Add(readers)
+	for i := 0; i < readers; i++ {
+		go func() {
+			defer wg.Done()
+			got, err := store.Get(ctx, person.ID)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "Testy", got.FirstName)
+			}
+		}()
+	}
+
+	// Wait until every reader has missed, then let the single backend read finish
+	assert.Eventually(t, func() bool { return store.Stats().Misses == readers }, time.Second, time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, backend.getCount())
+}
+
+func TestDatastore_StaleRead(t *testing.T) {
+	ctx := context.Background()
+	store, backend, person := newTestDatastore(t)
+	backend.release = make(chan struct{})
+
+	// A read that started before an update must not cache what it read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		store.Get(ctx, person.ID)
+	}()
+	assert.Eventually(t, func() bool { return backend.getCount() == 1 }, time.Second, time.Millisecond)
+
+	person.FirstName = "Updated"
+	if err := store.Update(ctx, person); err != nil {
+		t.Fatalf("failed to update person: %v", err)
+	}
+	close(backend.release)
+	<-done
+
+	got, err := store.Get(ctx, person.ID)
+	if err != nil {
+		t.Fatalf("failed to get person: %v", err)
+	}
+	assert.Equal(t, "Updated", got.FirstName)
+}
+
+func TestDatabase_WithTx(t *testing.T) {
+	ctx := context.Background()
+	database := Wrap(memdb.NewSession())
+
+	person := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	if err := database.Person().Insert(ctx, person); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+	if _, err := database.Person().Get(ctx, person.ID); err != nil {
+		t.Fatalf("failed to get person: %v", err)
+	}
+
+	err := database.WithTx(ctx, func(tx db.Database) error {
+		return tx.WithTx(ctx, func(tx db.Database) error {
+			person.FirstName = "Updated"
+			return tx.Person().Update(ctx, person)
+		})
+	})
+	if err != nil {
+		t.Fatalf("failed to run transaction: %v", err)
+	}
+
+	got, err := database.Person().Get(ctx, person.ID)
+	if err != nil {
+		t.Fatalf("failed to get person: %v", err)
+	}
+	assert.Equal(t, "Updated", got.FirstName)
+	assert.Equal(t, Stats{Hits: 0, Misses: 2}, database.PersonStats())
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Database is a db.Database whose datastores cache the items read from another db.Database.
+type Database struct {
+	next   db.Database
+	person *Datastore[db.Person, uuid.UUID]
+}
+
+// Wrap returns a Database that caches the items read from database. Every datastore gets its own cache.
+func Wrap(database db.Database, opts ...Option) *Database {
+	return &Database{
+		next:   database,
+		person: New(database.Person(), PersonSchema, opts...),
+	}
+}
+
+// PersonStats returns the number of hits and misses of the person cache so far.
+func (d *Database) PersonStats() Stats {
+	return d.person.Stats()
+}
+
+// Person implements db.Database.
+func (d *Database) Person() db.Datastore[db.Person, uuid.UUID] {
+	return d.person
+}
+
+// PersonHistory implements db.Database. History is not cached.
+func (d *Database) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return d.next.PersonHistory()
+}
+
+// PersonFeed implements db.Database.
+func (d *Database) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return d.next.PersonFeed()
+}
+
+// WithTx implements db.Database. Reads made in the transaction bypass the cache, since they must see its
+// uncommitted changes. The items changed in the transaction are invalidated once it is over.
+func (d *Database) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	changed := &changedKeys[uuid.UUID]{}
+	defer func() {
+		d.person.Invalidate(changed.keys...)
+	}()
+
+	return d.next.WithTx(ctx, func(tx db.Database) error {
+		return fn(txDatabase{Database: tx, personChanges: changed})
+	})
+}
+
+// txDatabase is the view of a Database that is passed to a transaction.
+// It records which items are changed, so that they can be invalidated once the transaction is over.
+type txDatabase struct {
+	db.Database
+	personChanges *changedKeys[uuid.UUID]
+}
+
+// Person implements db.Database.
+func (t txDatabase) Person() db.Datastore[db.Person, uuid.UUID] {
+	return txDatastore[db.Person, uuid.UUID]{
+		Datastore: t.Database.Person(),
+		schema:    PersonSchema,
+		changes:   t.personChanges,
+	}
+}
+
+// WithTx implements db.Database.
+func (t txDatabase) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	return t.Database.WithTx(ctx, func(tx db.Database) error {
+		return fn(txDatabase{Database: tx, personChanges: t.personChanges})
+	})
+}
+
+// changedKeys collects the identifiers of the items changed in a transaction
+type changedKeys[U db.Identifier] struct {
+	mu   sync.Mutex
+	keys []U
+}
+
+func (c *changedKeys[U]) add(ids ...U) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.keys = append(c.keys, ids...)
+}
+
+// txDatastore passes every call to the datastore of a transaction, and records the items that are changed
+type txDatastore[T db.Entity, U db.Identifier] struct {
+	db.Datastore[T, U]
+	schema  Schema[T, U]
+	changes *changedKeys[U]
+}
+
+// Purge implements db.Datastore.
+func (t txDatastore[T, U]) Purge(ctx context.Context, id U) error {
+	t.changes.add(id)
+	return t.Datastore.Purge(ctx, id)
+}
+
+// Remove implements db.Datastore.
+func (t txDatastore[T, U]) Remove(ctx context.Context, id U) (*T, error) {
+	t.changes.add(id)
+	return t.Datastore.Remove(ctx, id)
+}
+
+// RemoveBatch implements db.Datastore.
+func (t txDatastore[T, U]) RemoveBatch(ctx context.Context, ids []U) ([]*T, error) {
+	t.changes.add(ids...)
+	return t.Datastore.RemoveBatch(ctx, ids)
+}
+
+// Restore implements db.Datastore.
+func (t txDatastore[T, U]) Restore(ctx context.Context, id U) (*T, error) {
+	t.changes.add(id)
+	return t.Datastore.Restore(ctx, id)
+}
+
+// Update implements db.Datastore.
+func (t txDatastore[T, U]) Update(ctx context.Context, item *T) error {
+	t.changes.add(t.schema.Key(item))
+	return t.Datastore.Update(ctx, item)
+}
+
+// UpdateBatch implements db.Datastore.
+func (t txDatastore[T, U]) UpdateBatch(ctx context.Context, items []*T) error {
+	for _, item := range items {
+		if item != nil {
+			t.changes.add(t.schema.Key(item))
+		}
+	}
+	return t.Datastore.UpdateBatch(ctx, items)
+}
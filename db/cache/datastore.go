@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/williabk198/go-api-server-template/db"
+	"golang.org/x/sync/singleflight"
+)
+
+// Datastore is a db.Datastore that caches the items read with Get from another db.Datastore.
+// Every other read goes straight to the underlying datastore, and every change invalidates the items it touched.
+type Datastore[T db.Entity, U db.Identifier] struct {
+	next   db.Datastore[T, U]
+	schema Schema[T, U]
+	items  *lru[U, T]
+	reads  singleflight.Group
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// New wraps next in a Datastore that caches the items read from it.
+func New[T db.Entity, U db.Identifier](next db.Datastore[T, U], schema Schema[T, U], opts ...Option) *Datastore[T, U] {
+	o := newOptions(opts)
+	return &Datastore[T, U]{
+		next:   next,
+		schema: schema,
+		items:  newLRU[U, T](o.size, o.ttl, o.clock),
+	}
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (d *Datastore[T, U]) Stats() Stats {
+	return Stats{
+		Hits:   d.hits.Load(),
+		Misses: d.misses.Load(),
+	}
+}
+
+// Invalidate drops the given items from the cache, so that they are read from the underlying datastore next time.
+func (d *Datastore[T, U]) Invalidate(ids ...U) {
+	d.items.remove(ids...)
+	for _, id := range ids {
+		d.reads.Forget(fmt.Sprint(id))
+	}
+}
+
+// Get implements db.Datastore.
+func (d *Datastore[T, U]) Get(ctx context.Context, id U) (*T, error) {
+	if item, ok := d.items.get(id); ok {
+		d.hits.Add(1)
+		return d.schema.Copy(&item), nil
+	}
+	d.misses.Add(1)
+
+	result, err, shared := d.reads.Do(fmt.Sprint(id), func() (any, error) {
+		generation := d.items.currentGeneration()
+		item, err := d.next.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		d.items.add(id, *d.schema.Copy(item), generation)
+		return item, nil
+	})
+	if err != nil {
+		// The read was made with the context of whichever caller started it. If that context was canceled,
+		// the other callers still deserve an answer of their own.
+		if shared && ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+			return d.next.Get(ctx, id)
+		}
+		return nil, err
+	}
+
+	return d.schema.Copy(result.(*T)), nil
+}
+
+// Insert implements db.Datastore.
+func (d *Datastore[T, U]) Insert(ctx context.Context, item *T) error {
+	return d.next.Insert(ctx, item)
+}
+
+// InsertBatch implements db.Datastore.
+func (d *Datastore[T, U]) InsertBatch(ctx context.Context, items []*T) error {
+	return d.next.InsertBatch(ctx, items)
+}
+
+// Purge implements db.Datastore.
+func (d *Datastore[T, U]) Purge(ctx context.Context, id U) error {
+	defer d.Invalidate(id)
+	return d.next.Purge(ctx, id)
+}
+
+// Query implements db.Datastore.
+func (d *Datastore[T, U]) Query(ctx context.Context, query db.Query) (*db.Page[T], error) {
+	return d.next.Query(ctx, query)
+}
+
+// Remove implements db.Datastore.
+func (d *Datastore[T, U]) Remove(ctx context.Context, id U) (*T, error) {
+	defer d.Invalidate(id)
+	return d.next.Remove(ctx, id)
+}
+
+// RemoveBatch implements db.Datastore.
+func (d *Datastore[T, U]) RemoveBatch(ctx context.Context, ids []U) ([]*T, error) {
+	defer d.Invalidate(ids...)
+	return d.next.RemoveBatch(ctx, ids)
+}
+
+// Restore implements db.Datastore.
+func (d *Datastore[T, U]) Restore(ctx context.Context, id U) (*T, error) {
+	defer d.Invalidate(id)
+	return d.next.Restore(ctx, id)
+}
+
+// Update implements db.Datastore.
+func (d *Datastore[T, U]) Update(ctx context.Context, item *T) error {
+	defer d.Invalidate(d.schema.Key(item))
+	return d.next.Update(ctx, item)
+}
+
+// UpdateBatch implements db.Datastore.
+func (d *Datastore[T, U]) UpdateBatch(ctx context.Context, items []*T) error {
+	defer d.Invalidate(d.keys(items)...)
+	return d.next.UpdateBatch(ctx, items)
+}
+
+// keys returns the identifiers of the given items
+func (d *Datastore[T, U]) keys(items []*T) []U {
+	ids := make([]U, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			ids = append(ids, d.schema.Key(item))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,8 @@
+// cache wraps a db.Database, or any single db.Datastore, with a bounded read-through cache.
+//
+// Items that are read with Get are kept in a least recently used cache for a limited time, so that
+// repeated reads of the same items don't reach the underlying database. Concurrent misses for the same item
+// share a single read. Every change made through the wrapper invalidates the items it touched, so a cached
+// item is never older than the last change made through it. Changes made to the underlying database by other
+// processes are only picked up once the cached item expires.
+package cache
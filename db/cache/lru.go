@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// lruEntry is a cached value along with the key it is cached under
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time // Zero if the entry doesn't expire
+}
+
+// lru is a thread-safe, least recently used cache whose entries expire after a fixed time.
+type lru[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries map[K]*list.Element
+	order   *list.List // The most recently used entry is at the front
+	size    int
+	ttl     time.Duration
+	clock   db.Clock
+	// generation is incremented every time entries are removed, so that values that were read before
+	// the removal, and might therefore be stale, are not added afterwards.
+	generation uint64
+}
+
+func newLRU[K comparable, V any](size int, ttl time.Duration, clock db.Clock) *lru[K, V] {
+	return &lru[K, V]{
+		entries: make(map[K]*list.Element),
+		order:   list.New(),
+		size:    size,
+		ttl:     ttl,
+		clock:   clock,
+	}
+}
+
+// get returns the value cached under key, and false if there is none or it has expired
+func (c *lru[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	entry := element.Value.(*lruEntry[K, V])
+	if !entry.expires.IsZero() && !c.clock().Before(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// currentGeneration returns the generation to pass to add for a value that is about to be read
+func (c *lru[K, V]) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// add caches value under key, evicting the least recently used entry if the cache is full.
+// The value is discarded if any entries were removed since generation, since it might be stale by now.
+func (c *lru[K, V]) add(key K, value V, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.clock().Add(c.ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// remove drops the entries cached under the given keys
+func (c *lru[K, V]) remove(keys ...K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, key := range keys {
+		if element, ok := c.entries[key]; ok {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// len returns the number of cached entries, including the ones that have expired but weren't dropped yet
+func (c *lru[K, V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
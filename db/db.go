@@ -2,7 +2,9 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -10,22 +12,91 @@ import (
 // ErrNoResultsFound is a database agnostic error that indicates that no results were found
 var ErrNoResultsFound = fmt.Errorf("db query yeilded no results")
 
+// ErrConflict is a database agnostic error that indicates that a change conflicts with the current state of the
+// database, such as a violated unique constraint. The more specific conflicts below wrap it.
+var ErrConflict = fmt.Errorf("db change conflicts with the current state")
+
+// ErrVersionConflict is a database agnostic error that indicates that an item was changed
+// since the version that the caller based its change on
+var ErrVersionConflict = fmt.Errorf("%w: db item was changed by someone else", ErrConflict)
+
+// ErrAlreadyRemoved is a database agnostic error that indicates that an item is already marked as removed
+var ErrAlreadyRemoved = fmt.Errorf("%w: db item is already removed", ErrConflict)
+
+// ErrNotRemoved is a database agnostic error that indicates that an item is not marked as removed
+var ErrNotRemoved = fmt.Errorf("%w: db item is not removed", ErrConflict)
+
+// ErrInvalidArgument is a database agnostic error that indicates that the database rejected a value passed to it,
+// for example because it is out of range for the column it is stored in.
+var ErrInvalidArgument = fmt.Errorf("db rejected an invalid argument")
+
+// ErrUnavailable is a database agnostic error that indicates that the database can't be reached or is overloaded.
+// The operation may succeed if it is retried later.
+var ErrUnavailable = fmt.Errorf("db is unavailable")
+
+// ErrDeadlineExceeded is a database agnostic error that indicates that an operation did not finish in time
+var ErrDeadlineExceeded = fmt.Errorf("db operation exceeded its deadline")
+
+// ErrPermissionDenied is a database agnostic error that indicates that the database refused to perform an operation
+var ErrPermissionDenied = fmt.Errorf("db permission denied")
+
+// ContextError returns the error of ctx if it is done, and nil otherwise.
+// If the deadline of ctx has passed, the error is wrapped in ErrDeadlineExceeded.
+func ContextError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrDeadlineExceeded, err)
+	}
+	return err
+}
+
 // Database defines the interactions with the database
 type Database interface {
 	Person() Datastore[Person, uuid.UUID]
+	PersonHistory() HistoryStore[Person, uuid.UUID]
+	// PersonFeed reports the changes made to people.
+	PersonFeed() ChangeFeed[Person, uuid.UUID]
+	// WithTx runs fn inside a transaction. Every datastore of the Database passed to fn takes part in the transaction,
+	// which is committed if fn returns nil, and rolled back if fn returns an error or panics.
+	// Calling WithTx on the Database passed to fn runs the nested function as part of the same transaction.
+	WithTx(ctx context.Context, fn func(tx Database) error) error
 }
 
-// Datastore defines the basic interactions for a database entry
+// Datastore defines the basic interactions for a database entry.
+// Implementations wrap the errors of the underlying database in the errors of this package, such as
+// ErrConflict or ErrUnavailable, so that callers can handle them without knowing which database is used.
 type Datastore[T Entity, U Identifier] interface {
 	// Get retrieves an item from the database that matches the passed in item.
 	Get(ctx context.Context, id U) (*T, error)
-	// Insert puts the given item into the database.
+	// Insert puts the given item into the database, at version 1.
 	Insert(ctx context.Context, item *T) error
-	// Remove marks the given item as removed in the database. This should NOT actually remove the item from the database.
+	// InsertBatch inserts every given item, as Insert does. The items are inserted independently of each other,
+	// and a BatchError is returned if any of them failed. Use WithTx to insert either all of them or none.
+	InsertBatch(ctx context.Context, items []*T) error
+	// Purge permanently deletes the given item, and its history, from the database.
+	// Only removed items can be purged, ErrNotRemoved is returned otherwise.
+	Purge(ctx context.Context, id U) error
+	// Query lists the items that match the given query, excluding removed items unless the query says otherwise.
+	// An error wrapping ErrInvalidQuery is returned if the query does not fit the item type.
+	Query(ctx context.Context, query Query) (*Page[T], error)
+	// Remove marks the given item as removed in the database and increments its version.
+	// This should NOT actually remove the item from the database. ErrAlreadyRemoved is returned if it already is removed.
 	Remove(ctx context.Context, id U) (*T, error)
-	// Update changes an item in the database to the given value.
+	// RemoveBatch removes every given item, as Remove does, and returns them at the index of their ID.
+	// The items are removed independently of each other, and a BatchError is returned if any of them failed,
+	// in which case the result holds nil for the failed items. Use WithTx to remove either all of them or none.
+	RemoveBatch(ctx context.Context, ids []U) ([]*T, error)
+	// Restore clears the removed mark of the given item and increments its version.
+	// ErrNotRemoved is returned if the item is not removed.
+	Restore(ctx context.Context, id U) (*T, error)
+	// Update changes an item in the database to the given value, and increments its version.
 	// The key of the database item to be updated must be provided in the passed in item.
+	// If the passed in item has a non-zero version, ErrVersionConflict is returned if it doesn't match the stored version.
+	// The removed mark of the item is kept as it is stored, since only Remove and Restore change it.
 	Update(ctx context.Context, item *T) error
+	// UpdateBatch updates every given item, as Update does. The items are updated independently of each other,
+	// and a BatchError is returned if any of them failed. Use WithTx to update either all of them or none.
+	UpdateBatch(ctx context.Context, items []*T) error
 }
 
 // Entity is a type constraint which represents the items that are stored in the database.
@@ -37,6 +108,25 @@ type Identifier interface {
 	int | uuid.UUID
 }
 
+// Timestamps records when an item was created, last changed and removed.
+// They are managed by the datastores, which ignore the timestamps of the items passed to them.
+type Timestamps struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// RemovedAt is nil if the item is not removed.
+	RemovedAt *time.Time
+}
+
+// Clock returns the current time. Datastores use it to timestamp the changes they make,
+// so that tests can control the time they see.
+type Clock func() time.Time
+
+// SystemClock is the Clock that datastores use by default. It returns the current time in UTC,
+// truncated to microseconds since that is the most precise time every database can store.
+func SystemClock() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
 // NullBool is a database agnostic type to represent a nullable boolean value.
 type NullBool *bool
 
@@ -1,17 +1,38 @@
 package dummydb
 
 import (
+	"context"
+
 	"github.com/google/uuid"
 	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/feed"
 )
 
 type dummyDB struct{}
 
+// personFeed never publishes any events, since nothing is ever changed in the dummy database
+var personFeed = feed.NewBroker(feed.Options[db.Person, uuid.UUID]{})
+
 // Person implements db.Database.
 func (d dummyDB) Person() db.Datastore[db.Person, uuid.UUID] {
 	return personDatastore{}
 }
 
+// PersonHistory implements db.Database.
+func (d dummyDB) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return personHistoryStore{}
+}
+
+// PersonFeed implements db.Database.
+func (d dummyDB) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return personFeed
+}
+
+// WithTx implements db.Database. The dummy database has nothing to commit or roll back, so fn is simply called.
+func (d dummyDB) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	return fn(d)
+}
+
 func NewSession() db.Database {
 	return dummyDB{}
 }
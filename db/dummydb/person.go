@@ -8,6 +8,12 @@ import (
 	"github.com/williabk198/go-api-server-template/db"
 )
 
+// dummyTimestamps are the timestamps of every dummy person
+var dummyTimestamps = db.Timestamps{
+	CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	UpdatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
 type personDatastore struct{}
 
 // Get implements db.Datastore.
@@ -19,6 +25,8 @@ func (p personDatastore) Get(ctx context.Context, id uuid.UUID) (*db.Person, err
 		LastName:    "McTesterson",
 		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		Removed:     db.NewBool(false),
+		Version:     1,
+		Timestamps:  dummyTimestamps,
 	}
 
 	return result, nil
@@ -27,9 +35,38 @@ func (p personDatastore) Get(ctx context.Context, id uuid.UUID) (*db.Person, err
 // Insert implements db.Datastore.
 func (p personDatastore) Insert(ctx context.Context, item *db.Person) error {
 	item.ID = uuid.New()
+	item.Version = 1
+	item.Timestamps = dummyTimestamps
+	return nil
+}
+
+// InsertBatch implements db.Datastore.
+func (p personDatastore) InsertBatch(ctx context.Context, items []*db.Person) error {
+	return db.Batch(ctx, len(items), func(i int) error {
+		return p.Insert(ctx, items[i])
+	})
+}
+
+// Purge implements db.Datastore.
+func (p personDatastore) Purge(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// Query implements db.Datastore.
+func (p personDatastore) Query(ctx context.Context, query db.Query) (*db.Page[db.Person], error) {
+	result := db.Person{
+		ID:          uuid.New(),
+		FirstName:   "Testy",
+		LastName:    "McTesterson",
+		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     1,
+		Timestamps:  dummyTimestamps,
+	}
+
+	return &db.Page[db.Person]{Items: []db.Person{result}, Total: 1}, nil
+}
+
 // Remove implements db.Datastore.
 func (p personDatastore) Remove(ctx context.Context, id uuid.UUID) (*db.Person, error) {
 	result := &db.Person{
@@ -38,6 +75,36 @@ func (p personDatastore) Remove(ctx context.Context, id uuid.UUID) (*db.Person,
 		LastName:    "McTesterson",
 		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		Removed:     db.NewBool(true),
+		Version:     2,
+		Timestamps:  dummyTimestamps,
+	}
+	result.RemovedAt = &result.UpdatedAt
+
+	return result, nil
+}
+
+// RemoveBatch implements db.Datastore.
+func (p personDatastore) RemoveBatch(ctx context.Context, ids []uuid.UUID) ([]*db.Person, error) {
+	result := make([]*db.Person, len(ids))
+	err := db.Batch(ctx, len(ids), func(i int) error {
+		var err error
+		result[i], err = p.Remove(ctx, ids[i])
+		return err
+	})
+
+	return result, err
+}
+
+// Restore implements db.Datastore.
+func (p personDatastore) Restore(ctx context.Context, id uuid.UUID) (*db.Person, error) {
+	result := &db.Person{
+		ID:          id,
+		FirstName:   "Testy",
+		LastName:    "McTesterson",
+		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     3,
+		Timestamps:  dummyTimestamps,
 	}
 
 	return result, nil
@@ -45,5 +112,60 @@ func (p personDatastore) Remove(ctx context.Context, id uuid.UUID) (*db.Person,
 
 // Update implements db.Datastore.
 func (p personDatastore) Update(ctx context.Context, item *db.Person) error {
+	item.Version++
 	return nil
 }
+
+// UpdateBatch implements db.Datastore.
+func (p personDatastore) UpdateBatch(ctx context.Context, items []*db.Person) error {
+	return db.Batch(ctx, len(items), func(i int) error {
+		return p.Update(ctx, items[i])
+	})
+}
+
+type personHistoryStore struct{}
+
+// Get implements db.HistoryStore.
+func (p personHistoryStore) Get(ctx context.Context, id uuid.UUID, version int) (*db.HistoryEntry[db.Person], error) {
+	item := db.Person{
+		ID:          id,
+		FirstName:   "Testy",
+		LastName:    "McTesterson",
+		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     version,
+		Timestamps:  dummyTimestamps,
+	}
+
+	result := &db.HistoryEntry[db.Person]{
+		Version:   version,
+		Change:    db.ChangeUpdate,
+		Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Item:      item,
+	}
+
+	return result, nil
+}
+
+// List implements db.HistoryStore.
+func (p personHistoryStore) List(ctx context.Context, id uuid.UUID) ([]db.HistoryEntry[db.Person], error) {
+	item := db.Person{
+		ID:          id,
+		FirstName:   "Testy",
+		LastName:    "McTesterson",
+		DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		Removed:     db.NewBool(false),
+		Version:     1,
+		Timestamps:  dummyTimestamps,
+	}
+
+	result := []db.HistoryEntry[db.Person]{{
+		Version:   1,
+		Change:    db.ChangeInsert,
+		Timestamp: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Item:      item,
+		Diff:      item.Diff(nil),
+	}}
+
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ChangePurge is the Change of an Event that reports that an item was purged.
+// It never appears in a history, since the history of an item is purged along with it.
+const ChangePurge Change = "purge"
+
+// ErrSequenceExpired is a database agnostic error that indicates that a change feed was asked to resume from
+// a sequence it no longer holds the events of. The subscriber has to catch up some other way, such as by querying
+// the datastore, and watch the feed again without a starting sequence.
+var ErrSequenceExpired = fmt.Errorf("db change feed no longer holds the requested sequence")
+
+// ErrSubscriberOverflow is a database agnostic error that indicates that a subscription to a change feed was ended
+// because its buffer filled up. The subscriber can watch the feed again, resuming after the last event it received.
+var ErrSubscriberOverflow = fmt.Errorf("db change feed subscriber fell too far behind")
+
+// ErrFeedClosed is a database agnostic error that indicates that a change feed was closed, because the database was
+var ErrFeedClosed = fmt.Errorf("db change feed is closed")
+
+// DefaultWatchBuffer is the number of events buffered for a subscriber unless WatchOptions.Buffer says otherwise
+const DefaultWatchBuffer = 256
+
+// Event reports a single change that was made to an item
+type Event[T Entity, U Identifier] struct {
+	// Sequence is the position of the event in the feed. Later events always have a greater sequence,
+	// although sequences may be skipped.
+	Sequence uint64
+	Change   Change
+	ID       U
+	// Principal is the name of the principal that made the change, or empty if it was made anonymously.
+	Principal string
+	Timestamp time.Time
+	// Before is the item right before the change, and nil if the change inserted it.
+	Before *T
+	// After is the item right after the change, and nil if the change purged it.
+	After *T
+}
+
+// OverflowPolicy decides what happens when a subscriber doesn't keep up with the events of a change feed,
+// and its buffer is full when another event is published
+type OverflowPolicy int
+
+const (
+	// OverflowClose ends the subscription with ErrSubscriberOverflow, once the buffered events are delivered.
+	OverflowClose OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered event to make room for the new one. Subscribers can tell that
+	// events were dropped from the gap between the sequences of the events they receive.
+	OverflowDropOldest
+)
+
+// WatchOptions configures a subscription to a change feed
+type WatchOptions struct {
+	// From is the sequence of the first event to deliver, which allows a subscriber to resume after the last event
+	// it received. Zero only delivers the events of changes made after the subscription started.
+	// ErrSequenceExpired is returned if the feed no longer holds the events from the given sequence on.
+	From uint64
+	// Buffer is the number of events that are held for the subscriber while it is busy. DefaultWatchBuffer is used
+	// if it is zero. When resuming, the events the subscriber missed count towards the buffer as well.
+	Buffer int
+	// Overflow decides what happens when the buffer is full.
+	Overflow OverflowPolicy
+}
+
+// Subscription delivers the events of a change feed to a single subscriber
+type Subscription[T Entity, U Identifier] interface {
+	// Events delivers the events in the order of their sequence.
+	// It is closed when the subscription ends, after which Err tells why.
+	Events() <-chan Event[T, U]
+	// Err returns the reason the subscription ended, such as ErrSubscriberOverflow or the error of the context
+	// passed to Watch. It is nil while the subscription is active, and after the subscription is closed with Close.
+	Err() error
+	// Close ends the subscription. It is safe to call more than once.
+	Close()
+}
+
+// ChangeFeed reports every change made to the items of a Datastore, including the changes made by other
+// processes sharing a persistent database. Changes made in a transaction are reported once it is committed.
+type ChangeFeed[T Entity, U Identifier] interface {
+	// Watch subscribes to the feed. The subscription ends when ctx is done, or when it is closed.
+	Watch(ctx context.Context, opts WatchOptions) (Subscription[T, U], error)
+}
@@ -0,0 +1,8 @@
+// filedb is a pure Go implementation of db.Database that persists its data to an append-only log file.
+//
+// Every change is written to the log, and flushed to disk, before it is applied to an in-memory copy of the data.
+// When the database is opened the log is replayed to rebuild that copy. A record that was only partially written,
+// for example because the process crashed, is discarded. The log is periodically compacted in the background,
+// so that it only holds the history of every item and drops the records of the items that were purged.
+// Since the history is kept, History and Revert work the same before and after a compaction.
+package filedb
@@ -0,0 +1,400 @@
+package filedb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/memdb"
+)
+
+const personEntity = "person"
+
+// Option configures a Database when it is opened
+type Option func(*options)
+
+type options struct {
+	compactionInterval  time.Duration
+	compactionThreshold int
+	logger              *slog.Logger
+	clock               db.Clock
+}
+
+// WithCompaction changes how often the log is checked, and how many records must have been
+// appended to it since it was last compacted, before it is compacted in the background.
+// An interval of zero disables background compaction.
+func WithCompaction(interval time.Duration, threshold int) Option {
+	return func(o *options) {
+		o.compactionInterval = interval
+		o.compactionThreshold = threshold
+	}
+}
+
+// WithLogger sets the logger used to report errors that happen during background compaction.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithClock sets the clock that is used to timestamp changes. By default the system clock is used.
+func WithClock(clock db.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// Database is a db.Database that persists its data to an append-only log file.
+// Close must be called once the database is no longer needed.
+type Database struct {
+	log    *appendLog
+	person *memdb.Datastore[db.Person, uuid.UUID]
+
+	// pending holds the records of changes made in transactions that are not committed yet
+	pendingMu sync.Mutex
+	pending   map[*memdb.Tx][]record
+
+	logger    *slog.Logger
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// Person implements db.Database.
+func (d *Database) Person() db.Datastore[db.Person, uuid.UUID] {
+	return personDatastore{Datastore: d.person, database: d}
+}
+
+// PersonHistory implements db.Database.
+func (d *Database) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return d.person.History()
+}
+
+// PersonFeed implements db.Database. Only the most recent events are kept for subscribers that resume from
+// an earlier sequence, and none of them survive a restart, although their sequences do.
+func (d *Database) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return d.person.Feed()
+}
+
+// Open opens the log file at path, creating it if it does not exist yet, and rebuilds the database by replaying it.
+func Open(path string, opts ...Option) (*Database, error) {
+	options := options{
+		compactionInterval:  time.Minute,
+		compactionThreshold: 1000,
+		logger:              slog.Default(),
+		clock:               db.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	log, records, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	database := &Database{
+		log:     log,
+		person:  memdb.NewDatastore(memdb.PersonSchema),
+		logger:  options.logger,
+		stop:    make(chan struct{}),
+		pending: make(map[*memdb.Tx][]record),
+	}
+
+	if err := database.replay(records); err != nil {
+		log.close()
+		return nil, err
+	}
+
+	database.person.UseClock(options.clock)
+	database.person.OnWrite(func(tx *memdb.Tx, id uuid.UUID, sequence uint64, entry *db.HistoryEntry[db.Person]) error {
+		if entry == nil {
+			return database.append(tx, personEntity, opDelete, db.Person{ID: id}, nil, sequence)
+		}
+		return database.append(tx, personEntity, opPut, entry.Item, &historyInfo{
+			Change:    entry.Change,
+			Principal: entry.Principal,
+			Timestamp: entry.Timestamp,
+		}, sequence)
+	})
+
+	if options.compactionInterval > 0 {
+		database.stopped.Add(1)
+		go database.compactInBackground(options.compactionInterval, options.compactionThreshold)
+	}
+
+	return database, nil
+}
+
+// WithTx implements db.Database. The changes made in the transaction are written to the log as a single record.
+func (d *Database) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	if err := db.ContextError(ctx); err != nil {
+		return err
+	}
+
+	tx := &memdb.Tx{}
+	txDB := &fileTx{person: memdb.Join(tx, d.person), personHistory: memdb.TxHistory(tx, d.person), personFeed: d.person.Feed()}
+	defer func() {
+		d.pendingMu.Lock()
+		delete(d.pending, tx)
+		d.pendingMu.Unlock()
+	}()
+
+	return memdb.RunTx(tx, func() error { return fn(txDB) }, func() error {
+		d.pendingMu.Lock()
+		records := d.pending[tx]
+		d.pendingMu.Unlock()
+
+		if len(records) == 0 {
+			return nil
+		}
+		data, err := json.Marshal(records)
+		if err != nil {
+			return fmt.Errorf("failed to encode transaction: %w", err)
+		}
+		return d.log.append(record{Op: opBatch, Data: data})
+	})
+}
+
+// fileTx is the db.Database that is passed to the function run by WithTx
+type fileTx struct {
+	person        db.Datastore[db.Person, uuid.UUID]
+	personHistory db.HistoryStore[db.Person, uuid.UUID]
+	personFeed    db.ChangeFeed[db.Person, uuid.UUID]
+}
+
+// Person implements db.Database.
+func (f *fileTx) Person() db.Datastore[db.Person, uuid.UUID] {
+	return f.person
+}
+
+// PersonHistory implements db.Database.
+func (f *fileTx) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return f.personHistory
+}
+
+// PersonFeed implements db.Database. Changes made in the transaction are only published once it is committed.
+func (f *fileTx) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return f.personFeed
+}
+
+// WithTx implements db.Database. The function is run as part of the existing transaction.
+func (f *fileTx) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	return fn(f)
+}
+
+// personDatastore is the person datastore of a Database. It writes every batch of changes to the log
+// as a single record, instead of writing, and flushing, a record for every item in the batch.
+type personDatastore struct {
+	*memdb.Datastore[db.Person, uuid.UUID]
+	database *Database
+}
+
+// InsertBatch implements db.Datastore.
+func (p personDatastore) InsertBatch(ctx context.Context, items []*db.Person) error {
+	return p.database.batch(ctx, func(tx db.Database) error {
+		return tx.Person().InsertBatch(ctx, items)
+	})
+}
+
+// RemoveBatch implements db.Datastore.
+func (p personDatastore) RemoveBatch(ctx context.Context, ids []uuid.UUID) ([]*db.Person, error) {
+	var result []*db.Person
+	err := p.database.batch(ctx, func(tx db.Database) error {
+		var err error
+		result, err = tx.Person().RemoveBatch(ctx, ids)
+		return err
+	})
+
+	return result, err
+}
+
+// UpdateBatch implements db.Datastore.
+func (p personDatastore) UpdateBatch(ctx context.Context, items []*db.Person) error {
+	return p.database.batch(ctx, func(tx db.Database) error {
+		return tx.Person().UpdateBatch(ctx, items)
+	})
+}
+
+// batch runs a batch operation in a transaction, so that its changes are written to the log as a single record.
+// Unlike other transactions, it is committed even if some of the items in the batch failed.
+func (d *Database) batch(ctx context.Context, fn func(tx db.Database) error) error {
+	var batchErr db.BatchError
+	err := d.WithTx(ctx, func(tx db.Database) error {
+		err := fn(tx)
+		if errors.As(err, &batchErr) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+
+	return nil
+}
+
+// Compact rewrites the log so that it only holds the history and the current state of every item,
+// leaving out the records of the items that were purged. Changes to the database are blocked while the log is being rewritten.
+func (d *Database) Compact() error {
+	return d.person.Snapshot(func(people []db.Person, history map[uuid.UUID][]db.HistoryEntry[db.Person], sequence uint64) error {
+		records := []record{{Op: opSequence, Sequence: sequence}}
+		for _, person := range people {
+			for _, entry := range history[person.ID] {
+				data, err := json.Marshal(entry.Item)
+				if err != nil {
+					return fmt.Errorf("failed to encode person: %w", err)
+				}
+				records = append(records, record{Entity: personEntity, Op: opPut, Data: data, History: &historyInfo{
+					Change:    entry.Change,
+					Principal: entry.Principal,
+					Timestamp: entry.Timestamp,
+				}})
+			}
+
+			// The last history entry already holds the current state, unless the history is incomplete
+			if entries := history[person.ID]; len(entries) > 0 && entries[len(entries)-1].Version == person.Version {
+				continue
+			}
+			data, err := json.Marshal(person)
+			if err != nil {
+				return fmt.Errorf("failed to encode person: %w", err)
+			}
+			records = append(records, record{Entity: personEntity, Op: opPut, Data: data})
+		}
+
+		return d.log.rewrite(records)
+	})
+}
+
+// Close stops the background compaction and closes the log file. Subscriptions to the feed end with db.ErrFeedClosed.
+func (d *Database) Close() error {
+	var err error
+	d.closeOnce.Do(func() {
+		d.person.CloseFeed()
+		close(d.stop)
+		d.stopped.Wait()
+		err = d.log.close()
+	})
+	return err
+}
+
+// append writes a change to an item to the log. Changes made in a transaction
+// are held back until the transaction is committed.
+func (d *Database) append(tx *memdb.Tx, entity, op string, item any, history *historyInfo, sequence uint64) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", entity, err)
+	}
+	rec := record{Entity: entity, Op: op, Data: data, History: history, Sequence: sequence}
+
+	if tx != nil {
+		d.pendingMu.Lock()
+		d.pending[tx] = append(d.pending[tx], rec)
+		d.pendingMu.Unlock()
+		return nil
+	}
+
+	return d.log.append(rec)
+}
+
+// replay applies the records read from the log, in order, to the in-memory datastores
+func (d *Database) replay(records []record) error {
+	people := make(map[uuid.UUID]db.Person)
+	history := make(map[uuid.UUID][]db.HistoryEntry[db.Person])
+	var sequence uint64
+
+	var apply func(rec record) error
+	apply = func(rec record) error {
+		sequence = max(sequence, rec.Sequence)
+		if rec.Op == opSequence {
+			return nil
+		}
+		if rec.Op == opBatch {
+			var batch []record
+			if err := json.Unmarshal(rec.Data, &batch); err != nil {
+				return fmt.Errorf("failed to decode batch from log: %w", err)
+			}
+			for _, batchRec := range batch {
+				if err := apply(batchRec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if rec.Entity != personEntity {
+			return fmt.Errorf("log contains a record for unknown entity %q", rec.Entity)
+		}
+
+		var person db.Person
+		if err := json.Unmarshal(rec.Data, &person); err != nil {
+			return fmt.Errorf("failed to decode person from log: %w", err)
+		}
+
+		switch rec.Op {
+		case opPut:
+			people[person.ID] = person
+			if rec.History != nil {
+				history[person.ID] = append(history[person.ID], db.HistoryEntry[db.Person]{
+					Version:   person.Version,
+					Change:    rec.History.Change,
+					Principal: rec.History.Principal,
+					Timestamp: rec.History.Timestamp,
+					Item:      person,
+				})
+			}
+		case opDelete:
+			delete(people, person.ID)
+			delete(history, person.ID)
+		default:
+			return fmt.Errorf("log contains a record with unknown operation %q", rec.Op)
+		}
+		return nil
+	}
+
+	for _, rec := range records {
+		if err := apply(rec); err != nil {
+			return err
+		}
+	}
+
+	for _, person := range people {
+		d.person.Load(person)
+		d.person.LoadHistory(person.ID, history[person.ID]...)
+	}
+	d.person.LoadSequence(sequence)
+
+	return nil
+}
+
+// compactInBackground periodically compacts the log once enough records have been appended to it
+func (d *Database) compactInBackground(interval time.Duration, threshold int) {
+	defer d.stopped.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if d.log.appendedSince() < threshold {
+				continue
+			}
+			if err := d.Compact(); err != nil {
+				d.logger.Error("failed to compact the database log", "path", d.log.path, "error", err)
+			}
+		}
+	}
+}
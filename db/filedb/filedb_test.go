@@ -0,0 +1,339 @@
+package filedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/dbtest"
+)
+
+// testDatabase opens a database in a temporary directory that is removed once the test completes
+func testDatabase(t *testing.T, path string, opts ...Option) *Database {
+	t.Helper()
+
+	database, err := Open(path, append([]Option{WithCompaction(0, 0)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	return database
+}
+
+func TestPersonDatastore(t *testing.T) {
+	dbtest.RunPersonDatastore(t, testDatabase(t, filepath.Join(t.TempDir(), "test.log")))
+}
+
+func TestPersonQuery(t *testing.T) {
+	dbtest.RunPersonQuery(t, testDatabase(t, filepath.Join(t.TempDir(), "test.log")))
+}
+
+func TestPersonBatch(t *testing.T) {
+	dbtest.RunPersonBatch(t, testDatabase(t, filepath.Join(t.TempDir(), "test.log")))
+}
+
+func TestPersonErrors(t *testing.T) {
+	dbtest.RunPersonErrors(t, testDatabase(t, filepath.Join(t.TempDir(), "test.log")))
+}
+
+func TestPersonHistory(t *testing.T) {
+	dbtest.RunPersonHistory(t, testDatabase(t, filepath.Join(t.TempDir(), "test.log")))
+}
+
+func TestPersonTimestamps(t *testing.T) {
+	dbtest.RunPersonTimestamps(t, func(clock db.Clock) db.Database {
+		return testDatabase(t, filepath.Join(t.TempDir(), "test.log"), WithClock(clock))
+	})
+}
+
+func TestPersonFeed(t *testing.T) {
+	dbtest.RunPersonFeed(t, testDatabase(t, filepath.Join(t.TempDir(), "test.log")))
+}
+
+func TestTx(t *testing.T) {
+	dbtest.RunTx(t, testDatabase(t, filepath.Join(t.TempDir(), "test.log")))
+}
+
+func TestDatabase_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	database := testDatabase(t, path)
+	kept := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	removed := &db.Person{FirstName: "Some", LastName: "Tester"}
+	purged := &db.Person{FirstName: "Gone", LastName: "Forever"}
+	for _, person := range []*db.Person{kept, removed, purged} {
+		if err := database.Person().Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+	kept.FirstName = "Another"
+	assert.NoError(t, database.Person().Update(ctx, kept))
+	for _, person := range []*db.Person{removed, purged} {
+		_, err := database.Person().Remove(ctx, person.ID)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, database.Person().Purge(ctx, purged.ID))
+	assert.NoError(t, database.Close())
+
+	reopened := testDatabase(t, path)
+
+	got, err := reopened.Person().Get(ctx, kept.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, kept, got)
+
+	entries, err := reopened.PersonHistory().List(ctx, kept.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, db.ChangeUpdate, entries[1].Change)
+		assert.Equal(t, []db.FieldChange{{Field: db.PersonFirstName, Old: "Testy", New: "Another"}}, entries[1].Diff)
+	}
+
+	got, err = reopened.Person().Get(ctx, removed.ID)
+	assert.NoError(t, err)
+	assert.True(t, *got.Removed)
+
+	_, err = reopened.Person().Get(ctx, purged.ID)
+	assert.ErrorIs(t, err, db.ErrNoResultsFound)
+}
+
+func TestDatabase_TruncatedRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	database := testDatabase(t, path)
+	kept := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	lost := &db.Person{FirstName: "Some", LastName: "Tester"}
+	for _, person := range []*db.Person{kept, lost} {
+		if err := database.Person().Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+	assert.NoError(t, database.Close())
+
+	// Simulate a crash in the middle of writing the last record
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat log file: %v", err)
+	}
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatalf("failed to truncate log file: %v", err)
+	}
+
+	reopened := testDatabase(t, path)
+	_, err = reopened.Person().Get(ctx, kept.ID)
+	assert.NoError(t, err)
+	_, err = reopened.Person().Get(ctx, lost.ID)
+	assert.ErrorIs(t, err, db.ErrNoResultsFound)
+
+	// New records must be readable after the truncated one is discarded
+	added := &db.Person{FirstName: "Another", LastName: "Tester"}
+	assert.NoError(t, reopened.Person().Insert(ctx, added))
+	assert.NoError(t, reopened.Close())
+
+	_, err = testDatabase(t, path).Person().Get(ctx, added.ID)
+	assert.NoError(t, err)
+}
+
+// failingFile is a log file whose writes stop halfway and fail, as they would if the disk filled up
+type failingFile struct {
+	logFile
+	failTruncate bool
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	n, _ := f.logFile.Write(p[:len(p)/2])
+	return n, errors.New("mock error")
+}
+
+func (f *failingFile) Truncate(size int64) error {
+	if f.failTruncate {
+		return errors.New("mock error")
+	}
+	return f.logFile.Truncate(size)
+}
+
+func TestDatabase_FailedAppend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	database := testDatabase(t, path)
+	kept := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	if err := database.Person().Insert(ctx, kept); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+
+	file := database.log.file
+	database.log.file = &failingFile{logFile: file}
+	failed := &db.Person{FirstName: "Some", LastName: "Tester"}
+	assert.ErrorIs(t, database.Person().Insert(ctx, failed), db.ErrUnavailable)
+
+	// The partial record must be gone, or the records appended after it would be lost when the log is read
+	database.log.file = file
+	added := &db.Person{FirstName: "Another", LastName: "Tester"}
+	assert.NoError(t, database.Person().Insert(ctx, added))
+
+	// If the partial record can't be removed, nothing else may be appended
+	database.log.file = &failingFile{logFile: file, failTruncate: true}
+	assert.ErrorIs(t, database.Person().Insert(ctx, &db.Person{FirstName: "Some", LastName: "Tester"}), db.ErrUnavailable)
+	database.log.file = file
+	assert.ErrorIs(t, database.Person().Insert(ctx, &db.Person{FirstName: "Some", LastName: "Tester"}), db.ErrUnavailable)
+	assert.NoError(t, database.Close())
+
+	reopened := testDatabase(t, path)
+	_, err := reopened.Person().Get(ctx, kept.ID)
+	assert.NoError(t, err)
+	_, err = reopened.Person().Get(ctx, added.ID)
+	assert.NoError(t, err)
+	_, err = reopened.Person().Get(ctx, failed.ID)
+	assert.ErrorIs(t, err, db.ErrNoResultsFound)
+}
+
+func TestDatabase_Compact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	database := testDatabase(t, path)
+	person := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	purged := &db.Person{FirstName: "Gone", LastName: "Forever"}
+	for _, p := range []*db.Person{person, purged} {
+		if err := database.Person().Insert(ctx, p); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, database.Person().Update(ctx, person))
+		assert.NoError(t, database.Person().Update(ctx, purged))
+	}
+	_, err := database.Person().Remove(ctx, purged.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, database.Person().Purge(ctx, purged.ID))
+
+	before, _ := os.Stat(path)
+	assert.NoError(t, database.Compact())
+	after, _ := os.Stat(path)
+	assert.Less(t, after.Size(), before.Size())
+
+	// Writes made after compacting must end up in the new log file
+	person.FirstName = "Another"
+	assert.NoError(t, database.Person().Update(ctx, person))
+	assert.NoError(t, database.Close())
+
+	reopened := testDatabase(t, path)
+	got, err := reopened.Person().Get(ctx, person.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, person, got)
+
+	// Compaction keeps the history of the people that weren't purged
+	entries, err := reopened.PersonHistory().List(ctx, person.ID)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 12)
+	_, err = reopened.Person().Get(ctx, purged.ID)
+	assert.ErrorIs(t, err, db.ErrNoResultsFound)
+}
+
+func TestDatabase_Batch(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	database := testDatabase(t, path)
+	people := []*db.Person{
+		{FirstName: "Testy", LastName: "McTesterson"},
+		{FirstName: "Some", LastName: "Tester"},
+	}
+	assert.NoError(t, database.Person().InsertBatch(ctx, people))
+	_, err := database.Person().RemoveBatch(ctx, []uuid.UUID{people[0].ID, uuid.New()})
+	assert.Error(t, err)
+
+	// Every batch is written as a single record, even if some of its items failed
+	assert.Equal(t, 2, database.log.appendedSince())
+	assert.NoError(t, database.Close())
+
+	reopened := testDatabase(t, path)
+	got, err := reopened.Person().Get(ctx, people[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, *got.Removed)
+	got, err = reopened.Person().Get(ctx, people[1].ID)
+	assert.NoError(t, err)
+	assert.False(t, *got.Removed)
+}
+
+func TestDatabase_ReopenAfterTx(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	database := testDatabase(t, path)
+	committed := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	rolledBack := &db.Person{FirstName: "Some", LastName: "Tester"}
+	assert.NoError(t, database.WithTx(ctx, func(tx db.Database) error {
+		return tx.Person().Insert(ctx, committed)
+	}))
+	assert.Error(t, database.WithTx(ctx, func(tx db.Database) error {
+		if err := tx.Person().Insert(ctx, rolledBack); err != nil {
+			return err
+		}
+		return fmt.Errorf("mock error")
+	}))
+	assert.NoError(t, database.Close())
+
+	reopened := testDatabase(t, path)
+	_, err := reopened.Person().Get(ctx, committed.ID)
+	assert.NoError(t, err)
+	_, err = reopened.Person().Get(ctx, rolledBack.ID)
+	assert.ErrorIs(t, err, db.ErrNoResultsFound)
+}
+
+func TestDatabase_FeedSequence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+
+	database := testDatabase(t, path)
+	sub, err := database.PersonFeed().Watch(ctx, db.WatchOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch feed: %v", err)
+	}
+	person := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	purged := &db.Person{FirstName: "Gone", LastName: "Forever"}
+	for _, p := range []*db.Person{person, purged} {
+		if err := database.Person().Insert(ctx, p); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+	_, err = database.Person().Remove(ctx, purged.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, database.Person().Purge(ctx, purged.ID))
+
+	var last uint64
+	for i := 0; i < 4; i++ {
+		last = (<-sub.Events()).Sequence
+	}
+
+	// Compaction leaves out the records of the purged person, but must not forget their sequences
+	assert.NoError(t, database.Compact())
+	assert.NoError(t, database.Close())
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.ErrorIs(t, sub.Err(), db.ErrFeedClosed)
+
+	reopened := testDatabase(t, path)
+	_, err = reopened.PersonFeed().Watch(ctx, db.WatchOptions{From: last})
+	assert.ErrorIs(t, err, db.ErrSequenceExpired)
+
+	sub, err = reopened.PersonFeed().Watch(ctx, db.WatchOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch feed: %v", err)
+	}
+	defer sub.Close()
+	added := &db.Person{FirstName: "Another", LastName: "Tester"}
+	assert.NoError(t, reopened.Person().Insert(ctx, added))
+	event := <-sub.Events()
+	assert.Equal(t, added.ID, event.ID)
+	assert.Greater(t, event.Sequence, last)
+}
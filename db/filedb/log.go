@@ -0,0 +1,293 @@
+package filedb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+const (
+	// recordHeaderSize is the size of the length and checksum that prefix every record in the log
+	recordHeaderSize = 8
+	// maxRecordSize guards against allocating huge buffers when reading a corrupted record length
+	maxRecordSize = 64 << 20
+)
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	// opBatch records hold a list of records that must be applied together, for example the changes made in a transaction
+	opBatch = "batch"
+	// opSequence records only hold the sequence of the latest change, which compaction writes so that
+	// the feed doesn't reuse the sequences of changes whose records were left out
+	opSequence = "sequence"
+)
+
+// record is a single change that is written to the log
+type record struct {
+	Entity string          `json:"entity,omitempty"`
+	Op     string          `json:"op"`
+	Data   json.RawMessage `json:"data"`
+	// History describes the change a put record makes, so that the history of the item can be rebuilt.
+	// Records that only restore the current state of an item, like the ones written by compaction, don't have it.
+	History *historyInfo `json:"history,omitempty"`
+	// Sequence is the sequence of the event that reported the change on the feed.
+	Sequence uint64 `json:"seq,omitempty"`
+}
+
+// historyInfo is what, besides the item itself, makes up a db.HistoryEntry
+type historyInfo struct {
+	Change    db.Change `json:"change"`
+	Principal string    `json:"principal,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// logFile is the part of *os.File the log writes to, so that tests can make writes fail
+type logFile interface {
+	io.WriteSeeker
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// appendLog is an append-only file of length-prefixed, checksummed records.
+type appendLog struct {
+	mu       sync.Mutex
+	path     string
+	file     logFile
+	appended int // the number of records appended since the log was opened or last rewritten
+	// broken is set if a failed append couldn't be undone, after which the log refuses further appends,
+	// since they would be written after a partial record and lost when the log is read.
+	broken error
+}
+
+// openLog opens the log file at path, creating it if needed, and returns all the records it holds.
+// A truncated or corrupted record at the end of the file is removed from it.
+func openLog(path string) (*appendLog, []record, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	records, validSize, err := readRecords(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+
+	// Drop whatever was left over from a write that never completed, so that new records are appended after valid data.
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to truncate log file: %w", err)
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to seek log file: %w", err)
+	}
+
+	return &appendLog{path: path, file: file}, records, nil
+}
+
+// readRecords reads records until the end of r or until a record that is incomplete or fails its checksum.
+// It returns the records that were read along with the number of bytes they take up.
+func readRecords(r io.Reader) ([]record, int64, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, recordHeaderSize)
+
+	var records []record
+	var validSize int64
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, validSize, nil
+			}
+			return nil, 0, err
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		checksum := binary.LittleEndian.Uint32(header[4:8])
+		if size > maxRecordSize {
+			return records, validSize, nil
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, validSize, nil
+			}
+			return nil, 0, err
+		}
+		if crc32.ChecksumIEEE(payload) != checksum {
+			return records, validSize, nil
+		}
+
+		var rec record
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			return records, validSize, nil
+		}
+
+		records = append(records, rec)
+		validSize += int64(recordHeaderSize) + int64(size)
+	}
+}
+
+// encodeRecord frames the given record so that it can be written to the log
+func encodeRecord(rec record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode log record: %w", err)
+	}
+
+	frame := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(payload))
+	return append(frame, payload...), nil
+}
+
+// append writes the given records to the end of the log and flushes them to disk.
+func (l *appendLog) append(records ...record) error {
+	var buf []byte
+	for _, rec := range records {
+		frame, err := encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, frame...)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.broken != nil {
+		return fmt.Errorf("%w: log file is unusable after a failed write: %w", db.ErrUnavailable, l.broken)
+	}
+	// Records are always added at the end, which is also where a failed append is undone from
+	offset, err := l.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to seek log file: %w", classifyIOError(err))
+	}
+
+	if _, err := l.file.Write(buf); err != nil {
+		l.undo(offset)
+		return fmt.Errorf("failed to write to log file: %w", classifyIOError(err))
+	}
+	if err := l.file.Sync(); err != nil {
+		l.undo(offset)
+		return fmt.Errorf("failed to sync log file: %w", classifyIOError(err))
+	}
+
+	l.appended += len(records)
+	return nil
+}
+
+// undo removes whatever a failed append left behind after offset, so that later records aren't written after
+// a partial one. If that fails too, the log is marked as broken. The caller must hold the lock.
+func (l *appendLog) undo(offset int64) {
+	if err := l.file.Truncate(offset); err != nil {
+		l.broken = err
+		return
+	}
+	if _, err := l.file.Seek(offset, io.SeekStart); err != nil {
+		l.broken = err
+		return
+	}
+	if err := l.file.Sync(); err != nil {
+		l.broken = err
+	}
+}
+
+// classifyIOError wraps an error that occurred while writing to the log in the db error that describes it.
+// Changes that couldn't be written are rejected, so every I/O error makes the database unavailable
+// unless it is caused by missing permissions.
+func classifyIOError(err error) error {
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("%w: %w", db.ErrPermissionDenied, err)
+	}
+	return fmt.Errorf("%w: %w", db.ErrUnavailable, err)
+}
+
+// rewrite atomically replaces the contents of the log with the given records.
+func (l *appendLog) rewrite(records []record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tmpPath := l.path + ".compact"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted log file: %w", err)
+	}
+	defer os.Remove(tmpPath) // Only has an effect if the rename below didn't happen
+
+	writer := bufio.NewWriter(tmpFile)
+	for _, rec := range records {
+		frame, err := encodeRecord(rec)
+		if err != nil {
+			tmpFile.Close()
+			return err
+		}
+		if _, err := writer.Write(frame); err != nil {
+			tmpFile.Close()
+			return fmt.Errorf("failed to write compacted log file: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write compacted log file: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to sync compacted log file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close compacted log file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return fmt.Errorf("failed to replace log file: %w", err)
+	}
+	syncDir(filepath.Dir(l.path))
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen log file: %w", err)
+	}
+
+	l.file.Close()
+	l.file = file
+	l.appended = 0
+	l.broken = nil
+	return nil
+}
+
+// appendedSince reports how many records have been appended since the log was opened or last rewritten
+func (l *appendLog) appendedSince() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.appended
+}
+
+func (l *appendLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// syncDir flushes a directory to disk so that a rename within it survives a crash.
+// Not every platform supports this, so errors are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
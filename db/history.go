@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/williabk198/go-api-server-template/auth"
+)
+
+// Change is the kind of change a HistoryEntry records
+type Change string
+
+const (
+	ChangeInsert  Change = "insert"
+	ChangeUpdate  Change = "update"
+	ChangeRemove  Change = "remove"
+	ChangeRestore Change = "restore"
+)
+
+// HistoryEntry records a single change that was made to an item
+type HistoryEntry[T Entity] struct {
+	// Version is the version of the item that the change produced.
+	Version int
+	Change  Change
+	// Principal is the name of the principal that made the change, or empty if it was made anonymously.
+	Principal string
+	Timestamp time.Time
+	// Item is a snapshot of the item right after the change.
+	Item T
+	// Diff lists the fields the change modified. For inserts it lists the fields that were set.
+	Diff []FieldChange
+}
+
+// FieldChange is the change of a single field between two versions of an item
+type FieldChange struct {
+	Field Field
+	Old   any
+	New   any
+}
+
+// HistoryStore gives access to the history of the items of a Datastore.
+// The datastore records an entry every time it inserts, updates, removes or restores an item,
+// and the history of an item is purged together with the item.
+type HistoryStore[T Entity, U Identifier] interface {
+	// Get returns the entry that produced the given version of an item.
+	// If the item or version doesn't exist, then ErrNoResultsFound is returned.
+	Get(ctx context.Context, id U, version int) (*HistoryEntry[T], error)
+	// List returns every entry in the history of an item, oldest first.
+	// If the item doesn't have a history, then ErrNoResultsFound is returned.
+	List(ctx context.Context, id U) ([]HistoryEntry[T], error)
+}
+
+// ActingPrincipal returns the name of the principal on whose behalf a change is made, or an empty string if it is anonymous.
+// It is meant for the datastores, to fill in HistoryEntry.Principal.
+func ActingPrincipal(ctx context.Context) string {
+	principal, _ := auth.FromContext(ctx)
+	return principal.Name
+}
@@ -0,0 +1,54 @@
+package instrument
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Entities that the datastores of a Database are labelled with
+const (
+	personEntity        = "person"
+	personHistoryEntity = "person_history"
+	// databaseEntity labels the operations of the database as a whole, such as transactions
+	databaseEntity = "database"
+)
+
+// Database is a db.Database whose datastores measure every call made to the datastores of another db.Database.
+// Whole transactions are measured too, as the "tx" operation of the "database" entity.
+type Database struct {
+	next    db.Database
+	metrics *Metrics
+	opts    []Option
+}
+
+// Wrap returns a Database that records the calls made to database in metrics.
+func Wrap(database db.Database, metrics *Metrics, opts ...Option) *Database {
+	return &Database{next: database, metrics: metrics, opts: opts}
+}
+
+// Person implements db.Database.
+func (d *Database) Person() db.Datastore[db.Person, uuid.UUID] {
+	return New(d.next.Person(), personEntity, d.metrics, d.opts...)
+}
+
+// PersonHistory implements db.Database.
+func (d *Database) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return NewHistoryStore(d.next.PersonHistory(), personHistoryEntity, d.metrics, d.opts...)
+}
+
+// PersonFeed implements db.Database. Subscriptions last as long as their subscribers want, so they aren't measured.
+func (d *Database) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return d.next.PersonFeed()
+}
+
+// WithTx implements db.Database. The datastores of the Database passed to fn are measured as well.
+func (d *Database) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	o := observer{entity: databaseEntity, metrics: d.metrics, options: newOptions(d.opts)}
+	return o.observe(ctx, "tx", func() error {
+		return d.next.WithTx(ctx, func(tx db.Database) error {
+			return fn(&Database{next: tx, metrics: d.metrics, opts: d.opts})
+		})
+	})
+}
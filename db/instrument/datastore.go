@@ -0,0 +1,201 @@
+package instrument
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Option configures how calls are measured
+type Option func(*options)
+
+type options struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	clock         db.Clock
+}
+
+// WithSlowThreshold logs every call that takes longer than threshold to logger, at the warning level.
+// Slow calls are not logged unless this option is used.
+func WithSlowThreshold(logger *slog.Logger, threshold time.Duration) Option {
+	return func(o *options) {
+		o.logger = logger
+		o.slowThreshold = threshold
+	}
+}
+
+// WithClock sets the clock that is used to measure how long calls take. By default the system clock is used.
+func WithClock(clock db.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{clock: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// observer measures the calls made to the datastores of a single entity
+type observer struct {
+	entity  string
+	metrics *Metrics
+	options
+}
+
+// observe runs fn as the given operation, and records how long it took and whether it failed
+func (o observer) observe(ctx context.Context, operation string, fn func() error) error {
+	inFlight := o.metrics.inFlight.WithLabelValues(o.entity, operation)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	start := o.clock()
+	err := fn()
+	elapsed := o.clock().Sub(start)
+
+	o.metrics.duration.WithLabelValues(o.entity, operation).Observe(elapsed.Seconds())
+	if err != nil {
+		o.metrics.errors.WithLabelValues(o.entity, operation, ErrorClass(err)).Inc()
+	}
+
+	if o.logger != nil && o.slowThreshold > 0 && elapsed > o.slowThreshold {
+		attrs := []any{"entity", o.entity, "operation", operation, "duration", elapsed}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+		o.logger.WarnContext(ctx, "slow database operation", attrs...)
+	}
+
+	return err
+}
+
+// Datastore is a db.Datastore that measures every call made to another db.Datastore.
+type Datastore[T db.Entity, U db.Identifier] struct {
+	next     db.Datastore[T, U]
+	observer observer
+}
+
+// New wraps next in a Datastore that records its calls in metrics, labelled with the given entity.
+func New[T db.Entity, U db.Identifier](next db.Datastore[T, U], entity string, metrics *Metrics, opts ...Option) *Datastore[T, U] {
+	return &Datastore[T, U]{
+		next:     next,
+		observer: observer{entity: entity, metrics: metrics, options: newOptions(opts)},
+	}
+}
+
+// Get implements db.Datastore.
+func (d *Datastore[T, U]) Get(ctx context.Context, id U) (item *T, err error) {
+	err = d.observer.observe(ctx, "get", func() error {
+		item, err = d.next.Get(ctx, id)
+		return err
+	})
+	return item, err
+}
+
+// Insert implements db.Datastore.
+func (d *Datastore[T, U]) Insert(ctx context.Context, item *T) error {
+	return d.observer.observe(ctx, "insert", func() error {
+		return d.next.Insert(ctx, item)
+	})
+}
+
+// InsertBatch implements db.Datastore.
+func (d *Datastore[T, U]) InsertBatch(ctx context.Context, items []*T) error {
+	return d.observer.observe(ctx, "insert_batch", func() error {
+		return d.next.InsertBatch(ctx, items)
+	})
+}
+
+// Purge implements db.Datastore.
+func (d *Datastore[T, U]) Purge(ctx context.Context, id U) error {
+	return d.observer.observe(ctx, "purge", func() error {
+		return d.next.Purge(ctx, id)
+	})
+}
+
+// Query implements db.Datastore.
+func (d *Datastore[T, U]) Query(ctx context.Context, query db.Query) (page *db.Page[T], err error) {
+	err = d.observer.observe(ctx, "query", func() error {
+		page, err = d.next.Query(ctx, query)
+		return err
+	})
+	return page, err
+}
+
+// Remove implements db.Datastore.
+func (d *Datastore[T, U]) Remove(ctx context.Context, id U) (item *T, err error) {
+	err = d.observer.observe(ctx, "remove", func() error {
+		item, err = d.next.Remove(ctx, id)
+		return err
+	})
+	return item, err
+}
+
+// RemoveBatch implements db.Datastore.
+func (d *Datastore[T, U]) RemoveBatch(ctx context.Context, ids []U) (items []*T, err error) {
+	err = d.observer.observe(ctx, "remove_batch", func() error {
+		items, err = d.next.RemoveBatch(ctx, ids)
+		return err
+	})
+	return items, err
+}
+
+// Restore implements db.Datastore.
+func (d *Datastore[T, U]) Restore(ctx context.Context, id U) (item *T, err error) {
+	err = d.observer.observe(ctx, "restore", func() error {
+		item, err = d.next.Restore(ctx, id)
+		return err
+	})
+	return item, err
+}
+
+// Update implements db.Datastore.
+func (d *Datastore[T, U]) Update(ctx context.Context, item *T) error {
+	return d.observer.observe(ctx, "update", func() error {
+		return d.next.Update(ctx, item)
+	})
+}
+
+// UpdateBatch implements db.Datastore.
+func (d *Datastore[T, U]) UpdateBatch(ctx context.Context, items []*T) error {
+	return d.observer.observe(ctx, "update_batch", func() error {
+		return d.next.UpdateBatch(ctx, items)
+	})
+}
+
+// HistoryStore is a db.HistoryStore that measures every call made to another db.HistoryStore.
+type HistoryStore[T db.Entity, U db.Identifier] struct {
+	next     db.HistoryStore[T, U]
+	observer observer
+}
+
+// NewHistoryStore wraps next in a HistoryStore that records its calls in metrics, labelled with the given entity.
+func NewHistoryStore[T db.Entity, U db.Identifier](next db.HistoryStore[T, U], entity string, metrics *Metrics, opts ...Option) *HistoryStore[T, U] {
+	return &HistoryStore[T, U]{
+		next:     next,
+		observer: observer{entity: entity, metrics: metrics, options: newOptions(opts)},
+	}
+}
+
+// Get implements db.HistoryStore.
+func (h *HistoryStore[T, U]) Get(ctx context.Context, id U, version int) (entry *db.HistoryEntry[T], err error) {
+	err = h.observer.observe(ctx, "get", func() error {
+		entry, err = h.next.Get(ctx, id, version)
+		return err
+	})
+	return entry, err
+}
+
+// List implements db.HistoryStore.
+func (h *HistoryStore[T, U]) List(ctx context.Context, id U) (entries []db.HistoryEntry[T], err error) {
+	err = h.observer.observe(ctx, "list", func() error {
+		entries, err = h.next.List(ctx, id)
+		return err
+	})
+	return entries, err
+}
@@ -0,0 +1,7 @@
+// instrument wraps a db.Database, or any single db.Datastore, so that every call made to it is measured.
+//
+// The latency, errors and number of in-flight calls of every operation are recorded as Prometheus metrics,
+// labelled with the entity and operation, and calls that take longer than a threshold are logged.
+// Errors are counted by their class, such as "not_found" or "unavailable", which is derived from the
+// errors of the db package.
+package instrument
@@ -0,0 +1,155 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/dbtest"
+	"github.com/williabk198/go-api-server-template/db/memdb"
+)
+
+func newTestMetrics(t *testing.T) *Metrics {
+	t.Helper()
+
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("failed to create metrics: %v", err)
+	}
+	return metrics
+}
+
+func TestPersonDatastore(t *testing.T) {
+	dbtest.RunPersonDatastore(t, Wrap(memdb.NewSession(), newTestMetrics(t)))
+}
+
+func TestPersonBatch(t *testing.T) {
+	dbtest.RunPersonBatch(t, Wrap(memdb.NewSession(), newTestMetrics(t)))
+}
+
+func TestPersonHistory(t *testing.T) {
+	dbtest.RunPersonHistory(t, Wrap(memdb.NewSession(), newTestMetrics(t)))
+}
+
+func TestPersonFeed(t *testing.T) {
+	dbtest.RunPersonFeed(t, Wrap(memdb.NewSession(), newTestMetrics(t)))
+}
+
+func TestTx(t *testing.T) {
+	dbtest.RunTx(t, Wrap(memdb.NewSession(), newTestMetrics(t)))
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: db.ErrNoResultsFound, want: "not_found"},
+		{err: db.ErrVersionConflict, want: "version_conflict"},
+		{err: db.ErrAlreadyRemoved, want: "conflict"},
+		{err: fmt.Errorf("%w: bad value", db.ErrInvalidArgument), want: "invalid_argument"},
+		{err: db.ErrInvalidQuery, want: "invalid_argument"},
+		{err: db.ErrPermissionDenied, want: "permission_denied"},
+		{err: fmt.Errorf("%w: connection refused", db.ErrUnavailable), want: "unavailable"},
+		{err: context.DeadlineExceeded, want: "deadline_exceeded"},
+		{err: context.Canceled, want: "canceled"},
+		{err: errors.New("boom"), want: "internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, ErrorClass(tt.err))
+		})
+	}
+}
+
+func TestDatastore_Metrics(t *testing.T) {
+	ctx := context.Background()
+	metrics := newTestMetrics(t)
+	store := Wrap(memdb.NewSession(), metrics).Person()
+
+	person := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	if err := store.Insert(ctx, person); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+	store.Get(ctx, person.ID)
+	store.Get(ctx, uuid.New())
+	store.Restore(ctx, person.ID)
+
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.duration))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.errors.WithLabelValues("person", "get", "not_found")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.errors.WithLabelValues("person", "restore", "conflict")))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.errors))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.inFlight.WithLabelValues("person", "get")))
+}
+
+// blockingDatastore makes every call to Get wait until release is closed
+type blockingDatastore struct {
+	db.Datastore[db.Person, uuid.UUID]
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b blockingDatastore) Get(ctx context.Context, id uuid.UUID) (*db.Person, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return b.Datastore.Get(ctx, id)
+}
+
+func TestDatastore_InFlight(t *testing.T) {
+	ctx := context.Background()
+	metrics := newTestMetrics(t)
+	backend := blockingDatastore{
+		Datastore: memdb.NewSession().Person(),
+		started:   make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+	store := New[db.Person, uuid.UUID](backend, "person", metrics)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		store.Get(ctx, uuid.New())
+	}()
+
+	<-backend.started
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.inFlight.WithLabelValues("person", "get")))
+	close(backend.release)
+	<-done
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.inFlight.WithLabelValues("person", "get")))
+}
+
+func TestDatastore_SlowLog(t *testing.T) {
+	ctx := context.Background()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	// Every call to the clock moves it forward by the given step, so each call takes exactly one step
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	step := 50 * time.Millisecond
+	clock := func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+
+	store := New(memdb.NewSession().Person(), "person", newTestMetrics(t),
+		WithSlowThreshold(logger, 100*time.Millisecond), WithClock(clock))
+
+	store.Get(ctx, uuid.New())
+	assert.Empty(t, logs.String())
+
+	step = 150 * time.Millisecond
+	store.Get(ctx, uuid.New())
+	assert.Contains(t, logs.String(), `"msg":"slow database operation"`)
+	assert.Contains(t, logs.String(), `"operation":"get"`)
+	assert.Contains(t, logs.String(), `"error":`)
+}
@@ -0,0 +1,72 @@
+package instrument
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Metrics holds the Prometheus metrics that instrumented datastores record their calls in.
+// A single Metrics is meant to be shared by every datastore of a server.
+type Metrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+}
+
+// NewMetrics creates the metrics of instrumented datastores and registers them with registerer.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "db",
+			Name:      "operation_duration_seconds",
+			Help:      "How long database operations took, including the ones that failed.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"entity", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "db",
+			Name:      "operation_errors_total",
+			Help:      "The number of database operations that failed, by class of error.",
+		}, []string{"entity", "operation", "class"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "db",
+			Name:      "operations_in_flight",
+			Help:      "The number of database operations that are currently running.",
+		}, []string{"entity", "operation"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.duration, m.errors, m.inFlight} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ErrorClass returns the label that errors of the same kind are counted under, such as "not_found"
+// for errors wrapping db.ErrNoResultsFound. Errors that don't wrap any error of the db package are "internal".
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, db.ErrNoResultsFound):
+		return "not_found"
+	case errors.Is(err, db.ErrVersionConflict):
+		return "version_conflict"
+	case errors.Is(err, db.ErrConflict):
+		return "conflict"
+	case errors.Is(err, db.ErrInvalidArgument):
+		return "invalid_argument"
+	case errors.Is(err, db.ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, db.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, db.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "internal"
+	}
+}
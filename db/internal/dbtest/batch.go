@@ -0,0 +1,127 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// RunPersonBatch checks that the batch operations of the person datastore of the given database process every item
+// independently, report the errors of the items that failed, and can be made all-or-nothing with WithTx.
+func RunPersonBatch(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	store := database.Person()
+
+	// insertPeople inserts the given number of people in a single batch
+	insertPeople := func(t *testing.T, n int) []*db.Person {
+		t.Helper()
+		people := make([]*db.Person, n)
+		for i := range people {
+			people[i] = &db.Person{FirstName: "Batch", LastName: "Tester"}
+		}
+		if err := store.InsertBatch(ctx, people); err != nil {
+			t.Fatalf("failed to insert batch: %v", err)
+		}
+		return people
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		people := insertPeople(t, 3)
+		for _, person := range people {
+			assert.NotEqual(t, uuid.Nil, person.ID)
+			assert.Equal(t, 1, person.Version)
+
+			got, err := store.Get(ctx, person.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "Batch", got.FirstName)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		people := insertPeople(t, 2)
+		missing := &db.Person{ID: uuid.New(), FirstName: "Not", LastName: "There"}
+		people[0].FirstName = "Changed"
+		people[1].FirstName = "Changed"
+
+		err := store.UpdateBatch(ctx, []*db.Person{people[0], missing, people[1]})
+		var batchErr db.BatchError
+		if !errors.As(err, &batchErr) {
+			t.Fatalf("expected a BatchError, got %v", err)
+		}
+		if assert.Len(t, batchErr, 3) {
+			assert.NoError(t, batchErr[0])
+			assert.ErrorIs(t, batchErr[1], db.ErrNoResultsFound)
+			assert.NoError(t, batchErr[2])
+		}
+
+		for _, person := range people {
+			assert.Equal(t, 2, person.Version)
+			got, err := store.Get(ctx, person.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "Changed", got.FirstName)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		people := insertPeople(t, 2)
+		if _, err := store.Remove(ctx, people[1].ID); err != nil {
+			t.Fatalf("failed to remove person: %v", err)
+		}
+
+		removed, err := store.RemoveBatch(ctx, []uuid.UUID{people[0].ID, people[1].ID})
+		var batchErr db.BatchError
+		if !errors.As(err, &batchErr) {
+			t.Fatalf("expected a BatchError, got %v", err)
+		}
+		assert.NoError(t, batchErr[0])
+		assert.ErrorIs(t, batchErr[1], db.ErrAlreadyRemoved)
+		if assert.Len(t, removed, 2) {
+			assert.True(t, *removed[0].Removed)
+			assert.Equal(t, 2, removed[0].Version)
+			assert.Nil(t, removed[1])
+		}
+	})
+
+	t.Run("All or Nothing", func(t *testing.T) {
+		existing := insertPeople(t, 1)[0]
+		inserted := []*db.Person{{FirstName: "Rolled", LastName: "Back"}}
+
+		err := database.WithTx(ctx, func(tx db.Database) error {
+			if err := tx.Person().InsertBatch(ctx, inserted); err != nil {
+				return err
+			}
+			_, err := tx.Person().RemoveBatch(ctx, []uuid.UUID{existing.ID, uuid.New()})
+			return err
+		})
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+
+		_, err = store.Get(ctx, inserted[0].ID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+		got, err := store.Get(ctx, existing.ID)
+		assert.NoError(t, err)
+		assert.False(t, *got.Removed)
+	})
+
+	t.Run("Best Effort in Transaction", func(t *testing.T) {
+		existing := insertPeople(t, 1)[0]
+
+		// A failed item must not keep the rest of the transaction from being committed
+		err := database.WithTx(ctx, func(tx db.Database) error {
+			_, err := tx.Person().RemoveBatch(ctx, []uuid.UUID{uuid.New(), existing.ID})
+			var batchErr db.BatchError
+			if errors.As(err, &batchErr) {
+				return nil
+			}
+			return err
+		})
+		assert.NoError(t, err)
+
+		got, err := store.Get(ctx, existing.ID)
+		assert.NoError(t, err)
+		assert.True(t, *got.Removed)
+	})
+}
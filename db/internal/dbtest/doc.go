@@ -0,0 +1,5 @@
+// dbtest holds a conformance test suite that every implementation of db.Database is expected to pass.
+//
+// Implementations call the exported functions from their own tests, so that they are all held
+// to the same contract that is described in package db.
+package dbtest
@@ -0,0 +1,57 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// RunPersonErrors checks that the person datastore of the given database reports failures with the database agnostic
+// errors of package db, so that callers can handle them without knowing which database they are using.
+func RunPersonErrors(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	store := database.Person()
+
+	person := &db.Person{FirstName: "Some", LastName: "Tester"}
+	if err := store.Insert(ctx, person); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+
+	t.Run("Conflicts", func(t *testing.T) {
+		stale := *person
+		updated := *person
+		assert.NoError(t, store.Update(ctx, &updated))
+		assert.ErrorIs(t, store.Update(ctx, &stale), db.ErrConflict)
+		assert.ErrorIs(t, store.Purge(ctx, person.ID), db.ErrConflict)
+	})
+
+	t.Run("Invalid Query", func(t *testing.T) {
+		_, err := store.Query(ctx, db.Query{Filters: []db.Filter{{Field: "unknown", Operator: db.Equal, Value: "x"}}})
+		assert.ErrorIs(t, err, db.ErrInvalidArgument)
+	})
+
+	t.Run("Deadline Exceeded", func(t *testing.T) {
+		expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := store.Get(expired, person.ID)
+		assert.ErrorIs(t, err, db.ErrDeadlineExceeded)
+		assert.ErrorIs(t, store.Update(expired, person), db.ErrDeadlineExceeded)
+		_, err = store.RemoveBatch(expired, []uuid.UUID{person.ID})
+		assert.ErrorIs(t, err, db.ErrDeadlineExceeded)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := store.Get(canceled, person.ID)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, errors.Is(err, db.ErrDeadlineExceeded), "a canceled request did not run out of time")
+	})
+}
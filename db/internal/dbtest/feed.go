@@ -0,0 +1,287 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/auth"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// feedTimeout is how long to wait for an event. Persistent databases may deliver events asynchronously.
+const feedTimeout = 5 * time.Second
+
+// idleFeed is implemented by feeds that stop following the changes of their database while nobody watches them.
+// Idle returns a channel that is closed once they have.
+type idleFeed interface {
+	Idle() <-chan struct{}
+}
+
+// RunPersonFeed checks that the given database reports the changes made to people on its feed.
+func RunPersonFeed(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	errMock := errors.New("mock error")
+
+	t.Run("Changes", func(t *testing.T) {
+		ctx := auth.WithPrincipal(ctx, auth.Principal{Name: "tester"})
+		sub := watch(t, database, db.WatchOptions{})
+		store := database.Person()
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+		person.FirstName = "Another"
+		assert.NoError(t, store.Update(ctx, person))
+		_, err := store.Remove(ctx, person.ID)
+		assert.NoError(t, err)
+		_, err = store.Restore(ctx, person.ID)
+		assert.NoError(t, err)
+		_, err = store.Remove(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Purge(ctx, person.ID))
+
+		wantChanges := []db.Change{db.ChangeInsert, db.ChangeUpdate, db.ChangeRemove, db.ChangeRestore, db.ChangeRemove, db.ChangePurge}
+		events := receive(t, sub, len(wantChanges))
+		if events == nil {
+			return
+		}
+
+		for i, event := range events {
+			assert.Equal(t, wantChanges[i], event.Change, "event %d", i)
+			assert.Equal(t, person.ID, event.ID, "event %d", i)
+			assert.False(t, event.Timestamp.IsZero(), "event %d", i)
+			if i > 0 {
+				assert.Greater(t, event.Sequence, events[i-1].Sequence)
+				assert.Equal(t, events[i-1].After, event.Before, "event %d", i)
+			}
+		}
+
+		assert.Equal(t, "tester", events[0].Principal)
+		assert.Nil(t, events[0].Before)
+		if assert.NotNil(t, events[0].After) {
+			assert.Equal(t, "Some", events[0].After.FirstName)
+			assert.Equal(t, 1, events[0].After.Version)
+		}
+		if assert.NotNil(t, events[1].After) {
+			assert.Equal(t, "Another", events[1].After.FirstName)
+		}
+		if assert.NotNil(t, events[2].After) {
+			assert.Equal(t, db.NewBool(true), events[2].After.Removed)
+		}
+		assert.Nil(t, events[5].After)
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		sub := watch(t, database, db.WatchOptions{})
+
+		err := database.WithTx(ctx, func(tx db.Database) error {
+			if err := tx.Person().Insert(ctx, &db.Person{FirstName: "Rolled", LastName: "Back"}); err != nil {
+				return err
+			}
+			return errMock
+		})
+		assert.ErrorIs(t, err, errMock)
+
+		first := &db.Person{FirstName: "Some", LastName: "Tester"}
+		second := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+		err = database.WithTx(ctx, func(tx db.Database) error {
+			if err := tx.Person().Insert(ctx, first); err != nil {
+				return err
+			}
+			return tx.Person().Insert(ctx, second)
+		})
+		assert.NoError(t, err)
+
+		// The rolled back insert must not be reported, so the committed ones come first
+		events := receive(t, sub, 2)
+		if events == nil {
+			return
+		}
+		assert.Equal(t, first.ID, events[0].ID)
+		assert.Equal(t, second.ID, events[1].ID)
+	})
+
+	t.Run("FanOut", func(t *testing.T) {
+		subs := []db.Subscription[db.Person, uuid.UUID]{
+			watch(t, database, db.WatchOptions{}),
+			watch(t, database, db.WatchOptions{}),
+		}
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := database.Person().Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+
+		for _, sub := range subs {
+			if events := receive(t, sub, 1); events != nil {
+				assert.Equal(t, person.ID, events[0].ID)
+			}
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		sub := watch(t, database, db.WatchOptions{})
+		for _, name := range []string{"Some", "Another"} {
+			if err := database.Person().Insert(ctx, &db.Person{FirstName: name, LastName: "Tester"}); err != nil {
+				t.Fatalf("failed to insert person: %v", err)
+			}
+		}
+		events := receive(t, sub, 2)
+		if events == nil {
+			return
+		}
+		sub.Close()
+
+		resumed := receive(t, watch(t, database, db.WatchOptions{From: events[1].Sequence}), 1)
+		assert.Equal(t, events[1:], resumed)
+		resumed = receive(t, watch(t, database, db.WatchOptions{From: events[0].Sequence}), 2)
+		assert.Equal(t, events, resumed)
+	})
+
+	t.Run("Unwatched", func(t *testing.T) {
+		watch(t, database, db.WatchOptions{}).Close()
+		if feed, ok := database.PersonFeed().(idleFeed); ok {
+			select {
+			case <-feed.Idle():
+			case <-time.After(feedTimeout):
+				t.Fatal("the feed is still followed after its last subscriber left")
+			}
+		}
+
+		// Changes made while nobody watched are only delivered to subscribers that resume from an earlier sequence
+		insertPeople(t, database, 2)
+		sub := watch(t, database, db.WatchOptions{})
+		person := insertPeople(t, database, 1)[0]
+
+		if events := receive(t, sub, 1); events != nil {
+			assert.Equal(t, person.ID, events[0].ID)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		sub := watch(t, database, db.WatchOptions{})
+		sub.Close()
+		sub.Close()
+
+		assert.True(t, ended(t, sub))
+		assert.NoError(t, sub.Err())
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		sub, err := database.PersonFeed().Watch(ctx, db.WatchOptions{})
+		if err != nil {
+			t.Fatalf("failed to watch feed: %v", err)
+		}
+		cancel()
+
+		assert.True(t, ended(t, sub))
+		assert.ErrorIs(t, sub.Err(), context.Canceled)
+	})
+
+	t.Run("OverflowClose", func(t *testing.T) {
+		sub := watch(t, database, db.WatchOptions{Buffer: 1})
+		people := insertPeople(t, database, 3)
+
+		var received []db.Event[db.Person, uuid.UUID]
+		for event := range sub.Events() {
+			received = append(received, event)
+		}
+		assert.ErrorIs(t, sub.Err(), db.ErrSubscriberOverflow)
+		if assert.NotEmpty(t, received) {
+			assert.Less(t, len(received), len(people))
+			assert.Equal(t, people[0].ID, received[0].ID)
+		}
+	})
+
+	t.Run("OverflowDropOldest", func(t *testing.T) {
+		sub := watch(t, database, db.WatchOptions{Buffer: 1, Overflow: db.OverflowDropOldest})
+		people := insertPeople(t, database, 3)
+
+		// Only the event that is being delivered and the one in the buffer are left
+		last := people[len(people)-1].ID
+		var received []db.Event[db.Person, uuid.UUID]
+		for len(received) == 0 || received[len(received)-1].ID != last {
+			events := receive(t, sub, 1)
+			if events == nil {
+				return
+			}
+			received = append(received, events...)
+		}
+		assert.Less(t, len(received), len(people))
+		assert.NoError(t, sub.Err())
+	})
+}
+
+// watch subscribes to the person feed of database until the test is over
+func watch(t *testing.T, database db.Database, opts db.WatchOptions) db.Subscription[db.Person, uuid.UUID] {
+	t.Helper()
+
+	sub, err := database.PersonFeed().Watch(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("failed to watch feed: %v", err)
+	}
+	t.Cleanup(sub.Close)
+
+	return sub
+}
+
+// receive waits for the next n events of sub. It fails the test and returns nil if they don't arrive in time.
+func receive(t *testing.T, sub db.Subscription[db.Person, uuid.UUID], n int) []db.Event[db.Person, uuid.UUID] {
+	t.Helper()
+
+	timeout := time.After(feedTimeout)
+	events := make([]db.Event[db.Person, uuid.UUID], 0, n)
+	for len(events) < n {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				t.Errorf("subscription ended after %d of %d events: %v", len(events), n, sub.Err())
+				return nil
+			}
+			events = append(events, event)
+		case <-timeout:
+			t.Errorf("received %d of %d events in time", len(events), n)
+			return nil
+		}
+	}
+
+	return events
+}
+
+// ended waits for the events of sub to be closed, and reports whether they were in time
+func ended(t *testing.T, sub db.Subscription[db.Person, uuid.UUID]) bool {
+	t.Helper()
+
+	timeout := time.After(feedTimeout)
+	for {
+		select {
+		case _, ok := <-sub.Events():
+			if !ok {
+				return true
+			}
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+// insertPeople inserts n people into database, one at a time
+func insertPeople(t *testing.T, database db.Database, n int) []*db.Person {
+	t.Helper()
+
+	people := make([]*db.Person, n)
+	for i := range people {
+		people[i] = &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := database.Person().Insert(context.Background(), people[i]); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+
+	return people
+}
@@ -0,0 +1,119 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/auth"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// RunPersonHistory checks that the given database records the history of the people it stores.
+func RunPersonHistory(t *testing.T, database db.Database) {
+	t.Run("Changes", func(t *testing.T) {
+		start := time.Now().Add(-time.Second)
+		ctx := auth.WithPrincipal(context.Background(), auth.Principal{Name: "tester"})
+		store := database.Person()
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester", DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)}
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+		person.FirstName = "Another"
+		assert.NoError(t, store.Update(ctx, person))
+		_, err := store.Remove(context.Background(), person.ID) // Made anonymously
+		assert.NoError(t, err)
+		_, err = store.Restore(ctx, person.ID)
+		assert.NoError(t, err)
+
+		entries, err := database.PersonHistory().List(ctx, person.ID)
+		if err != nil {
+			t.Fatalf("failed to list history: %v", err)
+		}
+		if !assert.Len(t, entries, 4) {
+			return
+		}
+
+		wantChanges := []db.Change{db.ChangeInsert, db.ChangeUpdate, db.ChangeRemove, db.ChangeRestore}
+		wantPrincipals := []string{"tester", "tester", "", "tester"}
+		for i, entry := range entries {
+			assert.Equal(t, i+1, entry.Version)
+			assert.Equal(t, i+1, entry.Item.Version)
+			assert.Equal(t, person.ID, entry.Item.ID)
+			assert.Equal(t, wantChanges[i], entry.Change)
+			assert.Equal(t, wantPrincipals[i], entry.Principal)
+			assert.True(t, entry.Timestamp.After(start), "entry %d has timestamp %v", i, entry.Timestamp)
+		}
+
+		assert.Equal(t, "Some", entries[0].Item.FirstName)
+		assert.Len(t, entries[0].Diff, 3) // first name, last name and date of birth are set
+		assert.Equal(t, []db.FieldChange{{Field: db.PersonFirstName, Old: "Some", New: "Another"}}, entries[1].Diff)
+		assert.Equal(t, []db.FieldChange{{Field: db.PersonRemoved, Old: false, New: true}}, entries[2].Diff)
+		assert.Equal(t, []db.FieldChange{{Field: db.PersonRemoved, Old: true, New: false}}, entries[3].Diff)
+
+		entry, err := database.PersonHistory().Get(ctx, person.ID, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "Another", entry.Item.FirstName)
+		assert.Equal(t, entries[1].Diff, entry.Diff)
+
+		_, err = database.PersonHistory().Get(ctx, person.ID, 5)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		ctx := context.Background()
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := database.Person().Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+
+		errMock := errors.New("mock error")
+		err := database.WithTx(ctx, func(tx db.Database) error {
+			person.FirstName = "Another"
+			if err := tx.Person().Update(ctx, person); err != nil {
+				return err
+			}
+
+			// The change is part of the history within the transaction
+			entries, err := tx.PersonHistory().List(ctx, person.ID)
+			if err != nil {
+				return err
+			}
+			assert.Len(t, entries, 2)
+			return errMock
+		})
+		assert.ErrorIs(t, err, errMock)
+
+		entries, err := database.PersonHistory().List(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		ctx := context.Background()
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := database.Person().Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+		_, err := database.Person().Remove(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, database.Person().Purge(ctx, person.ID))
+
+		_, err = database.PersonHistory().List(ctx, person.ID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	})
+
+	t.Run("NoResults", func(t *testing.T) {
+		_, err := database.PersonHistory().List(context.Background(), uuid.New())
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+
+		_, err = database.PersonHistory().Get(context.Background(), uuid.New(), 1)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	})
+}
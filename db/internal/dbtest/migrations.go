@@ -0,0 +1,61 @@
+package dbtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db/migrations"
+)
+
+// RunMigrations checks that every migration of migrator can be rolled back, and that rolling it back reverses its
+// changes to the schema, which is described by snapshot. The database is left migrated to the latest version.
+func RunMigrations(t *testing.T, migrator *migrations.Migrator, snapshot func(ctx context.Context) ([]string, error)) {
+	ctx := context.Background()
+
+	takeSnapshot := func() []string {
+		t.Helper()
+		schema, err := snapshot(ctx)
+		if err != nil {
+			t.Fatalf("failed to take a snapshot of the schema: %v", err)
+		}
+		return schema
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("failed to get the migration status: %v", err)
+	}
+	if _, err := migrator.Down(ctx, len(status)); err != nil {
+		t.Fatalf("failed to roll back every migration: %v", err)
+	}
+	assert.ErrorIs(t, migrator.Check(ctx), migrations.ErrPendingMigrations)
+
+	for _, s := range status {
+		before := takeSnapshot()
+
+		applied, err := migrator.UpTo(ctx, s.Version)
+		if err != nil {
+			t.Fatalf("failed to apply migration %d_%s: %v", s.Version, s.Name, err)
+		}
+		assert.Equal(t, []migrations.Migration{s.Migration}, applied)
+		after := takeSnapshot()
+		assert.NotEqual(t, before, after, "migration %d_%s didn't change the schema", s.Version, s.Name)
+
+		rolledBack, err := migrator.Down(ctx, 1)
+		if err != nil {
+			t.Fatalf("failed to roll back migration %d_%s: %v", s.Version, s.Name, err)
+		}
+		assert.Equal(t, []migrations.Migration{s.Migration}, rolledBack)
+		assert.Equal(t, before, takeSnapshot(), "rolling back migration %d_%s didn't restore the schema", s.Version, s.Name)
+
+		if _, err := migrator.UpTo(ctx, s.Version); err != nil {
+			t.Fatalf("failed to reapply migration %d_%s: %v", s.Version, s.Name, err)
+		}
+	}
+
+	assert.NoError(t, migrator.Check(ctx))
+	applied, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, applied, "migrating an up to date database must not apply anything")
+}
@@ -0,0 +1,402 @@
+package dbtest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// RunPersonDatastore checks that the person datastore of the given database follows the db.Datastore contract.
+func RunPersonDatastore(t *testing.T, database db.Database) {
+	t.Run("InsertAndGet", func(t *testing.T) {
+		ctx := context.Background()
+		store := database.Person()
+
+		person := &db.Person{
+			FirstName:   "Testy",
+			LastName:    "McTesterson",
+			DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+		assert.NotEqual(t, uuid.Nil, person.ID)
+
+		got, err := store.Get(ctx, person.ID)
+		if err != nil {
+			t.Fatalf("failed to get person: %v", err)
+		}
+		assertPerson(t, person, got)
+	})
+
+	t.Run("UpdateAndRemove", func(t *testing.T) {
+		ctx := context.Background()
+		store := database.Person()
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+
+		person.FirstName = "Another"
+		person.DateOfBirth = time.Date(1992, 1, 27, 0, 0, 0, 0, time.UTC)
+		assert.NoError(t, store.Update(ctx, person))
+
+		removed, err := store.Remove(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Another", removed.FirstName)
+		assert.True(t, *removed.Removed)
+
+		// Remove is a soft delete, so the person must still be retrievable
+		got, err := store.Get(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.True(t, *got.Removed)
+		assert.True(t, person.DateOfBirth.Equal(got.DateOfBirth))
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		ctx := context.Background()
+		store := database.Person()
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+		assert.Equal(t, 1, person.Version)
+
+		assert.NoError(t, store.Update(ctx, person))
+		assert.Equal(t, 2, person.Version)
+
+		stale := *person
+		stale.Version = 1
+		assert.ErrorIs(t, store.Update(ctx, &stale), db.ErrVersionConflict)
+
+		// A zero version skips the version check
+		unchecked := *person
+		unchecked.Version = 0
+		assert.NoError(t, store.Update(ctx, &unchecked))
+		assert.Equal(t, 3, unchecked.Version)
+
+		removed, err := store.Remove(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, removed.Version)
+
+		got, err := store.Get(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, got.Version)
+	})
+
+	t.Run("RestoreAndPurge", func(t *testing.T) {
+		ctx := context.Background()
+		store := database.Person()
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+
+		_, err := store.Restore(ctx, person.ID)
+		assert.ErrorIs(t, err, db.ErrNotRemoved)
+		assert.ErrorIs(t, store.Purge(ctx, person.ID), db.ErrNotRemoved)
+
+		_, err = store.Remove(ctx, person.ID)
+		assert.NoError(t, err)
+		_, err = store.Remove(ctx, person.ID)
+		assert.ErrorIs(t, err, db.ErrAlreadyRemoved)
+
+		restored, err := store.Restore(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.False(t, *restored.Removed)
+		assert.Equal(t, 3, restored.Version)
+
+		_, err = store.Remove(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Purge(ctx, person.ID))
+
+		_, err = store.Get(ctx, person.ID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	})
+
+	t.Run("UpdateKeepsRemoved", func(t *testing.T) {
+		ctx := context.Background()
+		store := database.Person()
+
+		person := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+		removed, err := store.Remove(ctx, person.ID)
+		if err != nil {
+			t.Fatalf("failed to remove person: %v", err)
+		}
+
+		// Only Restore brings a removed person back, whatever the updated item says
+		updated := *removed
+		updated.FirstName = "Another"
+		updated.Removed = db.NewBool(false)
+		assert.NoError(t, store.Update(ctx, &updated))
+		assert.True(t, *updated.Removed)
+
+		got, err := store.Get(ctx, person.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Another", got.FirstName)
+		assert.True(t, *got.Removed)
+		if assert.NotNil(t, got.RemovedAt) {
+			assertTime(t, "removedAt", *removed.RemovedAt, *got.RemovedAt)
+		}
+
+		// Updating a person that isn't removed doesn't remove it either
+		restored, err := store.Restore(ctx, person.ID)
+		if err != nil {
+			t.Fatalf("failed to restore person: %v", err)
+		}
+		restored.Removed = db.NewBool(true)
+		assert.NoError(t, store.Update(ctx, restored))
+		assert.False(t, *restored.Removed)
+	})
+
+	t.Run("NoResults", func(t *testing.T) {
+		ctx := context.Background()
+		store := database.Person()
+		dneUUID := uuid.New()
+
+		_, err := store.Get(ctx, dneUUID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+
+		_, err = store.Remove(ctx, dneUUID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+
+		err = store.Update(ctx, &db.Person{ID: dneUUID})
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+
+		_, err = store.Restore(ctx, dneUUID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+
+		err = store.Purge(ctx, dneUUID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := database.Person().Get(ctx, uuid.New())
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// assertPerson compares two people, ignoring the location of their timestamps
+// since not every database keeps track of it.
+func assertPerson(t *testing.T, want, got *db.Person) {
+	t.Helper()
+
+	assertTime(t, "DateOfBirth", want.DateOfBirth, got.DateOfBirth)
+	assertTimestamps(t, want.Timestamps, got.Timestamps)
+
+	wantCopy, gotCopy := *want, *got
+	wantCopy.DateOfBirth, gotCopy.DateOfBirth = time.Time{}, time.Time{}
+	wantCopy.Timestamps, gotCopy.Timestamps = db.Timestamps{}, db.Timestamps{}
+	assert.Equal(t, wantCopy, gotCopy)
+}
+
+func assertTime(t *testing.T, name string, want, got time.Time) {
+	t.Helper()
+	assert.True(t, want.Equal(got), "%s: want %v, got %v", name, want, got)
+}
+
+// RunPersonQuery checks that the person datastore of the given database filters, sorts and pages query results.
+func RunPersonQuery(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	store := database.Person()
+
+	// Use a last name that is unique to this test run, so that data left behind by other tests doesn't get in the way
+	lastName := "Query-" + uuid.NewString()
+	people := []*db.Person{
+		{FirstName: "Alice", LastName: lastName, DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{FirstName: "Bob", LastName: lastName, DateOfBirth: time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{FirstName: "Carol", LastName: lastName, DateOfBirth: time.Date(2000, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{FirstName: "Dave", LastName: lastName, DateOfBirth: time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{FirstName: "Eve", LastName: lastName, DateOfBirth: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, person := range people {
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+	}
+	if _, err := store.Remove(ctx, people[4].ID); err != nil {
+		t.Fatalf("failed to remove person: %v", err)
+	}
+
+	sameLastName := db.Filter{Field: db.PersonLastName, Operator: db.Equal, Value: lastName}
+	firstNames := func(page *db.Page[db.Person]) []string {
+		var names []string
+		for _, person := range page.Items {
+			names = append(names, person.FirstName)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name      string
+		query     db.Query
+		wantNames []string
+		wantTotal int
+		wantErr   error
+	}{
+		{
+			name: "Filter and Sort",
+			query: db.Query{
+				Filters: []db.Filter{sameLastName},
+				Sort:    []db.Sort{{Field: db.PersonFirstName}},
+			},
+			wantNames: []string{"Alice", "Bob", "Carol", "Dave"},
+			wantTotal: 4,
+		},
+		{
+			name: "Include Removed",
+			query: db.Query{
+				Filters:        []db.Filter{sameLastName},
+				Sort:           []db.Sort{{Field: db.PersonFirstName, Descending: true}},
+				IncludeRemoved: true,
+			},
+			wantNames: []string{"Eve", "Dave", "Carol", "Bob", "Alice"},
+			wantTotal: 5,
+		},
+		{
+			name: "Date Range",
+			query: db.Query{
+				Filters: []db.Filter{
+					sameLastName,
+					{Field: db.PersonDateOfBirth, Operator: db.GreaterOrEqual, Value: time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC)},
+					{Field: db.PersonDateOfBirth, Operator: db.LessThan, Value: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+				},
+				Sort: []db.Sort{{Field: db.PersonDateOfBirth, Descending: true}, {Field: db.PersonFirstName}},
+			},
+			wantNames: []string{"Alice", "Bob", "Dave"},
+			wantTotal: 3,
+		},
+		{
+			name: "Limit and Offset",
+			query: db.Query{
+				Filters: []db.Filter{sameLastName},
+				Sort:    []db.Sort{{Field: db.PersonFirstName}},
+				Limit:   2,
+				Offset:  1,
+			},
+			wantNames: []string{"Bob", "Carol"},
+			wantTotal: 4,
+		},
+		{
+			name: "Offset Only",
+			query: db.Query{
+				Filters: []db.Filter{sameLastName},
+				Sort:    []db.Sort{{Field: db.PersonFirstName}},
+				Offset:  3,
+			},
+			wantNames: []string{"Dave"},
+			wantTotal: 4,
+		},
+		{
+			name: "After Position",
+			query: db.Query{
+				Filters: []db.Filter{sameLastName},
+				Sort:    []db.Sort{{Field: db.PersonFirstName}},
+				After:   []any{"Bob", people[1].ID},
+			},
+			wantNames: []string{"Carol", "Dave"},
+			wantTotal: 4,
+		},
+		{
+			name: "After Position with Ties",
+			query: db.Query{
+				Filters:        []db.Filter{sameLastName},
+				Sort:           []db.Sort{{Field: db.PersonDateOfBirth, Descending: true}},
+				After:          []any{time.Date(1985, 6, 15, 0, 0, 0, 0, time.UTC), uuid.Max},
+				IncludeRemoved: true,
+				Limit:          10,
+			},
+			wantNames: []string{"Eve"},
+			wantTotal: 5,
+		},
+		{
+			name: "Unknown Field",
+			query: db.Query{
+				Filters: []db.Filter{{Field: "favoriteColor", Operator: db.Equal, Value: "blue"}},
+			},
+			wantErr: db.ErrInvalidQuery,
+		},
+		{
+			name: "Wrong Value Type",
+			query: db.Query{
+				Filters: []db.Filter{{Field: db.PersonDateOfBirth, Operator: db.Equal, Value: "1970-01-01"}},
+			},
+			wantErr: db.ErrInvalidQuery,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.Query(ctx, tt.query)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to query people: %v", err)
+			}
+
+			assert.Equal(t, tt.wantNames, firstNames(page))
+			assert.Equal(t, tt.wantTotal, page.Total)
+		})
+	}
+
+	// Every person ties on the last name, so the pages are ordered by key alone
+	t.Run("Backward Position with Ties", func(t *testing.T) {
+		query := db.Query{
+			Filters: []db.Filter{sameLastName},
+			Sort:    []db.Sort{{Field: db.PersonLastName}},
+			Limit:   2,
+		}
+		first, err := store.Query(ctx, query)
+		if err != nil {
+			t.Fatalf("failed to query people: %v", err)
+		}
+
+		last := first.Items[len(first.Items)-1]
+		query.After = []any{lastName, last.ID}
+		second, err := store.Query(ctx, query)
+		if err != nil {
+			t.Fatalf("failed to query people: %v", err)
+		}
+
+		query.After = []any{lastName, second.Items[0].ID}
+		query.Backward = true
+		back, err := store.Query(ctx, query)
+		if err != nil {
+			t.Fatalf("failed to query people: %v", err)
+		}
+
+		want := firstNames(first)
+		slices.Reverse(want)
+		assert.Equal(t, want, firstNames(back))
+		assert.Equal(t, 4, back.Total)
+	})
+
+	t.Run("Backward Position", func(t *testing.T) {
+		page, err := store.Query(ctx, db.Query{
+			Filters:  []db.Filter{sameLastName},
+			Sort:     []db.Sort{{Field: db.PersonFirstName, Descending: true}},
+			After:    []any{"Bob", people[1].ID},
+			Backward: true,
+		})
+		if err != nil {
+			t.Fatalf("failed to query people: %v", err)
+		}
+
+		assert.Equal(t, []string{"Carol", "Dave"}, firstNames(page))
+	})
+}
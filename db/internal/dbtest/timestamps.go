@@ -0,0 +1,88 @@
+package dbtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// fakeClock is a db.Clock that only moves when it is told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	return f.now
+}
+
+// RunPersonTimestamps checks that the person datastore of the database returned by open keeps the timestamps of
+// people up to date, using the clock passed to open. Timestamps set by the caller must be ignored.
+func RunPersonTimestamps(t *testing.T, open func(clock db.Clock) db.Database) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := open(clock.Now).Person()
+
+	created := clock.Now()
+	bogus := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
+	person := &db.Person{
+		FirstName:  "Some",
+		LastName:   "Tester",
+		Timestamps: db.Timestamps{CreatedAt: bogus, UpdatedAt: bogus, RemovedAt: &bogus},
+	}
+	if err := store.Insert(ctx, person); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+	assertTimestamps(t, db.Timestamps{CreatedAt: created, UpdatedAt: created}, person.Timestamps)
+
+	updated := clock.Advance(time.Minute)
+	person.FirstName = "Another"
+	person.CreatedAt = bogus
+	assert.NoError(t, store.Update(ctx, person))
+	assertTimestamps(t, db.Timestamps{CreatedAt: created, UpdatedAt: updated}, person.Timestamps)
+
+	removed := clock.Advance(time.Minute)
+	got, err := store.Remove(ctx, person.ID)
+	assert.NoError(t, err)
+	assertTimestamps(t, db.Timestamps{CreatedAt: created, UpdatedAt: removed, RemovedAt: &removed}, got.Timestamps)
+
+	// Updating a removed person keeps the time it was removed at
+	updated = clock.Advance(time.Minute)
+	got.LastName = "Changed"
+	assert.NoError(t, store.Update(ctx, got))
+	assertTimestamps(t, db.Timestamps{CreatedAt: created, UpdatedAt: updated, RemovedAt: &removed}, got.Timestamps)
+
+	restored := clock.Advance(time.Minute)
+	got, err = store.Restore(ctx, person.ID)
+	assert.NoError(t, err)
+	assertTimestamps(t, db.Timestamps{CreatedAt: created, UpdatedAt: restored}, got.Timestamps)
+
+	got, err = store.Get(ctx, person.ID)
+	assert.NoError(t, err)
+	assertTimestamps(t, db.Timestamps{CreatedAt: created, UpdatedAt: restored}, got.Timestamps)
+}
+
+func assertTimestamps(t *testing.T, want, got db.Timestamps) {
+	t.Helper()
+
+	assertTime(t, "CreatedAt", want.CreatedAt, got.CreatedAt)
+	assertTime(t, "UpdatedAt", want.UpdatedAt, got.UpdatedAt)
+	if want.RemovedAt == nil || got.RemovedAt == nil {
+		assert.Equal(t, want.RemovedAt, got.RemovedAt, "RemovedAt")
+	} else {
+		assertTime(t, "RemovedAt", *want.RemovedAt, *got.RemovedAt)
+	}
+}
@@ -0,0 +1,107 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// RunTx checks that the changes made in a transaction of the given database are committed or rolled back together.
+func RunTx(t *testing.T, database db.Database) {
+	ctx := context.Background()
+	errMock := errors.New("mock error")
+
+	t.Run("Commit", func(t *testing.T) {
+		existing := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := database.Person().Insert(ctx, existing); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+
+		inserted := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+		err := database.WithTx(ctx, func(tx db.Database) error {
+			if err := tx.Person().Insert(ctx, inserted); err != nil {
+				return err
+			}
+			existing.FirstName = "Another"
+			return tx.Person().Update(ctx, existing)
+		})
+		assert.NoError(t, err)
+
+		_, err = database.Person().Get(ctx, inserted.ID)
+		assert.NoError(t, err)
+		got, err := database.Person().Get(ctx, existing.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Another", got.FirstName)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		existing := &db.Person{FirstName: "Some", LastName: "Tester"}
+		if err := database.Person().Insert(ctx, existing); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+
+		inserted := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+		err := database.WithTx(ctx, func(tx db.Database) error {
+			if err := tx.Person().Insert(ctx, inserted); err != nil {
+				return err
+			}
+			if _, err := tx.Person().Remove(ctx, existing.ID); err != nil {
+				return err
+			}
+			return errMock
+		})
+		assert.ErrorIs(t, err, errMock)
+
+		_, err = database.Person().Get(ctx, inserted.ID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+		got, err := database.Person().Get(ctx, existing.ID)
+		assert.NoError(t, err)
+		assert.False(t, *got.Removed)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		inserted := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+		assert.Panics(t, func() {
+			database.WithTx(ctx, func(tx db.Database) error {
+				if err := tx.Person().Insert(ctx, inserted); err != nil {
+					return err
+				}
+				panic("mock panic")
+			})
+		})
+
+		_, err := database.Person().Get(ctx, inserted.ID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		outer := &db.Person{FirstName: "Outer", LastName: "Tester"}
+		inner := &db.Person{FirstName: "Inner", LastName: "Tester"}
+		err := database.WithTx(ctx, func(tx db.Database) error {
+			if err := tx.Person().Insert(ctx, outer); err != nil {
+				return err
+			}
+			err := tx.WithTx(ctx, func(nested db.Database) error {
+				// The nested transaction must see the changes of the outer one
+				if _, err := nested.Person().Get(ctx, outer.ID); err != nil {
+					return err
+				}
+				return nested.Person().Insert(ctx, inner)
+			})
+			if err != nil {
+				return err
+			}
+			return errMock
+		})
+		assert.ErrorIs(t, err, errMock)
+
+		// Rolling back the outer transaction must also roll back the nested one
+		_, err = database.Person().Get(ctx, outer.ID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+		_, err = database.Person().Get(ctx, inner.ID)
+		assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	})
+}
@@ -0,0 +1,183 @@
+package feed
+
+import (
+	"context"
+	"sync"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// DefaultRetention is the number of recent events a Broker keeps unless Options.Retention says otherwise
+const DefaultRetention = 1024
+
+// ReplayFunc returns the events whose sequence lies between from and to, inclusive, in order
+type ReplayFunc[T db.Entity, U db.Identifier] func(ctx context.Context, from, to uint64) ([]db.Event[T, U], error)
+
+// Options configures a Broker
+type Options[T db.Entity, U db.Identifier] struct {
+	// Retention is the number of recent events that are kept for subscribers that resume from an earlier sequence.
+	Retention int
+	// Replay, if set, returns the events that are too old to be retained, for implementations that persist them.
+	// Without it, resuming from such an event fails with db.ErrSequenceExpired.
+	Replay ReplayFunc[T, U]
+	// OnIdle, if set, is called when the last subscriber leaves, for implementations that stop following their
+	// changes while nobody watches. It is called with the broker locked, so it must not block or use the broker.
+	OnIdle func()
+}
+
+// Broker fans the events published by a db.ChangeFeed out to its subscribers. It implements db.ChangeFeed.
+type Broker[T db.Entity, U db.Identifier] struct {
+	mu          sync.Mutex
+	last        uint64
+	recent      []db.Event[T, U] // The most recent events, oldest first
+	retention   int
+	replay      ReplayFunc[T, U]
+	onIdle      func()
+	subscribers map[*subscription[T, U]]struct{}
+	closed      bool
+}
+
+// NewBroker creates a Broker that has not published any events yet.
+func NewBroker[T db.Entity, U db.Identifier](opts Options[T, U]) *Broker[T, U] {
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+
+	return &Broker[T, U]{
+		retention:   opts.Retention,
+		replay:      opts.Replay,
+		onIdle:      opts.OnIdle,
+		subscribers: make(map[*subscription[T, U]]struct{}),
+	}
+}
+
+// Last returns the sequence of the latest event that was published, or set with SetLast.
+func (b *Broker[T, U]) Last() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.last
+}
+
+// SetLast sets the sequence of the latest event without publishing it, for implementations that continue
+// a feed whose earlier events were published by another Broker, such as before a restart.
+func (b *Broker[T, U]) SetLast(sequence uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if sequence > b.last {
+		b.last = sequence
+		b.recent = nil
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broker[T, U]) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
+
+// Publish delivers the given events, which must be in order, to every subscriber.
+// Events whose sequence is not greater than the last published one are ignored, so that implementations
+// that read their events from a database don't have to worry about reading one twice.
+func (b *Broker[T, U]) Publish(events ...db.Event[T, U]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		if event.Sequence <= b.last || b.closed {
+			continue
+		}
+		b.last = event.Sequence
+
+		b.recent = append(b.recent, event)
+		if len(b.recent) > 2*b.retention { // Only copy once in a while, rather than on every event
+			b.recent = append([]db.Event[T, U](nil), b.recent[len(b.recent)-b.retention:]...)
+		}
+
+		for s := range b.subscribers {
+			if !s.offer(event) {
+				delete(b.subscribers, s)
+			}
+		}
+	}
+}
+
+// Disconnect ends every subscription with err, for example because the events can't be read right now.
+// Unlike Close, new subscriptions can still be made.
+func (b *Broker[T, U]) Disconnect(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		s.end(err)
+		delete(b.subscribers, s)
+	}
+}
+
+// Close ends every subscription with db.ErrFeedClosed. Watch fails with the same error from then on.
+func (b *Broker[T, U]) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		s.end(db.ErrFeedClosed)
+		delete(b.subscribers, s)
+	}
+}
+
+// Watch implements db.ChangeFeed.
+func (b *Broker[T, U]) Watch(ctx context.Context, opts db.WatchOptions) (db.Subscription[T, U], error) {
+	if err := db.ContextError(ctx); err != nil {
+		return nil, err
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = db.DefaultWatchBuffer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, db.ErrFeedClosed
+	}
+
+	s := newSubscription[T, U](b, opts)
+	if opts.From > 0 && opts.From <= b.last {
+		oldest := b.last + 1
+		if len(b.recent) > 0 {
+			oldest = b.recent[max(0, len(b.recent)-b.retention)].Sequence
+		}
+		if opts.From < oldest {
+			if b.replay == nil {
+				return nil, db.ErrSequenceExpired
+			}
+			s.replayFrom, s.replayTo = opts.From, oldest-1
+		}
+
+		for _, event := range b.recent {
+			if event.Sequence >= opts.From && event.Sequence >= oldest {
+				s.queue = append(s.queue, event)
+			}
+		}
+	}
+
+	b.subscribers[s] = struct{}{}
+	go s.run(ctx)
+
+	return s, nil
+}
+
+// unsubscribe stops publishing events to s
+func (b *Broker[T, U]) unsubscribe(s *subscription[T, U]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, s)
+	if len(b.subscribers) == 0 && b.onIdle != nil {
+		b.onIdle()
+	}
+}
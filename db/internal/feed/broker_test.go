@@ -0,0 +1,152 @@
+package feed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// events returns events with the sequences from first to last
+func events(first, last uint64) []db.Event[db.Person, uuid.UUID] {
+	var events []db.Event[db.Person, uuid.UUID]
+	for seq := first; seq <= last; seq++ {
+		events = append(events, db.Event[db.Person, uuid.UUID]{Sequence: seq, Change: db.ChangeInsert, ID: uuid.New()})
+	}
+	return events
+}
+
+// receive waits for the next n events of sub
+func receive(t *testing.T, sub db.Subscription[db.Person, uuid.UUID], n int) []db.Event[db.Person, uuid.UUID] {
+	t.Helper()
+
+	var received []db.Event[db.Person, uuid.UUID]
+	for len(received) < n {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				t.Fatalf("subscription ended after %d of %d events: %v", len(received), n, sub.Err())
+			}
+			received = append(received, event)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d of %d events in time", len(received), n)
+		}
+	}
+	return received
+}
+
+func TestBroker_Retention(t *testing.T) {
+	ctx := context.Background()
+	broker := NewBroker(Options[db.Person, uuid.UUID]{Retention: 3})
+	published := events(1, 10)
+	broker.Publish(published...)
+	broker.Publish(published[4]) // Already published, so ignored
+
+	_, err := broker.Watch(ctx, db.WatchOptions{From: 7})
+	assert.ErrorIs(t, err, db.ErrSequenceExpired)
+
+	sub, err := broker.Watch(ctx, db.WatchOptions{From: 8})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer sub.Close()
+	assert.Equal(t, published[7:], receive(t, sub, 3))
+
+	more := events(11, 11)
+	broker.Publish(more...)
+	assert.Equal(t, more, receive(t, sub, 1))
+}
+
+func TestBroker_Replay(t *testing.T) {
+	ctx := context.Background()
+	published := events(1, 10)
+	var replayed [2]uint64
+	broker := NewBroker(Options[db.Person, uuid.UUID]{
+		Retention: 3,
+		Replay: func(ctx context.Context, from, to uint64) ([]db.Event[db.Person, uuid.UUID], error) {
+			replayed = [2]uint64{from, to}
+			return published[from-1 : to], nil
+		},
+	})
+	broker.Publish(published...)
+
+	sub, err := broker.Watch(ctx, db.WatchOptions{From: 2})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer sub.Close()
+	assert.Equal(t, published[1:], receive(t, sub, 9))
+	assert.Equal(t, [2]uint64{2, 7}, replayed)
+}
+
+func TestBroker_SetLast(t *testing.T) {
+	ctx := context.Background()
+	broker := NewBroker(Options[db.Person, uuid.UUID]{})
+	broker.Publish(events(1, 3)...)
+	broker.SetLast(5)
+	assert.Equal(t, uint64(5), broker.Last())
+
+	// The events before the new last one are no longer known
+	_, err := broker.Watch(ctx, db.WatchOptions{From: 3})
+	assert.ErrorIs(t, err, db.ErrSequenceExpired)
+
+	sub, err := broker.Watch(ctx, db.WatchOptions{From: 6})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	defer sub.Close()
+	broker.Publish(events(5, 6)...)
+	assert.Equal(t, uint64(6), receive(t, sub, 1)[0].Sequence)
+}
+
+func TestBroker_Disconnect(t *testing.T) {
+	ctx := context.Background()
+	broker := NewBroker(Options[db.Person, uuid.UUID]{})
+	sub, err := broker.Watch(ctx, db.WatchOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+
+	broker.Disconnect(db.ErrUnavailable)
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.ErrorIs(t, sub.Err(), db.ErrUnavailable)
+	assert.Equal(t, 0, broker.Subscribers())
+
+	// Unlike a closed broker, a disconnected one can be watched again
+	sub, err = broker.Watch(ctx, db.WatchOptions{})
+	assert.NoError(t, err)
+	sub.Close()
+
+	broker.Close()
+	_, err = broker.Watch(ctx, db.WatchOptions{})
+	assert.ErrorIs(t, err, db.ErrFeedClosed)
+}
+
+func TestBroker_OnIdle(t *testing.T) {
+	ctx := context.Background()
+	idle := make(chan struct{}, 2)
+	broker := NewBroker(Options[db.Person, uuid.UUID]{OnIdle: func() { idle <- struct{}{} }})
+
+	first, err := broker.Watch(ctx, db.WatchOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+	second, err := broker.Watch(ctx, db.WatchOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch: %v", err)
+	}
+
+	first.Close()
+	second.Close()
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Fatal("OnIdle was not called once the last subscriber left")
+	}
+	assert.Equal(t, 0, broker.Subscribers())
+	assert.Empty(t, idle, "OnIdle must only be called once the last subscriber left")
+}
@@ -0,0 +1,6 @@
+// feed holds the Broker that the implementations of db.ChangeFeed use to fan events out to their subscribers.
+//
+// The implementations decide which events there are and assign their sequences, and publish them to a Broker,
+// which keeps the most recent ones so that subscribers can resume. Implementations that persist their events
+// can replay older ones as well.
+package feed
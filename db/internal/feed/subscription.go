@@ -0,0 +1,168 @@
+package feed
+
+import (
+	"context"
+	"sync"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// subscription implements db.Subscription. The broker queues events for it, and a goroutine delivers them from
+// the queue, so that a slow subscriber never holds up the broker.
+type subscription[T db.Entity, U db.Identifier] struct {
+	broker *Broker[T, U]
+	events chan db.Event[T, U]
+	opts   db.WatchOptions
+	// replayFrom and replayTo are the sequences of the events that have to be replayed
+	// before the queued ones, or zero if there is nothing to replay
+	replayFrom, replayTo uint64
+
+	mu     sync.Mutex
+	queue  []db.Event[T, U]
+	ended  bool  // Whether the queue receives no more events
+	err    error // Why the subscription ended
+	wake   chan struct{}
+	closed chan struct{}
+	close  sync.Once
+}
+
+func newSubscription[T db.Entity, U db.Identifier](broker *Broker[T, U], opts db.WatchOptions) *subscription[T, U] {
+	return &subscription[T, U]{
+		broker: broker,
+		events: make(chan db.Event[T, U]),
+		opts:   opts,
+		wake:   make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+// Events implements db.Subscription.
+func (s *subscription[T, U]) Events() <-chan db.Event[T, U] {
+	return s.events
+}
+
+// Err implements db.Subscription.
+func (s *subscription[T, U]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Close implements db.Subscription.
+func (s *subscription[T, U]) Close() {
+	s.close.Do(func() {
+		close(s.closed)
+	})
+}
+
+// offer queues an event, applying the overflow policy if the queue is full.
+// It returns false if the subscription has ended, and the broker should forget about it.
+func (s *subscription[T, U]) offer(event db.Event[T, U]) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return false
+	}
+	if event.Sequence < s.opts.From {
+		return true
+	}
+
+	if len(s.queue) >= s.opts.Buffer {
+		if s.opts.Overflow != db.OverflowDropOldest {
+			s.endLocked(db.ErrSubscriberOverflow)
+			return false
+		}
+		s.queue = s.queue[1:]
+	}
+	s.queue = append(s.queue, event)
+	s.signal()
+
+	return true
+}
+
+// end stops queueing events, and ends the subscription with err once the queued events are delivered
+func (s *subscription[T, U]) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.endLocked(err)
+}
+
+func (s *subscription[T, U]) endLocked(err error) {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	s.err = err
+	s.signal()
+}
+
+func (s *subscription[T, U]) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the replayed and queued events until the subscription ends
+func (s *subscription[T, U]) run(ctx context.Context) {
+	defer close(s.events)
+	defer s.broker.unsubscribe(s)
+
+	if s.replayTo > 0 {
+		events, err := s.broker.replay(ctx, s.replayFrom, s.replayTo)
+		if err != nil {
+			s.end(err)
+			return
+		}
+		for _, event := range events {
+			if !s.deliver(ctx, event) {
+				return
+			}
+		}
+	}
+
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			event := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+
+			if !s.deliver(ctx, event) {
+				return
+			}
+			continue
+		}
+		ended := s.ended
+		s.mu.Unlock()
+
+		if ended {
+			return
+		}
+
+		select {
+		case <-s.wake:
+		case <-s.closed:
+			return
+		case <-ctx.Done():
+			s.end(db.ContextError(ctx))
+			return
+		}
+	}
+}
+
+// deliver sends event to the subscriber, and returns false if the subscription ended while it was waiting
+func (s *subscription[T, U]) deliver(ctx context.Context, event db.Event[T, U]) bool {
+	select {
+	case s.events <- event:
+		return true
+	case <-s.closed:
+		return false
+	case <-ctx.Done():
+		s.end(db.ContextError(ctx))
+		return false
+	}
+}
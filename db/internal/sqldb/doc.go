@@ -0,0 +1,5 @@
+// sqldb holds the database/sql logic that is shared between the SQL implementations of db.Database.
+//
+// The SQL written here sticks to the subset that is understood by every supported database.
+// Anything that differs between databases is described by a Dialect.
+package sqldb
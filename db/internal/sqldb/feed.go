@@ -0,0 +1,246 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/feed"
+)
+
+const personEventColumns = "sequence, person_id, kind, principal, changed_at, before, after"
+
+const (
+	// feedPollInterval is how often the events table is read while there are subscribers,
+	// to pick up the changes made by other processes. Changes made by this process are picked up right away.
+	feedPollInterval = time.Second
+	// feedPollLimit is the maximum number of events read from the events table at once
+	feedPollLimit = 1000
+)
+
+// personFeed implements db.ChangeFeed. Every change to a person is recorded in the person_events table, in the same
+// transaction as the change itself, and a single goroutine per process reads the new events from the table and
+// publishes them to the subscribers. It only runs while there are subscribers.
+type personFeed struct {
+	pool    *sql.DB
+	dialect Dialect
+	broker  *feed.Broker[db.Person, uuid.UUID]
+	wake    chan struct{}
+
+	mu      sync.Mutex
+	polling bool
+	idle    chan struct{} // Closed while the poller isn't running
+}
+
+func newPersonFeed(pool *sql.DB, dialect Dialect) *personFeed {
+	f := &personFeed{
+		pool:    pool,
+		dialect: dialect,
+		wake:    make(chan struct{}, 1),
+		idle:    make(chan struct{}),
+	}
+	close(f.idle)
+	f.broker = feed.NewBroker(feed.Options[db.Person, uuid.UUID]{
+		Replay: func(ctx context.Context, from, to uint64) ([]db.Event[db.Person, uuid.UUID], error) {
+			return f.events(ctx, from, to, 0)
+		},
+		// Let the poller notice right away that it can stop
+		OnIdle: f.notify,
+	})
+
+	return f
+}
+
+// Watch implements db.ChangeFeed.
+func (f *personFeed) Watch(ctx context.Context, opts db.WatchOptions) (db.Subscription[db.Person, uuid.UUID], error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.polling {
+		// Events that were recorded while nobody watched the feed are only delivered to subscribers that ask for them,
+		// so the poller starts after the latest one rather than where it left off
+		var last int64
+		err := f.pool.QueryRowContext(ctx, `SELECT COALESCE(MAX(sequence), 0) FROM person_events`).Scan(&last)
+		if err != nil {
+			return nil, fmt.Errorf("failed to watch people: %w", f.dialect.classify(err))
+		}
+		f.broker.SetLast(uint64(last))
+	}
+
+	subscription, err := f.broker.Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !f.polling {
+		f.polling = true
+		f.idle = make(chan struct{})
+		go f.poll()
+	}
+
+	return subscription, nil
+}
+
+// Idle returns a channel that is closed once nobody watches the feed and it stopped reading the events table.
+// Changes made from then on are only delivered to subscribers that resume from an earlier sequence.
+func (f *personFeed) Idle() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.idle
+}
+
+// notify tells the poller that new events were recorded
+func (f *personFeed) notify() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// poll publishes the new events in the events table until there are no subscribers left.
+// If the table can't be read, every subscription ends with the error, and the subscribers can resume later.
+func (f *personFeed) poll() {
+	ticker := time.NewTicker(feedPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			events, err := f.events(context.Background(), f.broker.Last()+1, math.MaxInt64, feedPollLimit)
+			if err != nil {
+				f.broker.Disconnect(fmt.Errorf("failed to read person events: %w", f.dialect.classify(err)))
+				break
+			}
+			f.broker.Publish(events...)
+			if len(events) < feedPollLimit {
+				break
+			}
+		}
+
+		f.mu.Lock()
+		if f.broker.Subscribers() == 0 {
+			f.polling = false
+			close(f.idle)
+			f.mu.Unlock()
+			return
+		}
+		f.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-f.wake:
+		}
+	}
+}
+
+// events reads the events whose sequence lies between from and to, inclusive, in order.
+// A limit of zero reads all of them.
+func (f *personFeed) events(ctx context.Context, from, to uint64, limit int) ([]db.Event[db.Person, uuid.UUID], error) {
+	limitClause := f.dialect.NoLimit
+	if limit > 0 {
+		limitClause = fmt.Sprint(limit)
+	}
+
+	rows, err := f.pool.QueryContext(ctx, f.dialect.Rebind(
+		`SELECT `+personEventColumns+` FROM person_events WHERE sequence BETWEEN ? AND ? ORDER BY sequence LIMIT `+limitClause),
+		int64(min(from, math.MaxInt64)), int64(min(to, math.MaxInt64)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []db.Event[db.Person, uuid.UUID]
+	for rows.Next() {
+		event, err := scanPersonEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// recordEvent adds a change to the events table. before is nil for inserts, and after is nil for purges.
+func (p personDatastore) recordEvent(ctx context.Context, change db.Change, id uuid.UUID, before, after *db.Person, at time.Time) error {
+	if p.dialect.EventLock != "" {
+		if _, err := p.exec(ctx, p.dialect.EventLock); err != nil {
+			return fmt.Errorf("failed to lock events: %w", err)
+		}
+	}
+
+	beforeData, err := encodePerson(before)
+	if err != nil {
+		return err
+	}
+	afterData, err := encodePerson(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.exec(ctx,
+		`INSERT INTO person_events (person_id, kind, principal, changed_at, before, after) VALUES (?, ?, ?, ?, ?, ?)`,
+		id, change, db.ActingPrincipal(ctx), at, beforeData, afterData,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+
+	return nil
+}
+
+// encodePerson encodes a person for the events table, where nil is stored as NULL
+func encodePerson(person *db.Person) (sql.NullString, error) {
+	if person == nil {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(person)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode person: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// decodePerson decodes a person stored in the events table
+func decodePerson(data sql.NullString) (*db.Person, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+
+	var person db.Person
+	if err := json.Unmarshal([]byte(data.String), &person); err != nil {
+		return nil, fmt.Errorf("failed to decode person: %w", err)
+	}
+	return &person, nil
+}
+
+// scanPersonEvent reads a single event from the given row
+func scanPersonEvent(row scanner) (*db.Event[db.Person, uuid.UUID], error) {
+	var result db.Event[db.Person, uuid.UUID]
+	var sequence int64
+	var before, after sql.NullString
+
+	err := row.Scan(&sequence, &result.ID, &result.Change, &result.Principal, &result.Timestamp, &before, &after)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Sequence = uint64(sequence)
+	if result.Before, err = decodePerson(before); err != nil {
+		return nil, err
+	}
+	if result.After, err = decodePerson(after); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
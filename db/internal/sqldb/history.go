@@ -0,0 +1,114 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+const personHistoryColumns = "person_id, version, kind, principal, changed_at, first_name, last_name, date_of_birth, removed, " +
+	"created_at, updated_at, removed_at"
+
+type personHistoryStore struct {
+	conn    querier
+	dialect Dialect
+}
+
+// Get implements db.HistoryStore.
+func (p personHistoryStore) Get(ctx context.Context, id uuid.UUID, version int) (*db.HistoryEntry[db.Person], error) {
+	// The previous version is needed as well, to tell what the change modified
+	entries, err := p.entries(ctx,
+		`SELECT `+personHistoryColumns+` FROM person_history WHERE person_id = ? AND version IN (?, ?) ORDER BY version`,
+		id, version-1, version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get person history: %w", p.dialect.classify(err))
+	}
+	if len(entries) == 0 || entries[len(entries)-1].Version != version {
+		return nil, fmt.Errorf("failed to get person history: %w", db.ErrNoResultsFound)
+	}
+
+	entry := entries[len(entries)-1]
+	var previous *db.Person
+	if len(entries) == 2 {
+		previous = &entries[0].Item
+	}
+	entry.Diff = entry.Item.Diff(previous)
+
+	return &entry, nil
+}
+
+// List implements db.HistoryStore.
+func (p personHistoryStore) List(ctx context.Context, id uuid.UUID) ([]db.HistoryEntry[db.Person], error) {
+	entries, err := p.entries(ctx,
+		`SELECT `+personHistoryColumns+` FROM person_history WHERE person_id = ? ORDER BY version`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list person history: %w", p.dialect.classify(err))
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("failed to list person history: %w", db.ErrNoResultsFound)
+	}
+
+	for i := range entries {
+		var previous *db.Person
+		if i > 0 {
+			previous = &entries[i-1].Item
+		}
+		entries[i].Diff = entries[i].Item.Diff(previous)
+	}
+
+	return entries, nil
+}
+
+// entries reads the history entries returned by the given query
+func (p personHistoryStore) entries(ctx context.Context, query string, args ...any) ([]db.HistoryEntry[db.Person], error) {
+	rows, err := p.conn.QueryContext(ctx, p.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []db.HistoryEntry[db.Person]
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// scanHistoryEntry reads a single history entry, translating sql.ErrNoRows into db.ErrNoResultsFound
+func scanHistoryEntry(row scanner) (*db.HistoryEntry[db.Person], error) {
+	var result db.HistoryEntry[db.Person]
+	var removed bool
+	var removedAt sql.NullTime
+
+	err := row.Scan(
+		&result.Item.ID, &result.Version, &result.Change, &result.Principal, &result.Timestamp,
+		&result.Item.FirstName, &result.Item.LastName, &result.Item.DateOfBirth, &removed,
+		&result.Item.CreatedAt, &result.Item.UpdatedAt, &removedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrNoResultsFound
+		}
+		return nil, err
+	}
+
+	result.Item.Removed = db.NewBool(removed)
+	result.Item.RemovedAt = nullTime(removedAt)
+	result.Item.Version = result.Version
+	return &result, nil
+}
@@ -0,0 +1,398 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+const personColumns = "id, first_name, last_name, date_of_birth, removed, version, created_at, updated_at, removed_at"
+
+// personFieldColumns maps the fields of a person to the columns that hold them
+var personFieldColumns = map[db.Field]string{
+	db.PersonID:          "id",
+	db.PersonFirstName:   "first_name",
+	db.PersonLastName:    "last_name",
+	db.PersonDateOfBirth: "date_of_birth",
+}
+
+type personDatastore struct {
+	conn    querier
+	pool    *sql.DB // nil when conn is a transaction
+	dialect Dialect
+	clock   db.Clock
+	feed    *personFeed
+}
+
+// Get implements db.Datastore.
+func (p personDatastore) Get(ctx context.Context, id uuid.UUID) (*db.Person, error) {
+	row := p.queryRow(ctx,
+		`SELECT `+personColumns+` FROM person WHERE id = ?`,
+		id,
+	)
+
+	result, err := scanPerson(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get person: %w", p.dialect.classify(err))
+	}
+
+	return result, nil
+}
+
+// Insert implements db.Datastore.
+func (p personDatastore) Insert(ctx context.Context, item *db.Person) error {
+	inserted := *item
+	inserted.ID = uuid.New()
+	inserted.Removed = db.NewBool(isRemoved(item.Removed))
+	inserted.Version = 1
+	now := p.clock()
+	inserted.Timestamps = db.Timestamps{CreatedAt: now, UpdatedAt: now}
+	if *inserted.Removed {
+		inserted.RemovedAt = &now
+	}
+
+	err := p.atomically(ctx, func(p personDatastore) error {
+		_, err := p.exec(ctx,
+			`INSERT INTO person (`+personColumns+`) VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?)`,
+			inserted.ID, inserted.FirstName, inserted.LastName, inserted.DateOfBirth, *inserted.Removed,
+			inserted.CreatedAt, inserted.UpdatedAt, inserted.RemovedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return p.recordChange(ctx, db.ChangeInsert, &inserted)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert person: %w", p.dialect.classify(err))
+	}
+
+	*item = inserted
+	return nil
+}
+
+// InsertBatch implements db.Datastore.
+func (p personDatastore) InsertBatch(ctx context.Context, items []*db.Person) error {
+	return p.batch(ctx, len(items), func(p personDatastore, i int) error {
+		return p.Insert(ctx, items[i])
+	})
+}
+
+// Query implements db.Datastore.
+func (p personDatastore) Query(ctx context.Context, query db.Query) (*db.Page[db.Person], error) {
+	if err := query.Validate(db.Person{}.Field, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	page := &db.Page[db.Person]{}
+	countWhere, countArgs := whereClause(query, personFieldColumns, "id", false)
+	err := p.queryRow(ctx, `SELECT COUNT(*) FROM person`+countWhere, countArgs...).Scan(&page.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count people: %w", p.dialect.classify(err))
+	}
+
+	where, whereArgs := whereClause(query, personFieldColumns, "id", true)
+	limit, limitArgs := p.dialect.limitClause(query)
+
+	rows, err := p.query(ctx,
+		`SELECT `+personColumns+` FROM person`+where+orderByClause(query, personFieldColumns, "id")+limit,
+		append(whereArgs, limitArgs...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query people: %w", p.dialect.classify(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		person, err := scanPerson(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read person: %w", p.dialect.classify(err))
+		}
+		page.Items = append(page.Items, *person)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query people: %w", p.dialect.classify(err))
+	}
+
+	return page, nil
+}
+
+// Purge implements db.Datastore.
+func (p personDatastore) Purge(ctx context.Context, id uuid.UUID) error {
+	err := p.atomically(ctx, func(p personDatastore) error {
+		purged, err := scanPerson(p.queryRow(ctx, `DELETE FROM person WHERE id = ? AND removed = TRUE RETURNING `+personColumns, id))
+		if errors.Is(err, db.ErrNoResultsFound) {
+			return p.missingOr(ctx, id, db.ErrNotRemoved)
+		}
+		if err != nil {
+			return err
+		}
+		return p.recordEvent(ctx, db.ChangePurge, id, purged, nil, p.clock())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge person: %w", p.dialect.classify(err))
+	}
+
+	return nil
+}
+
+// Remove implements db.Datastore.
+func (p personDatastore) Remove(ctx context.Context, id uuid.UUID) (*db.Person, error) {
+	result, err := p.setRemoved(ctx, id, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove person: %w", p.dialect.classify(err))
+	}
+
+	return result, nil
+}
+
+// RemoveBatch implements db.Datastore.
+func (p personDatastore) RemoveBatch(ctx context.Context, ids []uuid.UUID) ([]*db.Person, error) {
+	result := make([]*db.Person, len(ids))
+	err := p.batch(ctx, len(ids), func(p personDatastore, i int) error {
+		var err error
+		result[i], err = p.Remove(ctx, ids[i])
+		return err
+	})
+
+	return result, err
+}
+
+// Restore implements db.Datastore.
+func (p personDatastore) Restore(ctx context.Context, id uuid.UUID) (*db.Person, error) {
+	result, err := p.setRemoved(ctx, id, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore person: %w", p.dialect.classify(err))
+	}
+
+	return result, nil
+}
+
+// setRemoved implements both Remove and Restore
+func (p personDatastore) setRemoved(ctx context.Context, id uuid.UUID, removed bool) (*db.Person, error) {
+	change, unchangedErr := db.ChangeRestore, db.ErrNotRemoved
+	if removed {
+		change, unchangedErr = db.ChangeRemove, db.ErrAlreadyRemoved
+	}
+
+	now := p.clock()
+	var removedAt *time.Time
+	if removed {
+		removedAt = &now
+	}
+
+	var result *db.Person
+	err := p.atomically(ctx, func(p personDatastore) error {
+		row := p.queryRow(ctx,
+			`UPDATE person SET removed = ?, removed_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND removed = ? RETURNING `+personColumns,
+			removed, removedAt, now, id, !removed,
+		)
+
+		var err error
+		result, err = scanPerson(row)
+		if errors.Is(err, db.ErrNoResultsFound) {
+			return p.missingOr(ctx, id, unchangedErr)
+		}
+		if err != nil {
+			return err
+		}
+		return p.recordChange(ctx, change, result)
+	})
+
+	return result, err
+}
+
+// Update implements db.Datastore.
+func (p personDatastore) Update(ctx context.Context, item *db.Person) error {
+	// The removed mark and removal time are left alone, since only Remove and Restore change them
+	now := p.clock()
+	query := `UPDATE person SET first_name = ?, last_name = ?, date_of_birth = ?, updated_at = ?, version = version + 1 WHERE id = ?`
+	args := []any{item.FirstName, item.LastName, item.DateOfBirth, now, item.ID}
+	if item.Version != 0 {
+		query += ` AND version = ?`
+		args = append(args, item.Version)
+	}
+
+	var updated *db.Person
+	err := p.atomically(ctx, func(p personDatastore) error {
+		var err error
+		updated, err = scanPerson(p.queryRow(ctx, query+` RETURNING `+personColumns, args...))
+		if errors.Is(err, db.ErrNoResultsFound) {
+			// Nothing was updated, either because the person doesn't exist or because the version is stale
+			return p.missingOr(ctx, item.ID, db.ErrVersionConflict)
+		}
+		if err != nil {
+			return err
+		}
+		return p.recordChange(ctx, db.ChangeUpdate, updated)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update person: %w", p.dialect.classify(err))
+	}
+
+	item.Removed = updated.Removed
+	item.Version = updated.Version
+	item.Timestamps = updated.Timestamps
+	return nil
+}
+
+// UpdateBatch implements db.Datastore.
+func (p personDatastore) UpdateBatch(ctx context.Context, items []*db.Person) error {
+	return p.batch(ctx, len(items), func(p personDatastore, i int) error {
+		return p.Update(ctx, items[i])
+	})
+}
+
+// batch calls fn for every item of a batch of n items, all in a single transaction. Every call runs in its own
+// savepoint, so the changes of a failed item are rolled back without affecting the rest of the transaction.
+// The errors of the failed items are returned in a db.BatchError.
+func (p personDatastore) batch(ctx context.Context, n int, fn func(p personDatastore, i int) error) error {
+	errs := make([]error, n)
+	err := p.atomically(ctx, func(p personDatastore) error {
+		for i := range errs {
+			if err := db.ContextError(ctx); err != nil {
+				return err
+			}
+
+			if _, err := p.exec(ctx, `SAVEPOINT batch_item`); err != nil {
+				return fmt.Errorf("failed to create savepoint: %w", err)
+			}
+			if errs[i] = fn(p, i); errs[i] != nil {
+				if _, err := p.exec(ctx, `ROLLBACK TO SAVEPOINT batch_item`); err != nil {
+					return fmt.Errorf("failed to roll back to savepoint: %w", err)
+				}
+			}
+			if _, err := p.exec(ctx, `RELEASE SAVEPOINT batch_item`); err != nil {
+				return fmt.Errorf("failed to release savepoint: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return p.dialect.classify(err)
+	}
+
+	return db.NewBatchError(errs)
+}
+
+// recordChange adds the change that produced the given state of a person to its history, and to the events table.
+// The state before the change is taken from the previous version in the history.
+func (p personDatastore) recordChange(ctx context.Context, change db.Change, person *db.Person) error {
+	if err := p.recordHistory(ctx, change, person); err != nil {
+		return err
+	}
+
+	var before *db.Person
+	if change != db.ChangeInsert {
+		entry, err := scanHistoryEntry(p.queryRow(ctx,
+			`SELECT `+personHistoryColumns+` FROM person_history WHERE person_id = ? AND version = ?`,
+			person.ID, person.Version-1,
+		))
+		if err != nil && !errors.Is(err, db.ErrNoResultsFound) {
+			return fmt.Errorf("failed to read previous version: %w", err)
+		}
+		if entry != nil {
+			before = &entry.Item
+		}
+	}
+
+	return p.recordEvent(ctx, change, person.ID, before, person, person.UpdatedAt)
+}
+
+// recordHistory adds the change that produced the given state of a person to its history
+func (p personDatastore) recordHistory(ctx context.Context, change db.Change, person *db.Person) error {
+	_, err := p.exec(ctx,
+		`INSERT INTO person_history (`+personHistoryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		person.ID, person.Version, change, db.ActingPrincipal(ctx), person.UpdatedAt,
+		person.FirstName, person.LastName, person.DateOfBirth, isRemoved(person.Removed),
+		person.CreatedAt, person.UpdatedAt, person.RemovedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+
+	return nil
+}
+
+// atomically runs fn in a transaction, so that the statements it runs take effect together.
+// If the datastore already is part of a transaction, fn simply becomes part of it.
+// The feed is notified of the new events once they are committed.
+func (p personDatastore) atomically(ctx context.Context, fn func(p personDatastore) error) error {
+	err := runTx(ctx, p.conn, p.pool, func(conn querier) error {
+		return fn(personDatastore{conn: conn, dialect: p.dialect, clock: p.clock, feed: p.feed})
+	})
+	if err == nil && p.pool != nil {
+		p.feed.notify()
+	}
+	return err
+}
+
+// missingOr is used after a statement unexpectedly affected no rows. It returns db.ErrNoResultsFound
+// if the person with the given ID doesn't exist, and otherwise the given error.
+func (p personDatastore) missingOr(ctx context.Context, id uuid.UUID, err error) error {
+	var exists int
+	switch existsErr := p.queryRow(ctx, `SELECT 1 FROM person WHERE id = ?`, id).Scan(&exists); {
+	case errors.Is(existsErr, sql.ErrNoRows):
+		return db.ErrNoResultsFound
+	case existsErr != nil:
+		return existsErr
+	default:
+		return err
+	}
+}
+
+func (p personDatastore) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return p.conn.ExecContext(ctx, p.dialect.Rebind(query), args...)
+}
+
+func (p personDatastore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return p.conn.QueryContext(ctx, p.dialect.Rebind(query), args...)
+}
+
+func (p personDatastore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return p.conn.QueryRowContext(ctx, p.dialect.Rebind(query), args...)
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPerson reads a single person from the given row, translating sql.ErrNoRows into db.ErrNoResultsFound
+func scanPerson(row scanner) (*db.Person, error) {
+	var result db.Person
+	var removed bool
+	var removedAt sql.NullTime
+
+	err := row.Scan(
+		&result.ID, &result.FirstName, &result.LastName, &result.DateOfBirth, &removed, &result.Version,
+		&result.CreatedAt, &result.UpdatedAt, &removedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrNoResultsFound
+		}
+		return nil, err
+	}
+
+	result.Removed = db.NewBool(removed)
+	result.RemovedAt = nullTime(removedAt)
+	return &result, nil
+}
+
+// nullTime converts a nullable timestamp column to a pointer that is nil if the column is NULL
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// isRemoved reads a db.NullBool, treating NULL as false
+func isRemoved(removed db.NullBool) bool {
+	return removed != nil && *removed
+}
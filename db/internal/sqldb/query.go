@@ -0,0 +1,115 @@
+package sqldb
+
+import (
+	"strings"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// sqlOperators maps the db.Operator values to their SQL equivalent
+var sqlOperators = map[db.Operator]string{
+	db.Equal:          "=",
+	db.NotEqual:       "<>",
+	db.LessThan:       "<",
+	db.LessOrEqual:    "<=",
+	db.GreaterThan:    ">",
+	db.GreaterOrEqual: ">=",
+}
+
+// whereClause builds the WHERE clause, and its arguments, that selects the rows matching query.
+// columns maps the fields of the entity to the columns that hold them, and keyColumn is the column
+// that holds its key. The query must already be validated.
+//
+// The query's After position is only taken into account if withPosition is set,
+// so that the same function can build the clause used to count all matching rows.
+func whereClause(query db.Query, columns map[db.Field]string, keyColumn string, withPosition bool) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if withPosition && query.After != nil {
+		condition, positionArgs := positionCondition(query, columns, keyColumn)
+		conditions = append(conditions, condition)
+		args = append(args, positionArgs...)
+	}
+
+	if !query.IncludeRemoved {
+		conditions = append(conditions, "removed = FALSE")
+	}
+	for _, filter := range query.Filters {
+		conditions = append(conditions, columns[filter.Field]+" "+sqlOperators[filter.Operator]+" ?")
+		args = append(args, filter.Value)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// positionCondition builds the condition that selects the rows that sort after the query's After position.
+// For sort fields a and b this is: a > ? OR (a = ? AND b > ?) OR (a = ? AND b = ? AND key > ?),
+// with every comparison reversed if the query walks backward.
+func positionCondition(query db.Query, columns map[db.Field]string, keyColumn string) (string, []any) {
+	sortColumns := make([]string, 0, len(query.Sort)+1)
+	operators := make([]string, 0, len(query.Sort)+1)
+	for _, sort := range query.Sort {
+		sortColumns = append(sortColumns, columns[sort.Field])
+		if sort.Descending != query.Backward {
+			operators = append(operators, "<")
+		} else {
+			operators = append(operators, ">")
+		}
+	}
+	sortColumns = append(sortColumns, keyColumn)
+	if query.Backward {
+		operators = append(operators, "<")
+	} else {
+		operators = append(operators, ">")
+	}
+
+	var alternatives []string
+	var args []any
+	for i := range sortColumns {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, sortColumns[j]+" = ?")
+			args = append(args, query.After[j])
+		}
+		terms = append(terms, sortColumns[i]+" "+operators[i]+" ?")
+		args = append(args, query.After[i])
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// orderByClause builds the ORDER BY clause for the sort fields of query, with keyColumn as the final tiebreaker.
+func orderByClause(query db.Query, columns map[db.Field]string, keyColumn string) string {
+	terms := make([]string, 0, len(query.Sort)+1)
+	for _, sort := range query.Sort {
+		term := columns[sort.Field]
+		if sort.Descending != query.Backward {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	if query.Backward {
+		terms = append(terms, keyColumn+" DESC")
+	} else {
+		terms = append(terms, keyColumn)
+	}
+
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// limitClause builds the LIMIT and OFFSET clause, and its arguments, for the given query.
+func (d Dialect) limitClause(query db.Query) (string, []any) {
+	switch {
+	case query.Limit > 0:
+		return " LIMIT ? OFFSET ?", []any{query.Limit, query.Offset}
+	case query.Offset > 0:
+		return " LIMIT " + d.NoLimit + " OFFSET ?", []any{query.Offset}
+	}
+	return "", nil
+}
@@ -0,0 +1,149 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Dialect describes the parts of SQL that differ between databases.
+type Dialect struct {
+	// Placeholder returns the bind parameter for the n-th (starting at 1) argument of a query.
+	Placeholder func(n int) string
+	// NoLimit is the value of a LIMIT clause that doesn't limit the number of rows.
+	NoLimit string
+	// Classify returns the db error, such as db.ErrConflict, that describes an error returned by the driver,
+	// or nil if the error isn't specific to the driver.
+	Classify func(err error) error
+	// EventLock is a statement that takes a lock which is held until the end of the transaction. It is run before
+	// an event is recorded, so that events are committed in the order of their sequence. It may be left empty if
+	// the database only runs one writing transaction at a time anyway.
+	EventLock string
+}
+
+// classify wraps err in the db error that describes it, so that callers can handle it without knowing
+// which driver returned it. Errors that don't match any db error are returned as is.
+func (d Dialect) classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var kind error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		kind = db.ErrDeadlineExceeded
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		kind = db.ErrUnavailable
+	case d.Classify != nil:
+		kind = d.Classify(err)
+	}
+
+	if kind == nil || errors.Is(err, kind) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// Rebind replaces the '?' bind parameters in query with the ones used by the dialect.
+func (d Dialect) Rebind(query string) string {
+	if d.Placeholder == nil {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString(d.Placeholder(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
+
+// querier is the subset of *sql.DB that the datastores make use of
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type sqlDB struct {
+	conn       querier
+	pool       *sql.DB // nil when conn is a transaction
+	dialect    Dialect
+	clock      db.Clock
+	personFeed *personFeed
+}
+
+// Person implements db.Database.
+func (s sqlDB) Person() db.Datastore[db.Person, uuid.UUID] {
+	return personDatastore{conn: s.conn, pool: s.pool, dialect: s.dialect, clock: s.clock, feed: s.personFeed}
+}
+
+// PersonHistory implements db.Database.
+func (s sqlDB) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return personHistoryStore{conn: s.conn, dialect: s.dialect}
+}
+
+// PersonFeed implements db.Database.
+func (s sqlDB) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return s.personFeed
+}
+
+// WithTx implements db.Database.
+func (s sqlDB) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	err := runTx(ctx, s.conn, s.pool, func(conn querier) error {
+		return fn(sqlDB{conn: conn, dialect: s.dialect, clock: s.clock, personFeed: s.personFeed})
+	})
+	if err == nil && s.pool != nil {
+		s.personFeed.notify()
+	}
+	return s.dialect.classify(err)
+}
+
+// runTx runs fn in a new transaction on pool, or on conn if pool is nil because conn already is a transaction.
+// The transaction is committed if fn returns nil, and rolled back if it returns an error or panics.
+func runTx(ctx context.Context, conn querier, pool *sql.DB, fn func(conn querier) error) error {
+	if pool == nil { // Already in a transaction
+		return fn(conn)
+	}
+
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back transaction: %w", rollbackErr))
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// New returns a db.Database that uses the given connection and dialect, and timestamps changes with the given clock.
+// The tables used by the datastores must already exist.
+func New(conn *sql.DB, dialect Dialect, clock db.Clock) db.Database {
+	return sqlDB{conn: conn, pool: conn, dialect: dialect, clock: clock, personFeed: newPersonFeed(conn, dialect)}
+}
@@ -0,0 +1,496 @@
+package memdb
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/feed"
+)
+
+// IDGenerator creates the identifier for a newly inserted item.
+type IDGenerator[U db.Identifier] func() U
+
+// UUIDGenerator returns an IDGenerator that creates random UUIDs.
+func UUIDGenerator() IDGenerator[uuid.UUID] {
+	return uuid.New
+}
+
+// IntGenerator returns an IDGenerator that behaves like an auto-increment column, starting at 1.
+func IntGenerator() IDGenerator[int] {
+	var counter int64
+	return func() int {
+		return int(atomic.AddInt64(&counter, 1))
+	}
+}
+
+// Schema describes how a Datastore accesses the bookkeeping fields of the entity it stores.
+type Schema[T db.Entity, U db.Identifier] struct {
+	// Key returns a pointer to the field of item that holds its identifier.
+	Key func(item *T) *U
+	// Removed returns a pointer to the field of item that marks it as removed.
+	Removed func(item *T) *db.NullBool
+	// Version returns a pointer to the field of item that holds its version.
+	// It may be left nil if the datastore doesn't need to keep track of versions.
+	Version func(item *T) *int
+	// NewID generates the identifier of newly inserted items.
+	NewID IDGenerator[U]
+	// Field returns the value of the given field of item, and false if item doesn't have that field.
+	// It may be left nil if the datastore doesn't need to support filtering or sorting in queries.
+	Field func(item *T, field db.Field) (any, bool)
+	// Diff lists the fields that differ between previous, which is nil for the first version of an item, and item.
+	// It may be left nil, in which case history entries don't have a diff.
+	Diff func(previous, item *T) []db.FieldChange
+	// Timestamps returns a pointer to the field of item that holds its timestamps.
+	// It may be left nil if the datastore doesn't need to keep track of them.
+	Timestamps func(item *T) *db.Timestamps
+}
+
+// WriteHook is called with the history entry of every change a Datastore is about to make. The entry holds
+// the new state of the item, and is nil if the item is about to be purged. The entry's Diff is never filled in.
+// The change is abandoned, and the error is returned to the caller, if the hook returns an error.
+// tx is the transaction the change is part of, or nil if it isn't made in a transaction.
+// sequence is the sequence of the event that will report the change on the datastore's feed.
+type WriteHook[T db.Entity, U db.Identifier] func(tx *Tx, id U, sequence uint64, entry *db.HistoryEntry[T]) error
+
+// Datastore is a generic, thread-safe implementation of db.Datastore that keeps its items, and their history, in memory.
+// Items are copied on the way in and on the way out, so callers can never change stored state.
+type Datastore[T db.Entity, U db.Identifier] struct {
+	mu       sync.RWMutex
+	items    map[U]T
+	history  map[U][]db.HistoryEntry[T] // The Diff of the entries is filled in when they are read
+	schema   Schema[T, U]
+	hook     WriteHook[T, U]
+	clock    db.Clock
+	sequence uint64 // The sequence of the latest event
+	feed     *feed.Broker[T, U]
+}
+
+// NewDatastore creates an empty Datastore that uses the given schema to access its items.
+func NewDatastore[T db.Entity, U db.Identifier](schema Schema[T, U]) *Datastore[T, U] {
+	return &Datastore[T, U]{
+		items:   make(map[U]T),
+		history: make(map[U][]db.HistoryEntry[T]),
+		schema:  schema,
+		clock:   db.SystemClock,
+		feed:    feed.NewBroker(feed.Options[T, U]{}),
+	}
+}
+
+// Feed returns the feed that reports the changes made to the datastore. Only the most recent events are kept
+// for subscribers that resume from an earlier sequence, and none of them survive a restart.
+func (d *Datastore[T, U]) Feed() db.ChangeFeed[T, U] {
+	return d.feed
+}
+
+// OnWrite registers a hook that is called before every change to the datastore, which allows
+// other packages to persist the changes. It must be called before the datastore is used.
+func (d *Datastore[T, U]) OnWrite(hook WriteHook[T, U]) {
+	d.hook = hook
+}
+
+// UseClock replaces the clock that is used to timestamp changes. It must be called before the datastore is used.
+func (d *Datastore[T, U]) UseClock(clock db.Clock) {
+	d.clock = clock
+}
+
+// Load puts the given items into the datastore as they are, without generating IDs or calling the write hook.
+// It is intended for restoring previously persisted state.
+func (d *Datastore[T, U]) Load(items ...T) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := range items {
+		d.items[*d.schema.Key(&items[i])] = *d.copy(&items[i])
+	}
+}
+
+// LoadHistory appends the given entries to the history of the item with the given ID, without calling the write hook.
+// Like Load, it is intended for restoring previously persisted state.
+func (d *Datastore[T, U]) LoadHistory(id U, entries ...db.HistoryEntry[T]) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := range entries {
+		d.history[id] = append(d.history[id], d.copyEntry(&entries[i]))
+	}
+}
+
+// CloseFeed ends every subscription to the datastore's feed with db.ErrFeedClosed.
+// The datastore must not be changed afterwards.
+func (d *Datastore[T, U]) CloseFeed() {
+	d.feed.Close()
+}
+
+// LoadSequence sets the sequence of the latest event on the datastore's feed, so that the feed continues
+// where it left off. Like Load, it is intended for restoring previously persisted state.
+func (d *Datastore[T, U]) LoadSequence(sequence uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sequence = sequence
+	d.feed.SetLast(sequence)
+}
+
+// Snapshot calls fn with a copy of every item in the datastore, of their history, and the sequence of the latest event
+// on its feed. No changes can be made to the datastore until fn returns, so fn sees a consistent view of its contents.
+func (d *Datastore[T, U]) Snapshot(fn func(items []T, history map[U][]db.HistoryEntry[T], sequence uint64) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	items := make([]T, 0, len(d.items))
+	for _, item := range d.items {
+		items = append(items, *d.copy(&item))
+	}
+
+	history := make(map[U][]db.HistoryEntry[T], len(d.history))
+	for id, entries := range d.history {
+		for i := range entries {
+			history[id] = append(history[id], d.copyEntry(&entries[i]))
+		}
+	}
+
+	return fn(items, history, d.sequence)
+}
+
+// Get implements db.Datastore.
+func (d *Datastore[T, U]) Get(ctx context.Context, id U) (*T, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.get(ctx, id)
+}
+
+// Insert implements db.Datastore.
+func (d *Datastore[T, U]) Insert(ctx context.Context, item *T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.insert(ctx, nil, item)
+}
+
+// InsertBatch implements db.Datastore. The datastore stays locked for the whole batch.
+func (d *Datastore[T, U]) InsertBatch(ctx context.Context, items []*T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.insertBatch(ctx, nil, items)
+}
+
+// Purge implements db.Datastore.
+func (d *Datastore[T, U]) Purge(ctx context.Context, id U) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.purge(ctx, nil, id)
+}
+
+// Query implements db.Datastore.
+func (d *Datastore[T, U]) Query(ctx context.Context, query db.Query) (*db.Page[T], error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.query(ctx, query)
+}
+
+// Remove implements db.Datastore.
+func (d *Datastore[T, U]) Remove(ctx context.Context, id U) (*T, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.remove(ctx, nil, id)
+}
+
+// RemoveBatch implements db.Datastore. The datastore stays locked for the whole batch.
+func (d *Datastore[T, U]) RemoveBatch(ctx context.Context, ids []U) ([]*T, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.removeBatch(ctx, nil, ids)
+}
+
+// Restore implements db.Datastore.
+func (d *Datastore[T, U]) Restore(ctx context.Context, id U) (*T, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.restore(ctx, nil, id)
+}
+
+// Update implements db.Datastore.
+func (d *Datastore[T, U]) Update(ctx context.Context, item *T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.update(ctx, nil, item)
+}
+
+// UpdateBatch implements db.Datastore. The datastore stays locked for the whole batch.
+func (d *Datastore[T, U]) UpdateBatch(ctx context.Context, items []*T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.updateBatch(ctx, nil, items)
+}
+
+/*
+	The functions below implement the datastore operations. The caller must hold the appropriate lock,
+	and pass the transaction the operation is part of, if any.
+*/
+
+func (d *Datastore[T, U]) get(ctx context.Context, id U) (*T, error) {
+	if err := db.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	stored, ok := d.items[id]
+	if !ok {
+		return nil, db.ErrNoResultsFound
+	}
+
+	return d.copy(&stored), nil
+}
+
+func (d *Datastore[T, U]) insert(ctx context.Context, tx *Tx, item *T) error {
+	if err := db.ContextError(ctx); err != nil {
+		return err
+	}
+
+	stored := *d.copy(item)
+	id := d.schema.NewID()
+	*d.schema.Key(&stored) = id
+	if removed := d.schema.Removed(&stored); *removed == nil {
+		*removed = db.NewBool(false)
+	}
+	if d.schema.Version != nil {
+		*d.schema.Version(&stored) = 1
+	}
+
+	if err := d.write(ctx, tx, db.ChangeInsert, id, &stored); err != nil {
+		return err
+	}
+	*item = *d.copy(&stored)
+
+	return nil
+}
+
+func (d *Datastore[T, U]) insertBatch(ctx context.Context, tx *Tx, items []*T) error {
+	return db.Batch(ctx, len(items), func(i int) error {
+		return d.insert(ctx, tx, items[i])
+	})
+}
+
+func (d *Datastore[T, U]) purge(ctx context.Context, tx *Tx, id U) error {
+	if err := db.ContextError(ctx); err != nil {
+		return err
+	}
+
+	stored, ok := d.items[id]
+	if !ok {
+		return db.ErrNoResultsFound
+	}
+	if !d.isRemoved(&stored) {
+		return db.ErrNotRemoved
+	}
+
+	return d.write(ctx, tx, db.ChangePurge, id, nil)
+}
+
+func (d *Datastore[T, U]) remove(ctx context.Context, tx *Tx, id U) (*T, error) {
+	return d.setRemoved(ctx, tx, id, true)
+}
+
+func (d *Datastore[T, U]) removeBatch(ctx context.Context, tx *Tx, ids []U) ([]*T, error) {
+	result := make([]*T, len(ids))
+	err := db.Batch(ctx, len(ids), func(i int) error {
+		var err error
+		result[i], err = d.remove(ctx, tx, ids[i])
+		return err
+	})
+
+	return result, err
+}
+
+func (d *Datastore[T, U]) restore(ctx context.Context, tx *Tx, id U) (*T, error) {
+	return d.setRemoved(ctx, tx, id, false)
+}
+
+// setRemoved implements both remove and restore
+func (d *Datastore[T, U]) setRemoved(ctx context.Context, tx *Tx, id U, removed bool) (*T, error) {
+	if err := db.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	stored, ok := d.items[id]
+	if !ok {
+		return nil, db.ErrNoResultsFound
+	}
+	if d.isRemoved(&stored) == removed {
+		if removed {
+			return nil, db.ErrAlreadyRemoved
+		}
+		return nil, db.ErrNotRemoved
+	}
+
+	*d.schema.Removed(&stored) = db.NewBool(removed)
+	if d.schema.Version != nil {
+		*d.schema.Version(&stored)++
+	}
+	change := db.ChangeRestore
+	if removed {
+		change = db.ChangeRemove
+	}
+	if err := d.write(ctx, tx, change, id, &stored); err != nil {
+		return nil, err
+	}
+
+	return d.copy(&stored), nil
+}
+
+func (d *Datastore[T, U]) update(ctx context.Context, tx *Tx, item *T) error {
+	if err := db.ContextError(ctx); err != nil {
+		return err
+	}
+
+	id := *d.schema.Key(item)
+	current, ok := d.items[id]
+	if !ok {
+		return db.ErrNoResultsFound
+	}
+
+	stored := *d.copy(item)
+	*d.schema.Removed(&stored) = db.NewBool(d.isRemoved(&current))
+	if d.schema.Version != nil {
+		currentVersion := *d.schema.Version(&current)
+		if version := d.schema.Version(&stored); *version != 0 && *version != currentVersion {
+			return db.ErrVersionConflict
+		}
+		*d.schema.Version(&stored) = currentVersion + 1
+	}
+
+	if err := d.write(ctx, tx, db.ChangeUpdate, id, &stored); err != nil {
+		return err
+	}
+	*item = *d.copy(&stored)
+
+	return nil
+}
+
+func (d *Datastore[T, U]) updateBatch(ctx context.Context, tx *Tx, items []*T) error {
+	return db.Batch(ctx, len(items), func(i int) error {
+		return d.update(ctx, tx, items[i])
+	})
+}
+
+// write stores the given item and records the change in its history, or deletes both if item is nil,
+// after giving the write hook a chance to reject the change. The change is published on the feed right away,
+// or when the transaction it is part of is committed. The caller must hold the write lock.
+func (d *Datastore[T, U]) write(ctx context.Context, tx *Tx, change db.Change, id U, item *T) error {
+	now := d.clock()
+	event := db.Event[T, U]{
+		Sequence:  d.sequence + 1,
+		Change:    change,
+		ID:        id,
+		Principal: db.ActingPrincipal(ctx),
+		Timestamp: now,
+	}
+	if previous, ok := d.items[id]; ok {
+		event.Before = d.copy(&previous)
+	}
+
+	if item == nil {
+		if d.hook != nil {
+			if err := d.hook(tx, id, event.Sequence, nil); err != nil {
+				return err
+			}
+		}
+
+		delete(d.items, id)
+		delete(d.history, id)
+		d.publish(tx, event)
+		return nil
+	}
+
+	d.stamp(id, item, now)
+
+	entry := db.HistoryEntry[T]{
+		Version:   len(d.history[id]) + 1,
+		Change:    change,
+		Principal: event.Principal,
+		Timestamp: now,
+		Item:      *d.copy(item),
+	}
+	if d.schema.Version != nil {
+		entry.Version = *d.schema.Version(item)
+	}
+
+	if d.hook != nil {
+		hookEntry := d.copyEntry(&entry)
+		if err := d.hook(tx, id, event.Sequence, &hookEntry); err != nil {
+			return err
+		}
+	}
+
+	d.items[id] = *item
+	d.history[id] = append(d.history[id], entry)
+	event.After = d.copy(item)
+	d.publish(tx, event)
+	return nil
+}
+
+// publish takes the sequence of event, and publishes it on the feed once tx, if any, is committed.
+// The caller must hold the write lock.
+func (d *Datastore[T, U]) publish(tx *Tx, event db.Event[T, U]) {
+	d.sequence = event.Sequence
+	if tx == nil {
+		d.feed.Publish(event)
+		return
+	}
+	tx.onCommit(func() { d.feed.Publish(event) })
+}
+
+// stamp sets the timestamps of an item that is about to be written. The creation and removal times are carried
+// over from the stored version of the item, unless it is being created or removed by this change.
+func (d *Datastore[T, U]) stamp(id U, item *T, now time.Time) {
+	if d.schema.Timestamps == nil {
+		return
+	}
+
+	timestamps := d.schema.Timestamps(item)
+	previous, exists := d.items[id]
+	*timestamps = db.Timestamps{CreatedAt: now, UpdatedAt: now}
+	if exists {
+		timestamps.CreatedAt = d.schema.Timestamps(&previous).CreatedAt
+	}
+	if d.isRemoved(item) {
+		if exists && d.isRemoved(&previous) {
+			timestamps.RemovedAt = d.schema.Timestamps(&previous).RemovedAt
+		} else {
+			timestamps.RemovedAt = &now
+		}
+	}
+}
+
+// isRemoved reports whether the given item is marked as removed
+func (d *Datastore[T, U]) isRemoved(item *T) bool {
+	removed := *d.schema.Removed(item)
+	return removed != nil && *removed
+}
+
+// copyEntry creates a deep copy of the given history entry
+func (d *Datastore[T, U]) copyEntry(entry *db.HistoryEntry[T]) db.HistoryEntry[T] {
+	result := *entry
+	result.Item = *d.copy(&entry.Item)
+	result.Diff = slices.Clone(entry.Diff)
+	return result
+}
+
+// copy creates a deep copy of the given item so that the stored data
+// and the data handed back to callers never share memory.
+func (d *Datastore[T, U]) copy(item *T) *T {
+	result := *item
+	if removed := d.schema.Removed(&result); *removed != nil {
+		*removed = db.NewBool(**removed)
+	}
+	if d.schema.Timestamps != nil {
+		if removedAt := &d.schema.Timestamps(&result).RemovedAt; *removedAt != nil {
+			removedTime := **removedAt
+			*removedAt = &removedTime
+		}
+	}
+	return &result
+}
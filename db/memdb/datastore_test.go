@@ -0,0 +1,96 @@
+package memdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Compile time check that Datastore satisfies db.Datastore for identifiers other than uuid.UUID
+var _ db.Datastore[db.Person, int] = (*Datastore[db.Person, int])(nil)
+
+func TestIntGenerator(t *testing.T) {
+	first := IntGenerator()
+	assert.Equal(t, 1, first())
+	assert.Equal(t, 2, first())
+	assert.Equal(t, 3, first())
+
+	// Every generator keeps its own counter
+	second := IntGenerator()
+	assert.Equal(t, 1, second())
+}
+
+// intPersonSchema stores people under an int key. db.Person is the only entity, and Version is its only int field,
+// so it doubles as the key and versions aren't kept track of.
+var intPersonSchema = Schema[db.Person, int]{
+	Key:     func(item *db.Person) *int { return &item.Version },
+	Removed: func(item *db.Person) *db.NullBool { return &item.Removed },
+	NewID:   IntGenerator(),
+	Field:   func(item *db.Person, field db.Field) (any, bool) { return item.Field(field) },
+}
+
+func TestDatastore_IntKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewDatastore(intPersonSchema)
+
+	people := []*db.Person{
+		{FirstName: "Some", LastName: "Charlie"},
+		{FirstName: "Some", LastName: "Alpha"},
+		{FirstName: "Some", LastName: "Bravo"},
+	}
+	for i, person := range people {
+		if err := store.Insert(ctx, person); err != nil {
+			t.Fatalf("failed to insert person: %v", err)
+		}
+		assert.Equal(t, i+1, person.Version, "keys are assigned in order")
+	}
+
+	got, err := store.Get(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Alpha", got.LastName)
+	}
+
+	got.FirstName = "Changed"
+	if err := store.Update(ctx, got); err != nil {
+		t.Fatalf("failed to update person: %v", err)
+	}
+	got, err = store.Get(ctx, 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Changed", got.FirstName)
+	}
+
+	removed, err := store.Remove(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.True(t, *removed.Removed)
+	}
+
+	page, err := store.Query(ctx, db.Query{Sort: []db.Sort{{Field: db.PersonLastName}}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, page.Total)
+		assert.Equal(t, []int{2, 3}, keys(page.Items))
+	}
+
+	page, err = store.Query(ctx, db.Query{IncludeRemoved: true, After: []any{2}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{3}, keys(page.Items), "paging continues after the int key")
+	}
+
+	if err := store.Purge(ctx, 1); err != nil {
+		t.Fatalf("failed to purge person: %v", err)
+	}
+	_, err = store.Get(ctx, 1)
+	assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	_, err = store.Get(ctx, 99)
+	assert.ErrorIs(t, err, db.ErrNoResultsFound)
+}
+
+// keys returns the int keys of people stored with intPersonSchema
+func keys(people []db.Person) []int {
+	result := make([]int, len(people))
+	for i := range people {
+		result[i] = people[i].Version
+	}
+	return result
+}
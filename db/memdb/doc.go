@@ -0,0 +1,5 @@
+// memdb is a thread-safe, in-memory implementation of db.Database.
+//
+// Nothing is persisted between restarts, which makes it a good fit for local development
+// and for tests that need a database that actually remembers what was written to it.
+package memdb
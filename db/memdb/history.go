@@ -0,0 +1,95 @@
+package memdb
+
+import (
+	"context"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// History returns the db.HistoryStore that gives access to the history of the items in the datastore.
+func (d *Datastore[T, U]) History() db.HistoryStore[T, U] {
+	return historyStore[T, U]{datastore: d}
+}
+
+// TxHistory returns the db.HistoryStore of a datastore that has joined tx, which includes the changes made in tx.
+// Using the store returned by Datastore.History in a transaction would deadlock, since the datastore is locked by tx.
+func TxHistory[T db.Entity, U db.Identifier](tx *Tx, d *Datastore[T, U]) db.HistoryStore[T, U] {
+	return txHistoryStore[T, U]{datastore: d}
+}
+
+// historyStore locks the datastore before reading its history
+type historyStore[T db.Entity, U db.Identifier] struct {
+	datastore *Datastore[T, U]
+}
+
+// Get implements db.HistoryStore.
+func (h historyStore[T, U]) Get(ctx context.Context, id U, version int) (*db.HistoryEntry[T], error) {
+	h.datastore.mu.RLock()
+	defer h.datastore.mu.RUnlock()
+	return h.datastore.historyEntry(ctx, id, version)
+}
+
+// List implements db.HistoryStore.
+func (h historyStore[T, U]) List(ctx context.Context, id U) ([]db.HistoryEntry[T], error) {
+	h.datastore.mu.RLock()
+	defer h.datastore.mu.RUnlock()
+	return h.datastore.historyEntries(ctx, id)
+}
+
+// txHistoryStore reads the history of a datastore that is already locked by a transaction
+type txHistoryStore[T db.Entity, U db.Identifier] struct {
+	datastore *Datastore[T, U]
+}
+
+// Get implements db.HistoryStore.
+func (h txHistoryStore[T, U]) Get(ctx context.Context, id U, version int) (*db.HistoryEntry[T], error) {
+	return h.datastore.historyEntry(ctx, id, version)
+}
+
+// List implements db.HistoryStore.
+func (h txHistoryStore[T, U]) List(ctx context.Context, id U) ([]db.HistoryEntry[T], error) {
+	return h.datastore.historyEntries(ctx, id)
+}
+
+func (d *Datastore[T, U]) historyEntry(ctx context.Context, id U, version int) (*db.HistoryEntry[T], error) {
+	entries, err := d.historyEntries(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if entries[i].Version == version {
+			return &entries[i], nil
+		}
+	}
+
+	return nil, db.ErrNoResultsFound
+}
+
+// historyEntries returns a copy of the history of an item, with the diff of every entry against the one before it
+func (d *Datastore[T, U]) historyEntries(ctx context.Context, id U) ([]db.HistoryEntry[T], error) {
+	if err := db.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	stored, ok := d.history[id]
+	if !ok {
+		return nil, db.ErrNoResultsFound
+	}
+
+	entries := make([]db.HistoryEntry[T], 0, len(stored))
+	for i := range stored {
+		entry := d.copyEntry(&stored[i])
+		entry.Diff = nil
+		if d.schema.Diff != nil {
+			var previous *T
+			if i > 0 {
+				previous = &stored[i-1].Item
+			}
+			entry.Diff = d.schema.Diff(previous, &entry.Item)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,87 @@
+package memdb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+type memDB struct {
+	person *Datastore[db.Person, uuid.UUID]
+}
+
+// Person implements db.Database.
+func (m *memDB) Person() db.Datastore[db.Person, uuid.UUID] {
+	return m.person
+}
+
+// PersonHistory implements db.Database.
+func (m *memDB) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return m.person.History()
+}
+
+// PersonFeed implements db.Database.
+func (m *memDB) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return m.person.Feed()
+}
+
+// WithTx implements db.Database.
+func (m *memDB) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	if err := db.ContextError(ctx); err != nil {
+		return err
+	}
+
+	tx := &Tx{}
+	txDB := &memTx{person: Join(tx, m.person), personHistory: TxHistory(tx, m.person), personFeed: m.person.Feed()}
+	return RunTx(tx, func() error { return fn(txDB) }, nil)
+}
+
+// memTx is the db.Database that is passed to the function run by WithTx
+type memTx struct {
+	person        db.Datastore[db.Person, uuid.UUID]
+	personHistory db.HistoryStore[db.Person, uuid.UUID]
+	personFeed    db.ChangeFeed[db.Person, uuid.UUID]
+}
+
+// Person implements db.Database.
+func (m *memTx) Person() db.Datastore[db.Person, uuid.UUID] {
+	return m.person
+}
+
+// PersonHistory implements db.Database.
+func (m *memTx) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return m.personHistory
+}
+
+// PersonFeed implements db.Database. Changes made in the transaction are only published once it is committed.
+func (m *memTx) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return m.personFeed
+}
+
+// WithTx implements db.Database. The function is run as part of the existing transaction.
+func (m *memTx) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	return fn(m)
+}
+
+// Option configures a database created by NewSession
+type Option func(*memDB)
+
+// WithClock sets the clock that is used to timestamp changes. By default the system clock is used.
+func WithClock(clock db.Clock) Option {
+	return func(m *memDB) {
+		m.person.UseClock(clock)
+	}
+}
+
+// NewSession creates a new, empty in-memory database.
+func NewSession(opts ...Option) db.Database {
+	m := &memDB{
+		person: NewDatastore(PersonSchema),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
@@ -0,0 +1,18 @@
+package memdb
+
+import (
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// PersonSchema tells a Datastore how to access the bookkeeping fields of a db.Person.
+// IDs of newly inserted people are random UUIDs.
+var PersonSchema = Schema[db.Person, uuid.UUID]{
+	Key:        func(item *db.Person) *uuid.UUID { return &item.ID },
+	Removed:    func(item *db.Person) *db.NullBool { return &item.Removed },
+	Version:    func(item *db.Person) *int { return &item.Version },
+	NewID:      UUIDGenerator(),
+	Field:      func(item *db.Person, field db.Field) (any, bool) { return item.Field(field) },
+	Diff:       func(previous, item *db.Person) []db.FieldChange { return item.Diff(previous) },
+	Timestamps: func(item *db.Person) *db.Timestamps { return &item.Timestamps },
+}
@@ -0,0 +1,94 @@
+package memdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/dbtest"
+)
+
+func TestPersonDatastore(t *testing.T) {
+	dbtest.RunPersonDatastore(t, NewSession())
+}
+
+func TestPersonQuery(t *testing.T) {
+	dbtest.RunPersonQuery(t, NewSession())
+}
+
+func TestPersonBatch(t *testing.T) {
+	dbtest.RunPersonBatch(t, NewSession())
+}
+
+func TestPersonErrors(t *testing.T) {
+	dbtest.RunPersonErrors(t, NewSession())
+}
+
+func TestPersonHistory(t *testing.T) {
+	dbtest.RunPersonHistory(t, NewSession())
+}
+
+func TestPersonTimestamps(t *testing.T) {
+	dbtest.RunPersonTimestamps(t, func(clock db.Clock) db.Database {
+		return NewSession(WithClock(clock))
+	})
+}
+
+func TestPersonFeed(t *testing.T) {
+	dbtest.RunPersonFeed(t, NewSession())
+}
+
+func TestTx(t *testing.T) {
+	dbtest.RunTx(t, NewSession())
+}
+
+func TestDatastore_Copies(t *testing.T) {
+	ctx := context.Background()
+	store := NewSession().Person()
+
+	person := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+	if err := store.Insert(ctx, person); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+
+	// Mutating the inserted value must not change what is stored
+	person.FirstName = "Changed"
+
+	// Mutating the returned value must not change what is stored
+	got, err := store.Get(ctx, person.ID)
+	if err != nil {
+		t.Fatalf("failed to get person: %v", err)
+	}
+	assert.Equal(t, "Testy", got.FirstName)
+	*got.Removed = true
+
+	again, _ := store.Get(ctx, person.ID)
+	assert.False(t, *again.Removed)
+}
+
+func TestDatastore_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	store := NewSession().Person()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			person := &db.Person{FirstName: "Concurrent"}
+			if err := store.Insert(ctx, person); err != nil {
+				t.Errorf("failed to insert person: %v", err)
+				return
+			}
+			if _, err := store.Get(ctx, person.ID); err != nil {
+				t.Errorf("failed to get person: %v", err)
+			}
+			if _, err := store.Remove(ctx, person.ID); err != nil {
+				t.Errorf("failed to remove person: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
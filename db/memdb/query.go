@@ -0,0 +1,161 @@
+package memdb
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+func (d *Datastore[T, U]) query(ctx context.Context, query db.Query) (*db.Page[T], error) {
+	if err := db.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	var zero T
+	err := query.Validate(func(field db.Field) (any, bool) {
+		return d.field(&zero, field)
+	}, *d.schema.Key(&zero))
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]T, 0, len(d.items))
+	for _, item := range d.items {
+		if d.matches(&item, query) {
+			matches = append(matches, *d.copy(&item))
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b T) int {
+		return d.compare(&a, &b, query)
+	})
+
+	page := &db.Page[T]{Total: len(matches)}
+	if query.After != nil {
+		// Skip everything up to, and including, the given position
+		start, _ := slices.BinarySearchFunc(matches, query.After, func(item T, after []any) int {
+			if d.compareToPosition(&item, query, after) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		matches = matches[start:]
+	}
+	if query.Offset < len(matches) {
+		matches = matches[query.Offset:]
+		if query.Limit > 0 && query.Limit < len(matches) {
+			matches = matches[:query.Limit]
+		}
+		page.Items = matches
+	}
+
+	return page, nil
+}
+
+// field returns the value of the given field of item, and false if there is no such field.
+func (d *Datastore[T, U]) field(item *T, field db.Field) (any, bool) {
+	if d.schema.Field == nil {
+		return nil, false
+	}
+	return d.schema.Field(item, field)
+}
+
+// matches reports whether the given item should be part of the results of query.
+func (d *Datastore[T, U]) matches(item *T, query db.Query) bool {
+	if !query.IncludeRemoved && d.isRemoved(item) {
+		return false
+	}
+
+	for _, filter := range query.Filters {
+		value, _ := d.field(item, filter.Field)
+		result := compareValues(value, filter.Value)
+
+		var ok bool
+		switch filter.Operator {
+		case db.Equal:
+			ok = result == 0
+		case db.NotEqual:
+			ok = result != 0
+		case db.LessThan:
+			ok = result < 0
+		case db.LessOrEqual:
+			ok = result <= 0
+		case db.GreaterThan:
+			ok = result > 0
+		case db.GreaterOrEqual:
+			ok = result >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compare orders two items by the sort fields of query, and then by their keys.
+func (d *Datastore[T, U]) compare(a, b *T, query db.Query) int {
+	for _, sort := range query.Sort {
+		aValue, _ := d.field(a, sort.Field)
+		bValue, _ := d.field(b, sort.Field)
+
+		if result := directed(compareValues(aValue, bValue), sort.Descending != query.Backward); result != 0 {
+			return result
+		}
+	}
+
+	return directed(compareValues(*d.schema.Key(a), *d.schema.Key(b)), query.Backward)
+}
+
+// compareToPosition orders an item relative to the db.Query.After position of query, in the order of query.
+func (d *Datastore[T, U]) compareToPosition(item *T, query db.Query, position []any) int {
+	for i, sort := range query.Sort {
+		value, _ := d.field(item, sort.Field)
+
+		if result := directed(compareValues(value, position[i]), sort.Descending != query.Backward); result != 0 {
+			return result
+		}
+	}
+
+	return directed(compareValues(*d.schema.Key(item), position[len(query.Sort)]), query.Backward)
+}
+
+// directed reverses the result of a comparison if reversed is set
+func directed(result int, reversed bool) int {
+	if reversed {
+		return -result
+	}
+	return result
+}
+
+// compareValues returns a negative number if a sorts before b, a positive number if it sorts after and zero if they are equal.
+// Both values must be of the same type, which must be one of the types that entity fields are made of.
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case uuid.UUID:
+		bID := b.(uuid.UUID)
+		return bytes.Compare(a[:], bID[:])
+	case bool:
+		switch bBool := b.(bool); {
+		case a == bBool:
+			return 0
+		case bBool:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,163 @@
+package memdb
+
+import (
+	"context"
+	"maps"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Tx groups changes to one or more Datastores so that they are kept or discarded together.
+//
+// Every Datastore that joins a transaction stays locked until the transaction is committed or rolled back,
+// so other goroutines never observe its uncommitted changes. To avoid deadlocks, transactions that
+// join several datastores must always join them in the same order.
+type Tx struct {
+	participants []txParticipant
+	committed    []func() // Called when the transaction is committed, before the datastores are unlocked
+}
+
+type txParticipant interface {
+	rollback()
+	unlock()
+}
+
+// Join locks the datastore for the rest of the transaction, and returns a view of it whose
+// changes are undone if the transaction is rolled back.
+func Join[T db.Entity, U db.Identifier](tx *Tx, d *Datastore[T, U]) db.Datastore[T, U] {
+	d.mu.Lock()
+
+	view := &txDatastore[T, U]{
+		tx:               tx,
+		datastore:        d,
+		original:         maps.Clone(d.items),
+		originalHistory:  maps.Clone(d.history),
+		originalSequence: d.sequence,
+	}
+	tx.participants = append(tx.participants, view)
+
+	return view
+}
+
+// Commit keeps the changes made during the transaction, and unlocks the datastores that joined it.
+func (tx *Tx) Commit() {
+	for _, fn := range tx.committed {
+		fn()
+	}
+	for _, participant := range tx.participants {
+		participant.unlock()
+	}
+	tx.participants = nil
+	tx.committed = nil
+}
+
+// Rollback discards the changes made during the transaction, and unlocks the datastores that joined it.
+func (tx *Tx) Rollback() {
+	for _, participant := range tx.participants {
+		participant.rollback()
+		participant.unlock()
+	}
+	tx.participants = nil
+	tx.committed = nil
+}
+
+// onCommit registers fn to be called when the transaction is committed. Since the datastores are still locked
+// at that point, functions that publish the changes made by the transaction publish them in order.
+func (tx *Tx) onCommit(fn func()) {
+	tx.committed = append(tx.committed, fn)
+}
+
+// RunTx runs fn, and commits tx if fn returns nil. If fn returns an error or panics, tx is rolled back.
+// commit, if not nil, is called before the transaction is committed and can still cause it to roll back.
+func RunTx(tx *Tx, fn func() error, commit func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if commit != nil {
+		if err := commit(); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	tx.Commit()
+	return nil
+}
+
+// txDatastore is the view of a Datastore that has joined a transaction.
+// The datastore is already locked, so it calls the datastore operations directly.
+type txDatastore[T db.Entity, U db.Identifier] struct {
+	tx               *Tx
+	datastore        *Datastore[T, U]
+	original         map[U]T
+	originalHistory  map[U][]db.HistoryEntry[T] // Entries are only ever appended, so the slices can be shared
+	originalSequence uint64
+}
+
+func (t *txDatastore[T, U]) rollback() {
+	t.datastore.items = t.original
+	t.datastore.history = t.originalHistory
+	t.datastore.sequence = t.originalSequence
+}
+
+func (t *txDatastore[T, U]) unlock() {
+	t.datastore.mu.Unlock()
+}
+
+// Get implements db.Datastore.
+func (t *txDatastore[T, U]) Get(ctx context.Context, id U) (*T, error) {
+	return t.datastore.get(ctx, id)
+}
+
+// Insert implements db.Datastore.
+func (t *txDatastore[T, U]) Insert(ctx context.Context, item *T) error {
+	return t.datastore.insert(ctx, t.tx, item)
+}
+
+// InsertBatch implements db.Datastore.
+func (t *txDatastore[T, U]) InsertBatch(ctx context.Context, items []*T) error {
+	return t.datastore.insertBatch(ctx, t.tx, items)
+}
+
+// Purge implements db.Datastore.
+func (t *txDatastore[T, U]) Purge(ctx context.Context, id U) error {
+	return t.datastore.purge(ctx, t.tx, id)
+}
+
+// Query implements db.Datastore.
+func (t *txDatastore[T, U]) Query(ctx context.Context, query db.Query) (*db.Page[T], error) {
+	return t.datastore.query(ctx, query)
+}
+
+// Remove implements db.Datastore.
+func (t *txDatastore[T, U]) Remove(ctx context.Context, id U) (*T, error) {
+	return t.datastore.remove(ctx, t.tx, id)
+}
+
+// RemoveBatch implements db.Datastore.
+func (t *txDatastore[T, U]) RemoveBatch(ctx context.Context, ids []U) ([]*T, error) {
+	return t.datastore.removeBatch(ctx, t.tx, ids)
+}
+
+// Restore implements db.Datastore.
+func (t *txDatastore[T, U]) Restore(ctx context.Context, id U) (*T, error) {
+	return t.datastore.restore(ctx, t.tx, id)
+}
+
+// Update implements db.Datastore.
+func (t *txDatastore[T, U]) Update(ctx context.Context, item *T) error {
+	return t.datastore.update(ctx, t.tx, item)
+}
+
+// UpdateBatch implements db.Datastore.
+func (t *txDatastore[T, U]) UpdateBatch(ctx context.Context, items []*T) error {
+	return t.datastore.updateBatch(ctx, t.tx, items)
+}
@@ -0,0 +1,6 @@
+// migrations applies and rolls back versioned changes to the schema of a SQL database.
+//
+// Migrations are plain SQL files that are usually embedded into the binary with embed.FS. Every migration has an up
+// file that makes the change and a down file that reverses it. The versions of the applied migrations are kept in
+// the schema_migrations table, and a lock makes sure that only one instance migrates a database at the same time.
+package migrations
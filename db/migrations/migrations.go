@@ -0,0 +1,299 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownMigration indicates that the database has a migration applied that the Migrator doesn't know about,
+// which usually means that it was migrated by a newer version of the server.
+var ErrUnknownMigration = errors.New("database has an unknown migration applied")
+
+// Migration is a single, versioned change to the database schema
+type Migration struct {
+	Version int
+	Name    string
+	// Up holds the SQL statements that make the change.
+	Up string
+	// Down holds the SQL statements that reverse the change.
+	Down string
+}
+
+// fileNamePattern matches the names of migration files, e.g. 0001_create_person.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the root directory of fsys and returns them ordered by version.
+// Every migration consists of two files named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Files with other names are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s has an invalid version", entry.Name())
+		}
+		contents, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", migration.Name, match[2], version)
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		result = append(result, *migration)
+	}
+	slices.SortFunc(result, func(a, b Migration) int { return a.Version - b.Version })
+
+	return result, nil
+}
+
+// Dialect describes the parts of running migrations that differ between databases.
+type Dialect struct {
+	// Rebind replaces the '?' bind parameters of a query with the ones the database understands.
+	// It may be left nil if the database understands '?'.
+	Rebind func(query string) string
+	// Lock is called at the start of the transaction that migrations are run in. It must block until no other
+	// transaction holds the lock, and the lock must be released when the transaction ends.
+	// It may be left nil if starting the transaction already takes a lock on the whole database.
+	Lock func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Status tells whether a migration has been applied to the database
+type Status struct {
+	Migration
+	Applied bool
+	// AppliedAt is the time the migration was applied at, or zero if it is not applied.
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to a database, and rolls them back.
+//
+// Every operation runs in a single transaction that holds the migration lock. Both PostgreSQL and SQLite can
+// change their schema in a transaction, so either every migration of an operation takes effect, or none do.
+type Migrator struct {
+	conn       *sql.DB
+	migrations []Migration
+	dialect    Dialect
+}
+
+// New creates a Migrator that applies the given migrations, which must be ordered by version, to conn.
+func New(conn *sql.DB, migrations []Migration, dialect Dialect) *Migrator {
+	return &Migrator{conn: conn, migrations: migrations, dialect: dialect}
+}
+
+// Up applies every migration that isn't applied yet, in order, and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.UpTo(ctx, math.MaxInt)
+}
+
+// UpTo applies every migration up to and including the given version that isn't applied yet, in order,
+// and returns the ones it applied.
+func (m *Migrator) UpTo(ctx context.Context, version int) ([]Migration, error) {
+	var applied []Migration
+	err := m.inTx(ctx, func(tx *sql.Tx, status []Status) error {
+		for _, s := range status {
+			if s.Applied || s.Version > version {
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, s.Up); err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", s.Version, s.Name, err)
+			}
+			_, err := tx.ExecContext(ctx,
+				m.rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+				s.Version, s.Name, time.Now().UTC(),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to record migration %d_%s: %w", s.Version, s.Name, err)
+			}
+			applied = append(applied, s.Migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the given number of applied migrations, starting with the latest one, and returns the ones it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := m.inTx(ctx, func(tx *sql.Tx, status []Status) error {
+		for i := len(status) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+			s := status[i]
+			if !s.Applied {
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, s.Down); err != nil {
+				return fmt.Errorf("failed to roll back migration %d_%s: %w", s.Version, s.Name, err)
+			}
+			_, err := tx.ExecContext(ctx, m.rebind(`DELETE FROM schema_migrations WHERE version = ?`), s.Version)
+			if err != nil {
+				return fmt.Errorf("failed to record rollback of migration %d_%s: %w", s.Version, s.Name, err)
+			}
+			rolledBack = append(rolledBack, s.Migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rolledBack, nil
+}
+
+// Status reports which migrations are applied to the database. It doesn't make any changes to the database.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var result []Status
+	err := m.inTx(ctx, func(tx *sql.Tx, status []Status) error {
+		result = status
+		return errReadOnly
+	})
+	if err != nil && !errors.Is(err, errReadOnly) {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// errReadOnly makes inTx roll back the transaction of an operation that only reads
+var errReadOnly = errors.New("read only")
+
+// inTx runs fn in a transaction that holds the migration lock, and passes it the status of every migration.
+// The transaction is committed if fn returns nil.
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx, status []Status) error) error {
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if m.dialect.Lock != nil {
+		if err := m.dialect.Lock(ctx, tx); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER   PRIMARY KEY,
+	name       TEXT      NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	status, err := m.status(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx, status); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration transaction: %w", err)
+	}
+	return nil
+}
+
+// status reads the applied migrations from the schema_migrations table and matches them to the known migrations
+func (m *Migrator) status(ctx context.Context, tx *sql.Tx) ([]Status, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	status := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		status = append(status, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+		delete(applied, migration.Version)
+	}
+	for version := range applied {
+		return nil, fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+	}
+
+	return status, nil
+}
+
+// rebind replaces the '?' bind parameters in query with the ones used by the dialect
+func (m *Migrator) rebind(query string) string {
+	if m.dialect.Rebind == nil {
+		return query
+	}
+	return m.dialect.Rebind(query)
+}
+
+// ErrPendingMigrations indicates that the database schema is behind the migrations known to the Migrator
+var ErrPendingMigrations = errors.New("database has migrations that are not applied")
+
+// Check returns an error wrapping ErrPendingMigrations if any migration is not applied to the database yet.
+func (m *Migrator) Check(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range status {
+		if !s.Applied {
+			return fmt.Errorf("%w: version %d_%s is the first one missing", ErrPendingMigrations, s.Version, s.Name)
+		}
+	}
+	return nil
+}
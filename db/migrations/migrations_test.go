@@ -0,0 +1,184 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3" // Registers the "sqlite3" database/sql driver
+	"github.com/stretchr/testify/assert"
+)
+
+// testFS holds two valid migrations, and a file that is not a migration
+var testFS = fstest.MapFS{
+	"0002_add_age.up.sql":        {Data: []byte("ALTER TABLE thing ADD COLUMN age INTEGER;")},
+	"0002_add_age.down.sql":      {Data: []byte("ALTER TABLE thing DROP COLUMN age;")},
+	"0001_create_thing.up.sql":   {Data: []byte("CREATE TABLE thing (id INTEGER PRIMARY KEY);")},
+	"0001_create_thing.down.sql": {Data: []byte("DROP TABLE thing;")},
+	"README.md":                  {Data: []byte("not a migration")},
+}
+
+// testMigrator returns a Migrator for the migrations in testFS, that uses a new SQLite database
+func testMigrator(t *testing.T, dialect Dialect) (*Migrator, *sql.DB) {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrations, err := Load(testFS)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	return New(conn, migrations, dialect), conn
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			fsys: testFS,
+			want: []Migration{
+				{Version: 1, Name: "create_thing", Up: "CREATE TABLE thing (id INTEGER PRIMARY KEY);", Down: "DROP TABLE thing;"},
+				{Version: 2, Name: "add_age", Up: "ALTER TABLE thing ADD COLUMN age INTEGER;", Down: "ALTER TABLE thing DROP COLUMN age;"},
+			},
+		},
+		{
+			name: "Missing Down",
+			fsys: fstest.MapFS{
+				"0001_create_thing.up.sql": {Data: []byte("CREATE TABLE thing (id INTEGER);")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicate Version",
+			fsys: fstest.MapFS{
+				"0001_create_thing.up.sql":   {Data: []byte("CREATE TABLE thing (id INTEGER);")},
+				"0001_create_thing.down.sql": {Data: []byte("DROP TABLE thing;")},
+				"0001_create_other.up.sql":   {Data: []byte("CREATE TABLE other (id INTEGER);")},
+				"0001_create_other.down.sql": {Data: []byte("DROP TABLE other;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Version Zero",
+			fsys: fstest.MapFS{
+				"0000_create_thing.up.sql":   {Data: []byte("CREATE TABLE thing (id INTEGER);")},
+				"0000_create_thing.down.sql": {Data: []byte("DROP TABLE thing;")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fsys)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	migrator, conn := testMigrator(t, Dialect{})
+
+	status, err := migrator.Status(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, status, 2) {
+		assert.False(t, status[0].Applied)
+		assert.False(t, status[1].Applied)
+	}
+	assert.ErrorIs(t, migrator.Check(ctx), ErrPendingMigrations)
+
+	applied, err := migrator.UpTo(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{migrator.migrations[0]}, applied)
+
+	applied, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{migrator.migrations[1]}, applied)
+	assert.NoError(t, migrator.Check(ctx))
+	_, err = conn.ExecContext(ctx, "INSERT INTO thing (id, age) VALUES (1, 42)")
+	assert.NoError(t, err)
+
+	status, err = migrator.Status(ctx)
+	assert.NoError(t, err)
+	for _, s := range status {
+		assert.True(t, s.Applied)
+		assert.False(t, s.AppliedAt.IsZero())
+	}
+
+	rolledBack, err := migrator.Down(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{migrator.migrations[1], migrator.migrations[0]}, rolledBack)
+	_, err = conn.ExecContext(ctx, "SELECT * FROM thing")
+	assert.Error(t, err, "the table must be dropped")
+}
+
+func TestMigrator_FailedMigration(t *testing.T) {
+	ctx := context.Background()
+	migrator, conn := testMigrator(t, Dialect{})
+	migrator.migrations[1].Up = "ALTER TABLE missing ADD COLUMN age INTEGER;"
+
+	_, err := migrator.Up(ctx)
+	assert.Error(t, err)
+
+	// Migrations are applied in a single transaction, so the first one must be rolled back too
+	status, err := migrator.Status(ctx)
+	assert.NoError(t, err)
+	for _, s := range status {
+		assert.False(t, s.Applied)
+	}
+	_, err = conn.ExecContext(ctx, "SELECT * FROM thing")
+	assert.Error(t, err)
+}
+
+func TestMigrator_UnknownMigration(t *testing.T) {
+	ctx := context.Background()
+	migrator, _ := testMigrator(t, Dialect{})
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	older := New(migrator.conn, migrator.migrations[:1], Dialect{})
+	_, err = older.Status(ctx)
+	assert.ErrorIs(t, err, ErrUnknownMigration)
+	_, err = older.Up(ctx)
+	assert.ErrorIs(t, err, ErrUnknownMigration)
+}
+
+func TestMigrator_Lock(t *testing.T) {
+	ctx := context.Background()
+	locked := 0
+	lockErr := errors.New("mock error")
+	migrator, _ := testMigrator(t, Dialect{Lock: func(ctx context.Context, tx *sql.Tx) error {
+		locked++
+		if locked > 1 {
+			return lockErr
+		}
+		return nil
+	}})
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, locked)
+
+	_, err = migrator.Down(ctx, 1)
+	assert.ErrorIs(t, err, lockErr)
+	assert.Equal(t, 2, locked)
+}
@@ -6,10 +6,67 @@ import (
 	"github.com/google/uuid"
 )
 
+// The fields of a Person that can be used in a Query
+const (
+	PersonID          Field = "id"
+	PersonFirstName   Field = "firstName"
+	PersonLastName    Field = "lastName"
+	PersonDateOfBirth Field = "dateOfBirth"
+)
+
+// PersonRemoved is the field that marks a person as removed. It appears in history diffs,
+// but can't be used in a Query, which has Query.IncludeRemoved instead.
+const PersonRemoved Field = "removed"
+
 type Person struct {
 	ID          uuid.UUID
 	FirstName   string
 	LastName    string
 	DateOfBirth time.Time
 	Removed     NullBool
+	// Version starts at 1 and is incremented by the datastore every time the person is changed.
+	Version int
+	Timestamps
+}
+
+// Field returns the value of the given field, and false if a Person does not have such a field.
+func (p Person) Field(field Field) (any, bool) {
+	switch field {
+	case PersonID:
+		return p.ID, true
+	case PersonFirstName:
+		return p.FirstName, true
+	case PersonLastName:
+		return p.LastName, true
+	case PersonDateOfBirth:
+		return p.DateOfBirth, true
+	}
+	return nil, false
+}
+
+// Diff lists the fields that differ between previous and p. If previous is nil, the fields that are set in p are listed.
+// Removed is compared as a plain bool. The ID, Version and Timestamps are left out, since they are bookkeeping
+// that every version of a person shares or that differs between all of them.
+func (p Person) Diff(previous *Person) []FieldChange {
+	if previous == nil {
+		previous = &Person{}
+	}
+
+	var diff []FieldChange
+	if p.FirstName != previous.FirstName {
+		diff = append(diff, FieldChange{Field: PersonFirstName, Old: previous.FirstName, New: p.FirstName})
+	}
+	if p.LastName != previous.LastName {
+		diff = append(diff, FieldChange{Field: PersonLastName, Old: previous.LastName, New: p.LastName})
+	}
+	if !p.DateOfBirth.Equal(previous.DateOfBirth) {
+		diff = append(diff, FieldChange{Field: PersonDateOfBirth, Old: previous.DateOfBirth, New: p.DateOfBirth})
+	}
+	removed := p.Removed != nil && *p.Removed
+	previouslyRemoved := previous.Removed != nil && *previous.Removed
+	if removed != previouslyRemoved {
+		diff = append(diff, FieldChange{Field: PersonRemoved, Old: previouslyRemoved, New: removed})
+	}
+
+	return diff
 }
@@ -0,0 +1,5 @@
+// postgres is an implementation of db.Database that stores its data in PostgreSQL using database/sql.
+//
+// The connection itself is owned by the caller, which allows the same *sql.DB to be shared and
+// closed when the API server shuts down. Use Open to create a connection with the pgx driver.
+package postgres
@@ -0,0 +1,7 @@
+CREATE TABLE person (
+	id            UUID        PRIMARY KEY,
+	first_name    TEXT        NOT NULL,
+	last_name     TEXT        NOT NULL,
+	date_of_birth DATE        NOT NULL,
+	removed       BOOLEAN     NOT NULL DEFAULT FALSE
+);
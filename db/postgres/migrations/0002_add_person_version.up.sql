@@ -0,0 +1 @@
+ALTER TABLE person ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
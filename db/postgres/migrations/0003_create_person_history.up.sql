@@ -0,0 +1,12 @@
+CREATE TABLE person_history (
+	person_id     UUID        NOT NULL REFERENCES person (id) ON DELETE CASCADE,
+	version       INTEGER     NOT NULL,
+	kind          TEXT        NOT NULL,
+	principal     TEXT        NOT NULL DEFAULT '',
+	changed_at    TIMESTAMPTZ NOT NULL,
+	first_name    TEXT        NOT NULL,
+	last_name     TEXT        NOT NULL,
+	date_of_birth DATE        NOT NULL,
+	removed       BOOLEAN     NOT NULL,
+	PRIMARY KEY (person_id, version)
+);
@@ -0,0 +1,9 @@
+ALTER TABLE person_history
+	DROP COLUMN removed_at,
+	DROP COLUMN updated_at,
+	DROP COLUMN created_at;
+
+ALTER TABLE person
+	DROP COLUMN removed_at,
+	DROP COLUMN updated_at,
+	DROP COLUMN created_at;
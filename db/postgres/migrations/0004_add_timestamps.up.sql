@@ -0,0 +1,22 @@
+-- People were created when their first change was recorded, if the history goes back that far
+ALTER TABLE person
+	ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ADD COLUMN removed_at TIMESTAMPTZ;
+UPDATE person SET
+	created_at = COALESCE((SELECT MIN(changed_at) FROM person_history WHERE person_id = person.id), created_at),
+	updated_at = COALESCE((SELECT MAX(changed_at) FROM person_history WHERE person_id = person.id), updated_at);
+UPDATE person SET removed_at = updated_at WHERE removed;
+
+-- The history has no defaults, so they are only used to fill in the existing rows
+ALTER TABLE person_history
+	ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ADD COLUMN removed_at TIMESTAMPTZ;
+UPDATE person_history SET
+	created_at = (SELECT MIN(changed_at) FROM person_history AS earliest WHERE earliest.person_id = person_history.person_id),
+	updated_at = changed_at,
+	removed_at = CASE WHEN removed THEN changed_at END;
+ALTER TABLE person_history
+	ALTER COLUMN created_at DROP DEFAULT,
+	ALTER COLUMN updated_at DROP DEFAULT;
@@ -0,0 +1,9 @@
+CREATE TABLE person_events (
+	sequence   BIGSERIAL   PRIMARY KEY,
+	person_id  UUID        NOT NULL,
+	kind       TEXT        NOT NULL,
+	principal  TEXT        NOT NULL DEFAULT '',
+	changed_at TIMESTAMPTZ NOT NULL,
+	before     JSONB,
+	after      JSONB
+);
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" database/sql driver
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/sqldb"
+	"github.com/williabk198/go-api-server-template/db/migrations"
+)
+
+// eventLockKey identifies the advisory lock that serializes the transactions that record events, so that
+// they are committed in the order of their sequence. Like migrationLockKey, it is an arbitrary number.
+const eventLockKey = 7367687
+
+// dialect describes the PostgreSQL flavor of SQL
+var dialect = sqldb.Dialect{
+	Placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	NoLimit:     "ALL",
+	Classify:    classifyError,
+	EventLock:   fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", eventLockKey),
+}
+
+// classifyError returns the db error that describes an error returned by PostgreSQL, based on its SQLSTATE code,
+// or by pgx while talking to the server.
+func classifyError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "57014": // query_canceled, which statement_timeout causes as well
+			return db.ErrDeadlineExceeded
+		case pgErr.Code == "42501": // insufficient_privilege
+			return db.ErrPermissionDenied
+		case pgErr.Code == "40001", pgErr.Code == "40P01": // serialization_failure and deadlock_detected
+			return db.ErrConflict
+		case strings.HasPrefix(pgErr.Code, "23"): // integrity_constraint_violation
+			return db.ErrConflict
+		case strings.HasPrefix(pgErr.Code, "22"): // data_exception
+			return db.ErrInvalidArgument
+		case strings.HasPrefix(pgErr.Code, "08"), // connection_exception
+			strings.HasPrefix(pgErr.Code, "53"),  // insufficient_resources
+			strings.HasPrefix(pgErr.Code, "57P"): // operator_intervention, such as the server shutting down
+			return db.ErrUnavailable
+		}
+		return nil
+	}
+
+	if pgconn.Timeout(err) {
+		return db.ErrDeadlineExceeded
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) {
+		return db.ErrUnavailable
+	}
+	return nil
+}
+
+// migrationFiles holds the migrations that create and change the tables used by the datastores
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey identifies the advisory lock that is held while migrating.
+// It is an arbitrary number that is only required to be the same for every instance of the server.
+const migrationLockKey = 7367686
+
+// migrationDialect runs the migrations while holding a transaction-level advisory lock,
+// which postgres releases by itself if the instance that holds it crashes.
+var migrationDialect = migrations.Dialect{
+	Rebind: dialect.Rebind,
+	Lock: func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockKey)
+		return err
+	},
+}
+
+// Open creates a connection pool to the PostgreSQL server described by dsn and verifies that it is reachable.
+func Open(ctx context.Context, dsn string) (*sql.DB, error) {
+	conn, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres connection: %w", err)
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to reach postgres server: %w", err)
+	}
+
+	return conn, nil
+}
+
+// Option configures the database returned by NewSession
+type Option func(*options)
+
+type options struct {
+	clock db.Clock
+}
+
+// WithClock sets the clock that is used to timestamp changes. By default the system clock is used.
+func WithClock(clock db.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// Migrator returns a Migrator that applies the migrations of the PostgreSQL datastores to conn.
+func Migrator(conn *sql.DB) (*migrations.Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := migrations.Load(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrations.New(conn, loaded, migrationDialect), nil
+}
+
+// NewSession returns a db.Database that uses the given connection.
+// The database must already be migrated to the latest version, see Migrator.
+func NewSession(ctx context.Context, conn *sql.DB, opts ...Option) (db.Database, error) {
+	options := options{clock: db.SystemClock}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	migrator, err := Migrator(conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrator.Check(ctx); err != nil {
+		return nil, fmt.Errorf("postgres schema is not up to date: %w", err)
+	}
+
+	return sqldb.New(conn, dialect, options.clock), nil
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ErrInvalidQuery indicates that a query refers to an unknown field, or compares a field to a value of the wrong type
+var ErrInvalidQuery = fmt.Errorf("%w: invalid query", ErrInvalidArgument)
+
+// Field names a field of an entity that can be used to filter and sort query results.
+type Field string
+
+// Operator is the comparison a Filter makes between a field and a value.
+type Operator string
+
+const (
+	Equal          Operator = "eq"
+	NotEqual       Operator = "ne"
+	LessThan       Operator = "lt"
+	LessOrEqual    Operator = "lte"
+	GreaterThan    Operator = "gt"
+	GreaterOrEqual Operator = "gte"
+)
+
+// Filter restricts query results to the items whose field compares to the value with the given operator.
+// The value must be of the same type as the field, e.g. a time.Time for Person.DateOfBirth.
+type Filter struct {
+	Field    Field
+	Operator Operator
+	Value    any
+}
+
+// Sort orders query results by a field.
+type Sort struct {
+	Field      Field
+	Descending bool
+}
+
+// Query describes which items to list from a Datastore, and in which order.
+type Query struct {
+	// Filters that every returned item must match.
+	Filters []Filter
+	// IncludeRemoved includes items that have been marked as removed in the results.
+	IncludeRemoved bool
+	// Sort orders the results by each of the given fields in turn.
+	// Results are always sorted by their key last, so that the order is stable.
+	Sort []Sort
+	// After, if set, only returns the items that sort after the given position, which allows paging through
+	// results without being affected by items that are inserted or removed in the meantime. It must hold a value
+	// for each of the Sort fields, in the same order, followed by the key of the item the position belongs to.
+	After []any
+	// Backward reverses the order of the results, including the order of their keys, so that After
+	// returns the items that sort before the given position, closest first.
+	Backward bool
+	// Limit is the maximum number of items to return. Zero means there is no limit.
+	Limit int
+	// Offset is the number of matching items to skip before returning results.
+	Offset int
+}
+
+// Page is a subset of the items that matched a query.
+type Page[T Entity] struct {
+	Items []T
+	// Total is the number of items that matched the query, regardless of its After position, limit and offset.
+	Total int
+}
+
+// Validate checks the query against the fields of an entity. fieldOf returns the value of the given
+// field for a zero value of the entity, and false if the entity doesn't have such a field.
+// key is the zero value of the entity's key.
+func (q Query) Validate(fieldOf func(Field) (any, bool), key any) error {
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidQuery)
+	}
+
+	for _, filter := range q.Filters {
+		fieldValue, ok := fieldOf(filter.Field)
+		if !ok {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, filter.Field)
+		}
+		switch filter.Operator {
+		case Equal, NotEqual, LessThan, LessOrEqual, GreaterThan, GreaterOrEqual:
+		default:
+			return fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, filter.Operator)
+		}
+		if reflect.TypeOf(filter.Value) != reflect.TypeOf(fieldValue) {
+			return fmt.Errorf("%w: field %q can't be compared to a %T", ErrInvalidQuery, filter.Field, filter.Value)
+		}
+	}
+
+	for _, sort := range q.Sort {
+		if _, ok := fieldOf(sort.Field); !ok {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, sort.Field)
+		}
+	}
+
+	if q.After != nil {
+		if len(q.After) != len(q.Sort)+1 {
+			return fmt.Errorf("%w: after must hold a value for each sort field and the key", ErrInvalidQuery)
+		}
+		for i, sort := range q.Sort {
+			fieldValue, _ := fieldOf(sort.Field)
+			if reflect.TypeOf(q.After[i]) != reflect.TypeOf(fieldValue) {
+				return fmt.Errorf("%w: field %q can't be compared to a %T", ErrInvalidQuery, sort.Field, q.After[i])
+			}
+		}
+		if reflect.TypeOf(q.After[len(q.Sort)]) != reflect.TypeOf(key) {
+			return fmt.Errorf("%w: key can't be compared to a %T", ErrInvalidQuery, q.After[len(q.Sort)])
+		}
+	}
+
+	return nil
+}
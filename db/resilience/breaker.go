@@ -0,0 +1,154 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// Closed lets every operation through.
+	Closed State = iota
+	// HalfOpen lets a single operation through to probe whether the database has recovered.
+	HalfOpen
+	// Open fails every operation with ErrCircuitOpen.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// outcome is what the result of an operation says about the health of the database
+type outcome int
+
+const (
+	// succeeded means that the database answered, even if it was with an error such as db.ErrNoResultsFound
+	succeeded outcome = iota
+	// failed means that the database could not be reached, or didn't answer in time
+	failed
+	// inconclusive means that the operation was abandoned by the caller, so it says nothing about the database
+	inconclusive
+)
+
+// breaker is the circuit breaker of a Guard
+type breaker struct {
+	guard    *Guard
+	mu       sync.Mutex
+	state    State
+	failures int       // The number of consecutive failures while closed
+	openedAt time.Time // When the breaker last opened
+	probing  bool      // Whether the probe of a half-open breaker is in flight
+}
+
+func (b *breaker) currentState() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && b.cooledDown() {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// allow returns ErrCircuitOpen if an operation may not be run right now.
+// probe is true if the operation is the one that probes a half-open breaker.
+func (b *breaker) allow() (probe bool, err error) {
+	if b.guard.failureThreshold <= 0 {
+		return false, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && b.cooledDown() {
+		b.transition(HalfOpen)
+	}
+
+	switch {
+	case b.state == Closed:
+		return false, nil
+	case b.state == HalfOpen && !b.probing:
+		b.probing = true
+		return true, nil
+	default:
+		return false, ErrCircuitOpen
+	}
+}
+
+// record updates the breaker with the outcome of an operation that allow let through
+func (b *breaker) record(probe bool, result outcome) {
+	if b.guard.failureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+		switch result {
+		case succeeded:
+			b.failures = 0
+			b.transition(Closed)
+		case failed:
+			b.open()
+		}
+		return
+	}
+
+	if b.state != Closed {
+		return // The breaker opened while the operation was running
+	}
+	switch result {
+	case succeeded:
+		b.failures = 0
+	case failed:
+		b.failures++
+		if b.failures >= b.guard.failureThreshold {
+			b.open()
+		}
+	}
+}
+
+func (b *breaker) open() {
+	b.failures = 0
+	b.openedAt = b.guard.clock()
+	b.transition(Open)
+}
+
+func (b *breaker) cooledDown() bool {
+	return !b.guard.clock().Before(b.openedAt.Add(b.guard.cooldown))
+}
+
+// transition changes the state of the breaker, and reports the change
+func (b *breaker) transition(state State) {
+	if b.state == state {
+		return
+	}
+	previous := b.state
+	b.state = state
+
+	if metrics := b.guard.metrics; metrics != nil {
+		metrics.state.Set(float64(state))
+		metrics.transitions.WithLabelValues(state.String()).Inc()
+	}
+
+	switch state {
+	case Open:
+		b.guard.logger.Warn("database circuit breaker opened", "previous", previous.String(), "cooldown", b.guard.cooldown)
+	default:
+		b.guard.logger.Info("database circuit breaker state changed", "previous", previous.String(), "state", state.String())
+	}
+}
@@ -0,0 +1,45 @@
+package resilience
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Database is a db.Database whose datastores run every call made to the datastores of another db.Database
+// through a Guard.
+type Database struct {
+	next  db.Database
+	guard *Guard
+}
+
+// Wrap returns a Database that uses guard to retry the operations of database and fail fast while it is down.
+func Wrap(database db.Database, guard *Guard) *Database {
+	return &Database{next: database, guard: guard}
+}
+
+// Person implements db.Database.
+func (d *Database) Person() db.Datastore[db.Person, uuid.UUID] {
+	return New(d.next.Person(), "person", d.guard)
+}
+
+// PersonHistory implements db.Database.
+func (d *Database) PersonHistory() db.HistoryStore[db.Person, uuid.UUID] {
+	return NewHistoryStore(d.next.PersonHistory(), "person_history", d.guard)
+}
+
+// PersonFeed implements db.Database. Subscriptions are not retried, since a subscriber can resume from the last
+// event it received.
+func (d *Database) PersonFeed() db.ChangeFeed[db.Person, uuid.UUID] {
+	return d.next.PersonFeed()
+}
+
+// WithTx implements db.Database. A transaction fails fast while the circuit breaker is open, but is never retried,
+// since fn might not expect to be run more than once. The operations made in the transaction are passed straight
+// to the database, since one that failed with a transient error has already doomed the transaction.
+func (d *Database) WithTx(ctx context.Context, fn func(tx db.Database) error) error {
+	return d.guard.do(ctx, "database", "tx", false, func() error {
+		return d.next.WithTx(ctx, fn)
+	})
+}
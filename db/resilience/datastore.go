@@ -0,0 +1,132 @@
+package resilience
+
+import (
+	"context"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// Datastore is a db.Datastore that runs every call made to another db.Datastore through a Guard.
+type Datastore[T db.Entity, U db.Identifier] struct {
+	next   db.Datastore[T, U]
+	entity string
+	guard  *Guard
+}
+
+// New wraps next in a Datastore that uses guard to retry its operations and fail fast while the database is down.
+// The entity labels the retries in the guard's metrics.
+func New[T db.Entity, U db.Identifier](next db.Datastore[T, U], entity string, guard *Guard) *Datastore[T, U] {
+	return &Datastore[T, U]{next: next, entity: entity, guard: guard}
+}
+
+// Get implements db.Datastore.
+func (d *Datastore[T, U]) Get(ctx context.Context, id U) (item *T, err error) {
+	err = d.guard.do(ctx, d.entity, "get", true, func() error {
+		item, err = d.next.Get(ctx, id)
+		return err
+	})
+	return item, err
+}
+
+// Insert implements db.Datastore.
+func (d *Datastore[T, U]) Insert(ctx context.Context, item *T) error {
+	return d.guard.do(ctx, d.entity, "insert", d.guard.retryWrites, func() error {
+		return d.next.Insert(ctx, item)
+	})
+}
+
+// InsertBatch implements db.Datastore.
+func (d *Datastore[T, U]) InsertBatch(ctx context.Context, items []*T) error {
+	return d.guard.do(ctx, d.entity, "insert_batch", d.guard.retryWrites, func() error {
+		return d.next.InsertBatch(ctx, items)
+	})
+}
+
+// Purge implements db.Datastore.
+func (d *Datastore[T, U]) Purge(ctx context.Context, id U) error {
+	return d.guard.do(ctx, d.entity, "purge", d.guard.retryWrites, func() error {
+		return d.next.Purge(ctx, id)
+	})
+}
+
+// Query implements db.Datastore.
+func (d *Datastore[T, U]) Query(ctx context.Context, query db.Query) (page *db.Page[T], err error) {
+	err = d.guard.do(ctx, d.entity, "query", true, func() error {
+		page, err = d.next.Query(ctx, query)
+		return err
+	})
+	return page, err
+}
+
+// Remove implements db.Datastore.
+func (d *Datastore[T, U]) Remove(ctx context.Context, id U) (item *T, err error) {
+	err = d.guard.do(ctx, d.entity, "remove", d.guard.retryWrites, func() error {
+		item, err = d.next.Remove(ctx, id)
+		return err
+	})
+	return item, err
+}
+
+// RemoveBatch implements db.Datastore.
+func (d *Datastore[T, U]) RemoveBatch(ctx context.Context, ids []U) (items []*T, err error) {
+	err = d.guard.do(ctx, d.entity, "remove_batch", d.guard.retryWrites, func() error {
+		items, err = d.next.RemoveBatch(ctx, ids)
+		return err
+	})
+	return items, err
+}
+
+// Restore implements db.Datastore.
+func (d *Datastore[T, U]) Restore(ctx context.Context, id U) (item *T, err error) {
+	err = d.guard.do(ctx, d.entity, "restore", d.guard.retryWrites, func() error {
+		item, err = d.next.Restore(ctx, id)
+		return err
+	})
+	return item, err
+}
+
+// Update implements db.Datastore.
+func (d *Datastore[T, U]) Update(ctx context.Context, item *T) error {
+	return d.guard.do(ctx, d.entity, "update", d.guard.retryWrites, func() error {
+		return d.next.Update(ctx, item)
+	})
+}
+
+// UpdateBatch implements db.Datastore.
+func (d *Datastore[T, U]) UpdateBatch(ctx context.Context, items []*T) error {
+	return d.guard.do(ctx, d.entity, "update_batch", d.guard.retryWrites, func() error {
+		return d.next.UpdateBatch(ctx, items)
+	})
+}
+
+// HistoryStore is a db.HistoryStore that runs every call made to another db.HistoryStore through a Guard.
+// Its operations only read, so they are always retried.
+type HistoryStore[T db.Entity, U db.Identifier] struct {
+	next   db.HistoryStore[T, U]
+	entity string
+	guard  *Guard
+}
+
+// NewHistoryStore wraps next in a HistoryStore that uses guard to retry its operations and fail fast
+// while the database is down. The entity labels the retries in the guard's metrics.
+func NewHistoryStore[T db.Entity, U db.Identifier](next db.HistoryStore[T, U], entity string, guard *Guard) *HistoryStore[T, U] {
+	return &HistoryStore[T, U]{next: next, entity: entity, guard: guard}
+}
+
+// Get implements db.HistoryStore.
+func (h *HistoryStore[T, U]) Get(ctx context.Context, id U, version int) (entry *db.HistoryEntry[T], err error) {
+	err = h.guard.do(ctx, h.entity, "get", true, func() error {
+		entry, err = h.next.Get(ctx, id, version)
+		return err
+	})
+	return entry, err
+}
+
+// List implements db.HistoryStore.
+func (h *HistoryStore[T, U]) List(ctx context.Context, id U) (entries []db.HistoryEntry[T], err error) {
+	err = h.guard.do(ctx, h.entity, "list", true, func() error {
+		entries, err = h.next.List(ctx, id)
+		return err
+	})
+	return entries, err
+}
@@ -0,0 +1,11 @@
+// resilience wraps a db.Database, or any single db.Datastore, so that it copes with a flaky database.
+//
+// Operations that fail with a transient error, which is one wrapping db.ErrUnavailable or a db.ErrDeadlineExceeded
+// that wasn't caused by the caller's own deadline, are retried a bounded number of times with a jittered,
+// exponentially growing delay. Retries never go past the deadline of the caller's context.
+//
+// A circuit breaker counts consecutive transient errors. Once there are too many, it opens and every operation
+// fails immediately with ErrCircuitOpen, which wraps db.ErrUnavailable, instead of waiting on a database that is
+// known to be down. After a cooldown a single operation is let through to probe the database, which closes the
+// breaker again if it succeeds.
+package resilience
@@ -0,0 +1,216 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/williabk198/go-api-server-template/db"
+)
+
+// ErrCircuitOpen is returned, without calling the database, while the circuit breaker is open
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker is open", db.ErrUnavailable)
+
+// Default settings of a Guard, which can be changed with WithRetries and WithBreaker
+const (
+	DefaultMaxAttempts      = 3
+	DefaultBaseDelay        = 50 * time.Millisecond
+	DefaultMaxDelay         = time.Second
+	DefaultFailureThreshold = 5
+	DefaultCooldown         = 10 * time.Second
+)
+
+// Option configures a Guard when it is created
+type Option func(*options)
+
+type options struct {
+	maxAttempts      int
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+	retryWrites      bool
+	failureThreshold int
+	cooldown         time.Duration
+	logger           *slog.Logger
+	metrics          *Metrics
+	clock            db.Clock
+	sleep            func(ctx context.Context, d time.Duration) error
+}
+
+// WithRetries sets how many times an operation is attempted in total, and the delay before the first retry,
+// which doubles with every further retry up to maxDelay. A jitter of up to half the delay is subtracted from it,
+// so that callers that failed at the same time don't retry at the same time. One attempt disables retries.
+func WithRetries(maxAttempts int, baseDelay, maxDelay time.Duration) Option {
+	return func(o *options) {
+		o.maxAttempts = maxAttempts
+		o.baseDelay = baseDelay
+		o.maxDelay = maxDelay
+	}
+}
+
+// WithWriteRetries retries operations that change data as well. By default only reads are retried, since a write
+// that failed with a transient error might still have been applied, in which case retrying it could apply it twice
+// or fail with a conflict.
+func WithWriteRetries() Option {
+	return func(o *options) {
+		o.retryWrites = true
+	}
+}
+
+// WithBreaker sets how many consecutive transient errors open the circuit breaker,
+// and how long it stays open before an operation is let through to probe the database.
+// A threshold of zero disables the circuit breaker.
+func WithBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return func(o *options) {
+		o.failureThreshold = failureThreshold
+		o.cooldown = cooldown
+	}
+}
+
+// WithLogger sets the logger that changes of the circuit breaker's state are reported to. By default slog.Default() is used.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithMetrics records the state of the circuit breaker, and the retries that are made, in metrics.
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
+
+// WithClock sets the clock that is used to time the circuit breaker's cooldown, and to check whether a retry
+// would go past the caller's deadline. By default the system clock is used.
+func WithClock(clock db.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// Guard applies the retry policy and circuit breaker to the operations of one database.
+// Every datastore of a database should share the same Guard, since they fail together.
+type Guard struct {
+	options
+	breaker *breaker
+}
+
+// NewGuard creates a Guard whose circuit breaker is closed.
+func NewGuard(opts ...Option) *Guard {
+	o := options{
+		maxAttempts:      DefaultMaxAttempts,
+		baseDelay:        DefaultBaseDelay,
+		maxDelay:         DefaultMaxDelay,
+		failureThreshold: DefaultFailureThreshold,
+		cooldown:         DefaultCooldown,
+		logger:           slog.Default(),
+		clock:            time.Now,
+		sleep:            sleep,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxAttempts < 1 {
+		o.maxAttempts = 1
+	}
+
+	g := &Guard{options: o}
+	g.breaker = &breaker{guard: g}
+	if o.metrics != nil {
+		o.metrics.state.Set(float64(Closed))
+	}
+	return g
+}
+
+// State returns the current state of the circuit breaker.
+func (g *Guard) State() State {
+	return g.breaker.currentState()
+}
+
+// do runs fn as the given operation of entity. It is retried if it fails with a transient error and retry is set.
+func (g *Guard) do(ctx context.Context, entity, operation string, retry bool, fn func() error) error {
+	attempts := 1
+	if retry {
+		attempts = g.maxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		probe, err := g.breaker.allow()
+		if err != nil {
+			return err
+		}
+
+		err = fn()
+		g.breaker.record(probe, outcomeOf(ctx, err))
+		if err == nil || attempt >= attempts || !isTransient(ctx, err) {
+			return err
+		}
+
+		delay := g.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && !g.clock().Add(delay).Before(deadline) {
+			return err // The retry could not finish in time anyway
+		}
+		if g.metrics != nil {
+			g.metrics.retries.WithLabelValues(entity, operation).Inc()
+		}
+		if g.sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns how long to wait before the given retry, counting from 1
+func (g *Guard) backoff(retry int) time.Duration {
+	delay := g.maxDelay
+	if shift := retry - 1; shift < 32 && g.baseDelay<<shift < g.maxDelay {
+		delay = g.baseDelay << shift
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// isTransient reports whether err might not happen again if the operation is retried
+func isTransient(ctx context.Context, err error) bool {
+	switch {
+	case errors.Is(err, ErrCircuitOpen), ctx.Err() != nil:
+		return false
+	case errors.Is(err, db.ErrUnavailable):
+		return true
+	case errors.Is(err, db.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return true // The database's own timeout, since the caller's context isn't done
+	default:
+		return false
+	}
+}
+
+// outcomeOf returns what err, returned by an operation run with ctx, says about the health of the database
+func outcomeOf(ctx context.Context, err error) outcome {
+	switch {
+	case err == nil:
+		return succeeded
+	case isTransient(ctx, err):
+		return failed
+	case ctx.Err() != nil:
+		return inconclusive
+	default:
+		return succeeded
+	}
+}
+
+// sleep waits for d, or until ctx is done, in which case its error is returned
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,41 @@
+package resilience
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus metrics that a Guard records its circuit breaker's state and its retries in.
+type Metrics struct {
+	state       prometheus.Gauge
+	transitions *prometheus.CounterVec
+	retries     *prometheus.CounterVec
+}
+
+// NewMetrics creates the metrics of a Guard and registers them with registerer.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		state: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "db",
+			Name:      "circuit_breaker_state",
+			Help:      "The state of the database circuit breaker: 0 is closed, 1 is half-open and 2 is open.",
+		}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "db",
+			Name:      "circuit_breaker_transitions_total",
+			Help:      "The number of times the database circuit breaker changed to each state.",
+		}, []string{"state"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "db",
+			Name:      "retries_total",
+			Help:      "The number of times a database operation was retried after a transient error.",
+		}, []string{"entity", "operation"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.state, m.transitions, m.retries} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,289 @@
+package resilience
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/dbtest"
+	"github.com/williabk198/go-api-server-template/db/memdb"
+)
+
+func TestPersonDatastore(t *testing.T) {
+	dbtest.RunPersonDatastore(t, Wrap(memdb.NewSession(), NewGuard()))
+}
+
+func TestPersonHistory(t *testing.T) {
+	dbtest.RunPersonHistory(t, Wrap(memdb.NewSession(), NewGuard()))
+}
+
+func TestPersonFeed(t *testing.T) {
+	dbtest.RunPersonFeed(t, Wrap(memdb.NewSession(), NewGuard()))
+}
+
+func TestTx(t *testing.T) {
+	dbtest.RunTx(t, Wrap(memdb.NewSession(), NewGuard()))
+}
+
+var errTransient = fmt.Errorf("%w: connection refused", db.ErrUnavailable)
+
+// flakyDatastore fails the calls to Get and Update with the given errors, in order, before passing them on
+type flakyDatastore struct {
+	db.Datastore[db.Person, uuid.UUID]
+	errs  []error
+	calls int
+}
+
+func (f *flakyDatastore) fail() error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyDatastore) Get(ctx context.Context, id uuid.UUID) (*db.Person, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return f.Datastore.Get(ctx, id)
+}
+
+func (f *flakyDatastore) Update(ctx context.Context, item *db.Person) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.Datastore.Update(ctx, item)
+}
+
+// fakeClock is a db.Clock that only moves when it sleeps, or when it is told to
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	f.slept = append(f.slept, d)
+	f.now = f.now.Add(d)
+	return nil
+}
+
+func newTestGuard(clock *fakeClock, opts ...Option) *Guard {
+	guard := NewGuard(append([]Option{WithClock(clock.Now)}, opts...)...)
+	guard.sleep = clock.Sleep
+	return guard
+}
+
+func TestDatastore_Retries(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		errs      []error
+		call      func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name: "Transient Errors Are Retried",
+			errs: []error{errTransient, errTransient},
+			call: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				_, err := store.Get(ctx, person.ID)
+				return err
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "Database Timeouts Are Retried",
+			errs: []error{fmt.Errorf("%w: canceling statement", db.ErrDeadlineExceeded)},
+			call: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				_, err := store.Get(ctx, person.ID)
+				return err
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "Attempts Are Bounded",
+			errs: []error{errTransient, errTransient, errTransient, errTransient},
+			call: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				_, err := store.Get(ctx, person.ID)
+				return err
+			},
+			wantCalls: 3,
+			wantErr:   db.ErrUnavailable,
+		},
+		{
+			name: "Not Found Is Not Retried",
+			errs: []error{db.ErrNoResultsFound},
+			call: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				_, err := store.Get(ctx, person.ID)
+				return err
+			},
+			wantCalls: 1,
+			wantErr:   db.ErrNoResultsFound,
+		},
+		{
+			name: "Writes Are Not Retried By Default",
+			errs: []error{errTransient},
+			call: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				return store.Update(ctx, person)
+			},
+			wantCalls: 1,
+			wantErr:   db.ErrUnavailable,
+		},
+		{
+			name: "Writes Are Retried If Enabled",
+			opts: []Option{WithWriteRetries()},
+			errs: []error{errTransient},
+			call: func(ctx context.Context, store db.Datastore[db.Person, uuid.UUID], person *db.Person) error {
+				return store.Update(ctx, person)
+			},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := &flakyDatastore{Datastore: memdb.NewSession().Person(), errs: tt.errs}
+			person := &db.Person{FirstName: "Testy", LastName: "McTesterson"}
+			if err := backend.Datastore.Insert(ctx, person); err != nil {
+				t.Fatalf("failed to insert person: %v", err)
+			}
+
+			guard := newTestGuard(&fakeClock{}, tt.opts...)
+			err := tt.call(ctx, New[db.Person, uuid.UUID](backend, "person", guard), person)
+
+			assert.Equal(t, tt.wantCalls, backend.calls)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDatastore_RetriesRespectDeadline(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	guard := newTestGuard(clock, WithRetries(5, time.Minute, time.Hour))
+	backend := &flakyDatastore{Datastore: memdb.NewSession().Person(), errs: []error{errTransient, errTransient, errTransient}}
+	store := New[db.Person, uuid.UUID](backend, "person", guard)
+
+	// The first retry waits at most a minute, the second at least a minute, which would go past the deadline
+	ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(90*time.Second))
+	defer cancel()
+
+	_, err := store.Get(ctx, uuid.New())
+	assert.ErrorIs(t, err, db.ErrUnavailable)
+	assert.Equal(t, 2, backend.calls)
+}
+
+func TestGuard_Backoff(t *testing.T) {
+	guard := NewGuard(WithRetries(10, 100*time.Millisecond, time.Second))
+
+	tests := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{retry: 1, max: 100 * time.Millisecond},
+		{retry: 2, max: 200 * time.Millisecond},
+		{retry: 3, max: 400 * time.Millisecond},
+		{retry: 4, max: 800 * time.Millisecond},
+		{retry: 5, max: time.Second},
+		{retry: 40, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.retry), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				delay := guard.backoff(tt.retry)
+				assert.LessOrEqual(t, delay, tt.max)
+				assert.GreaterOrEqual(t, delay, tt.max/2)
+			}
+		})
+	}
+}
+
+func TestGuard_Breaker(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	var logs bytes.Buffer
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("failed to create metrics: %v", err)
+	}
+	guard := newTestGuard(clock,
+		WithRetries(1, 0, 0),
+		WithBreaker(2, 10*time.Second),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+		WithMetrics(metrics),
+	)
+	backend := &flakyDatastore{Datastore: memdb.NewSession().Person()}
+	store := New[db.Person, uuid.UUID](backend, "person", guard)
+
+	// Errors that come from a healthy database don't count
+	backend.errs = []error{errTransient, db.ErrNoResultsFound, errTransient}
+	for i := 0; i < 3; i++ {
+		store.Get(ctx, uuid.New())
+	}
+	assert.Equal(t, Closed, guard.State())
+
+	// Consecutive transient errors open it
+	backend.errs = []error{errTransient}
+	_, err = store.Get(ctx, uuid.New())
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, Open, guard.State())
+	assert.Equal(t, float64(Open), testutil.ToFloat64(metrics.state))
+	assert.Contains(t, logs.String(), "database circuit breaker opened")
+
+	// While it is open the database isn't called
+	calls := backend.calls
+	_, err = store.Get(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, err, db.ErrUnavailable)
+	assert.Equal(t, calls, backend.calls)
+
+	// After the cooldown a failed probe opens it again
+	clock.now = clock.now.Add(10 * time.Second)
+	assert.Equal(t, HalfOpen, guard.State())
+	backend.errs = []error{errTransient}
+	_, err = store.Get(ctx, uuid.New())
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, Open, guard.State())
+
+	// And a successful one closes it
+	clock.now = clock.now.Add(10 * time.Second)
+	_, err = store.Get(ctx, uuid.New())
+	assert.ErrorIs(t, err, db.ErrNoResultsFound)
+	assert.Equal(t, Closed, guard.State())
+	assert.Equal(t, float64(Closed), testutil.ToFloat64(metrics.state))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.transitions.WithLabelValues("open")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.transitions.WithLabelValues("half-open")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.transitions.WithLabelValues("closed")))
+}
+
+func TestGuard_BreakerIgnoresCanceledCalls(t *testing.T) {
+	clock := &fakeClock{}
+	guard := newTestGuard(clock, WithBreaker(1, time.Second))
+	backend := &flakyDatastore{Datastore: memdb.NewSession().Person(), errs: []error{context.Canceled}}
+	store := New[db.Person, uuid.UUID](backend, "person", guard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	store.Get(ctx, uuid.New())
+	assert.Equal(t, Closed, guard.State())
+}
@@ -0,0 +1,5 @@
+// sqlite is an implementation of db.Database that stores its data in a single SQLite database file.
+//
+// It is intended for small, single-node deployments that do not warrant running a separate database server.
+// The go-sqlite3 driver requires cgo, so CGO_ENABLED=1 and a C compiler are needed to build this package.
+package sqlite
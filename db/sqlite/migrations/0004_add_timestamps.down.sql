@@ -0,0 +1,7 @@
+ALTER TABLE person_history DROP COLUMN removed_at;
+ALTER TABLE person_history DROP COLUMN updated_at;
+ALTER TABLE person_history DROP COLUMN created_at;
+
+ALTER TABLE person DROP COLUMN removed_at;
+ALTER TABLE person DROP COLUMN updated_at;
+ALTER TABLE person DROP COLUMN created_at;
@@ -0,0 +1,17 @@
+-- SQLite can only add NOT NULL columns with a constant default, so the timestamps of existing rows are filled in
+-- afterwards. People were created when their first change was recorded, if the history goes back that far.
+ALTER TABLE person ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE person ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE person ADD COLUMN removed_at TIMESTAMP;
+UPDATE person SET
+	created_at = COALESCE((SELECT MIN(changed_at) FROM person_history WHERE person_id = person.id), CURRENT_TIMESTAMP),
+	updated_at = COALESCE((SELECT MAX(changed_at) FROM person_history WHERE person_id = person.id), CURRENT_TIMESTAMP);
+UPDATE person SET removed_at = updated_at WHERE removed;
+
+ALTER TABLE person_history ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE person_history ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE person_history ADD COLUMN removed_at TIMESTAMP;
+UPDATE person_history SET
+	created_at = (SELECT MIN(changed_at) FROM person_history AS earliest WHERE earliest.person_id = person_history.person_id),
+	updated_at = changed_at,
+	removed_at = CASE WHEN removed THEN changed_at END;
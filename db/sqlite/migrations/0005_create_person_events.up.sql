@@ -0,0 +1,9 @@
+CREATE TABLE person_events (
+	sequence   INTEGER   PRIMARY KEY AUTOINCREMENT,
+	person_id  TEXT      NOT NULL,
+	kind       TEXT      NOT NULL,
+	principal  TEXT      NOT NULL DEFAULT '',
+	changed_at TIMESTAMP NOT NULL,
+	before     TEXT,
+	after      TEXT
+);
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+
+	"github.com/mattn/go-sqlite3" // Also registers the "sqlite3" database/sql driver
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/sqldb"
+	"github.com/williabk198/go-api-server-template/db/migrations"
+)
+
+// dialect describes the SQLite flavor of SQL. SQLite understands the '?' bind parameters as is.
+var dialect = sqldb.Dialect{
+	NoLimit:  "-1",
+	Classify: classifyError,
+}
+
+// classifyError returns the db error that describes an error returned by SQLite, based on its result code
+func classifyError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+
+	switch sqliteErr.Code {
+	case sqlite3.ErrConstraint:
+		return db.ErrConflict
+	case sqlite3.ErrMismatch, sqlite3.ErrRange, sqlite3.ErrTooBig:
+		return db.ErrInvalidArgument
+	case sqlite3.ErrPerm, sqlite3.ErrAuth, sqlite3.ErrReadonly:
+		return db.ErrPermissionDenied
+	case sqlite3.ErrBusy, sqlite3.ErrLocked, sqlite3.ErrCantOpen, sqlite3.ErrIoErr, sqlite3.ErrFull, sqlite3.ErrNotADB, sqlite3.ErrCorrupt:
+		return db.ErrUnavailable
+	}
+	return nil
+}
+
+// migrationFiles holds the migrations that create and change the tables used by the datastores
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationDialect runs the migrations without an extra lock, since the immediate transactions used by
+// the connections returned from Open already hold the write lock of the whole database.
+var migrationDialect = migrations.Dialect{}
+
+// Open opens the SQLite database file at path, creating it if it does not exist yet.
+// The connection uses write-ahead logging so that readers do not block the writer.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_foreign_keys", "on")
+	params.Set("_txlock", "immediate")
+
+	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?%s", path, params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	return conn, nil
+}
+
+// Option configures the database returned by NewSession
+type Option func(*options)
+
+type options struct {
+	clock db.Clock
+}
+
+// WithClock sets the clock that is used to timestamp changes. By default the system clock is used.
+func WithClock(clock db.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// Migrator returns a Migrator that applies the migrations of the SQLite datastores to conn.
+func Migrator(conn *sql.DB) (*migrations.Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := migrations.Load(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrations.New(conn, loaded, migrationDialect), nil
+}
+
+// NewSession returns a db.Database that uses the given connection.
+// The database must already be migrated to the latest version, see Migrator.
+func NewSession(ctx context.Context, conn *sql.DB, opts ...Option) (db.Database, error) {
+	options := options{clock: db.SystemClock}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	migrator, err := Migrator(conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrator.Check(ctx); err != nil {
+		return nil, fmt.Errorf("sqlite schema is not up to date: %w", err)
+	}
+
+	return sqldb.New(conn, dialect, options.clock), nil
+}
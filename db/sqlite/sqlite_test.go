@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/williabk198/go-api-server-template/db"
+	"github.com/williabk198/go-api-server-template/db/internal/dbtest"
+	"github.com/williabk198/go-api-server-template/db/migrations"
+)
+
+// testConn creates a new, empty database file that is deleted once the test completes
+func testConn(t *testing.T) *sql.DB {
+	t.Helper()
+
+	conn, err := Open(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// testDatabase creates a new database file, migrated to the latest version, that is deleted once the test completes
+func testDatabase(t *testing.T, opts ...Option) db.Database {
+	t.Helper()
+	ctx := context.Background()
+	conn := testConn(t)
+
+	migrator, err := Migrator(conn)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("failed to migrate sqlite database: %v", err)
+	}
+
+	database, err := NewSession(ctx, conn, opts...)
+	if err != nil {
+		t.Fatalf("failed to create sqlite session: %v", err)
+	}
+
+	return database
+}
+
+func TestPersonDatastore(t *testing.T) {
+	dbtest.RunPersonDatastore(t, testDatabase(t))
+}
+
+func TestPersonQuery(t *testing.T) {
+	dbtest.RunPersonQuery(t, testDatabase(t))
+}
+
+func TestPersonBatch(t *testing.T) {
+	dbtest.RunPersonBatch(t, testDatabase(t))
+}
+
+func TestPersonErrors(t *testing.T) {
+	dbtest.RunPersonErrors(t, testDatabase(t))
+}
+
+func TestPersonHistory(t *testing.T) {
+	dbtest.RunPersonHistory(t, testDatabase(t))
+}
+
+func TestPersonTimestamps(t *testing.T) {
+	dbtest.RunPersonTimestamps(t, func(clock db.Clock) db.Database {
+		return testDatabase(t, WithClock(clock))
+	})
+}
+
+func TestPersonFeed(t *testing.T) {
+	dbtest.RunPersonFeed(t, testDatabase(t))
+}
+
+func TestTx(t *testing.T) {
+	dbtest.RunTx(t, testDatabase(t))
+}
+
+func TestMigrations(t *testing.T) {
+	conn := testConn(t)
+	migrator, err := Migrator(conn)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	dbtest.RunMigrations(t, migrator, func(ctx context.Context) ([]string, error) {
+		rows, err := conn.QueryContext(ctx, `
+SELECT type || ' ' || name || ': ' || COALESCE(sql, '') FROM sqlite_master
+WHERE name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'
+ORDER BY type, name`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var schema []string
+		for rows.Next() {
+			var object string
+			if err := rows.Scan(&object); err != nil {
+				return nil, err
+			}
+			schema = append(schema, object)
+		}
+		return schema, rows.Err()
+	})
+}
+
+func TestMigrations_ExistingTable(t *testing.T) {
+	ctx := context.Background()
+	conn := testConn(t)
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE person (id TEXT PRIMARY KEY)`); err != nil {
+		t.Fatalf("failed to create person table: %v", err)
+	}
+
+	migrator, err := Migrator(conn)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	_, err = migrator.Up(ctx)
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestNewSession_NotMigrated(t *testing.T) {
+	_, err := NewSession(context.Background(), testConn(t))
+	assert.ErrorIs(t, err, migrations.ErrPendingMigrations)
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "Constraint", err: sqlite3.Error{Code: sqlite3.ErrConstraint}, want: db.ErrConflict},
+		{name: "Mismatch", err: sqlite3.Error{Code: sqlite3.ErrMismatch}, want: db.ErrInvalidArgument},
+		{name: "Read Only", err: sqlite3.Error{Code: sqlite3.ErrReadonly}, want: db.ErrPermissionDenied},
+		{name: "Busy", err: sqlite3.Error{Code: sqlite3.ErrBusy}, want: db.ErrUnavailable},
+		{name: "Wrapped", err: fmt.Errorf("failed: %w", sqlite3.Error{Code: sqlite3.ErrIoErr}), want: db.ErrUnavailable},
+		{name: "Other SQLite Error", err: sqlite3.Error{Code: sqlite3.ErrInternal}, want: nil},
+		{name: "Not a SQLite Error", err: fmt.Errorf("mock error"), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyError(tt.err))
+		})
+	}
+}
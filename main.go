@@ -1,7 +1,21 @@
 package main
 
-import "github.com/williabk198/go-api-server-template/daemon"
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/williabk198/go-api-server-template/daemon"
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := daemon.Migrate(context.Background(), os.Stdout, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	daemon.Start()
 }
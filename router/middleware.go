@@ -1,5 +1,24 @@
 package router
 
+import (
+	"net/http"
+
+	"github.com/williabk198/go-api-server-template/auth"
+)
+
 //TODO: Put any custom middleware functions here.
 //      If you intend on having publicly avavailable middleware functions,
 //      then consider adding a "middleware" package to the root of this project.
+
+// authenticate adds the principal that made the request to the request context, if the authenticator recognizes it.
+// Requests it doesn't recognize are passed on without a principal, and it's up to the handlers to turn them away.
+func authenticate(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal, ok := authenticator.Authenticate(r); ok {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -6,25 +6,75 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
+	"github.com/williabk198/go-api-server-template/auth"
 	"github.com/williabk198/go-api-server-template/controller"
 )
 
+// Option configures optional behavior of the router
+type Option func(*options)
+
+// WithAuthenticator sets how the principal that made a request is identified.
+// Without it every request is anonymous, which means admin-only routes can't be used.
+func WithAuthenticator(authenticator auth.Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = authenticator
+	}
+}
+
+// WithMetrics serves the given handler, which exposes the server's metrics, at GET /metrics.
+func WithMetrics(handler http.Handler) Option {
+	return func(o *options) {
+		o.metrics = handler
+	}
+}
+
+type options struct {
+	authenticator auth.Authenticator
+	metrics       http.Handler
+}
+
 // NewRouter maps routes to controller functions and returns the root router
-func NewRouter(controls controller.Controller) http.Handler {
+func NewRouter(controls controller.Controller, opts ...Option) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	rootRouter := chi.NewRouter()
-	rootRouter.Use(middleware.SetHeader("Content-Type", "application/json"))
+	rootRouter.Use(middleware.RequestID)
 	rootRouter.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "Last-Event-ID"},
+		ExposedHeaders:   []string{"ETag", "Accept-Patch"},
 		AllowCredentials: false,
 	}))
+	if o.authenticator != nil {
+		rootRouter.Use(authenticate(o.authenticator))
+	}
+
+	if o.metrics != nil {
+		rootRouter.Method(http.MethodGet, "/metrics", o.metrics)
+	}
 
 	rootRouter.Route("/person", func(r chi.Router) {
+		r.Get("/", controls.Person().GetAll)
 		r.Post("/", controls.Person().Add)
+		r.Post("/batch", controls.Person().Batch)
+		r.Get("/events", controls.Person().Events)
+		r.Get("/socket", controls.Person().Socket)
 		r.Get("/{id}", controls.Person().GetSpecific)
 		r.Delete("/{id}", controls.Person().Remove)
 		r.Put("/{id}", controls.Person().Update)
+		r.Patch("/{id}", controls.Person().Patch)
+		r.Post("/{id}/restore", controls.Person().Restore)
+		r.Get("/{id}/history", controls.Person().History)
+		r.Post("/{id}/history/{version}/revert", controls.Person().Revert)
+	})
+
+	// The handlers of the routes under /admin only serve requests made by admins
+	rootRouter.Route("/admin", func(r chi.Router) {
+		r.Delete("/person/{id}", controls.Person().Purge)
 	})
 
 	return rootRouter